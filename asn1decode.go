@@ -3,6 +3,7 @@ package asn1dynamic
 import (
 	"bytes"
 	"fmt"
+	"time"
 
 	"github.com/anton-zolotarev/go-simplejson"
 )
@@ -57,6 +58,8 @@ type AsnTag struct {
 	explicit bool
 	tagged   bool
 	taggedN  int
+	taggedC  int
+	inner    *AsnTag
 }
 
 type AsnData struct {
@@ -66,65 +69,48 @@ type AsnData struct {
 	len   int
 	tag   AsnTag
 	sub   []*AsnData
+	// tm keeps the time a UTCTime or GeneralizedTime was built from, as
+	// its contents may drop part of it
+	tm *time.Time
 }
 
 type AsnContext struct {
 	parent *AsnContext
 	tag    *AsnData
-	od     string
-}
-
-var (
-	debug    bool
-	implicit bool
-	explicit bool
-)
-
-func ImplicitMode() {
-	implicit = true
-	explicit = false
-}
-
-func ExplicitMode() {
-	explicit = true
-	implicit = false
-}
-
-func Debug(on ...bool) bool {
-	if len(on) > 0 {
-		debug = on[0]
-	}
-	return debug
+	// sheme decodes tag, it names the field in the path of an error
+	sheme *Sheme
+	// buf is the parsed data, the offset of an element in an error is
+	// taken within it
+	buf []byte
+	od  string
+	der bool
+	opt *Options
+	// wide decodes an INTEGER too large for int64 to *big.Int, for the Go
+	// target of DecodeInto to take it or not
+	wide bool
 }
 
-func debugHex(data []byte) {
-	if debug {
+// debugHex and debugPrint of a context write to the Debug of its options.
+func (ctx *AsnContext) debugHex(data []byte) {
+	if w := ctx.options().Debug; w != nil {
 		var buff bytes.Buffer
 		buff.WriteString(fmt.Sprintf("len %d\n", len(data)))
 		for i := 0; i < len(data); i++ {
 			buff.WriteString(fmt.Sprintf("%02X ", data[i]))
 		}
-		fmt.Println(buff.String())
+		fmt.Fprintln(w, buff.String())
 	}
 }
 
-func debugPrint(frm string, arg ...interface{}) {
-	if debug {
-		fmt.Printf(frm, arg...)
-		fmt.Print("\n")
-	}
-}
-
-func debugPrintln(arg ...interface{}) {
-	if debug {
-		fmt.Println(arg...)
+func (ctx *AsnContext) debugPrint(frm string, arg ...interface{}) {
+	if w := ctx.options().Debug; w != nil {
+		fmt.Fprintf(w, frm, arg...)
+		fmt.Fprint(w, "\n")
 	}
 }
 
 func Errorf(frm string, arg ...interface{}) error {
-	err := fmt.Errorf(frm, arg...)
-	debugPrint(err.Error())
-	return err
+	return fmt.Errorf(frm, arg...)
 }
 
 func decodeTypeErr(tgn string, sheme *Sheme) error {
@@ -319,6 +305,15 @@ func (th *AsnData) RawData() []byte {
 }
 
 func (th *AsnData) Parse(data []byte) ([]byte, bool, error) {
+	return th.parse(data, nil, 1)
+}
+
+// parse reads an element at the level depth of the nesting, tracing to the
+// Debug of the options of ctx and stopping at their MaxDepth.
+func (th *AsnData) parse(data []byte, ctx *AsnContext, depth int) ([]byte, bool, error) {
+	if max := ctx.options().MaxDepth; max > 0 && depth > max {
+		return data, false, decodeDataErr("elements nested deeper than %d", max)
+	}
 	if len(data) < 2 {
 		return data, false, nil
 	}
@@ -328,16 +323,34 @@ func (th *AsnData) Parse(data []byte) ([]byte, bool, error) {
 		return data, false, err
 	}
 	// считываем длину
+	indefinite := false
 	th.len = int(data[pos] & 0x7F)
-	if th.len != int(data[pos]) {
+	if data[pos] == 0x80 {
+		if !th.tag.tagConstructed {
+			return data, false, Errorf("indefinite length of primitive '%s'", th.tag.typeName())
+		}
+		indefinite = true
+	} else if th.len != int(data[pos]) {
+		num := th.len
+		if num > 4 {
+			return data, false, Errorf("'%s' length too large", th.tag.typeName())
+		}
+		if len(data)-pos-1 < num {
+			return data, false, nil
+		}
 		buf := 0
-		for ; pos-1 < th.len; pos++ {
-			buf = (buf * 256) + int(data[pos+1])
+		for i := 0; i < num; i++ {
+			pos++
+			buf = (buf << 8) | int(data[pos])
 		}
 		th.len = buf
 	}
 	pos++
 
+	if indefinite {
+		return th.parseIndefinite(data, pos, ctx, depth)
+	}
+
 	if len(data)-pos < th.len {
 		return data, false, nil
 	}
@@ -345,17 +358,17 @@ func (th *AsnData) Parse(data []byte) ([]byte, bool, error) {
 	th.reset()
 	th.fdata = data[:pos+th.len]
 	th.data = th.fdata[pos:]
-	debugPrint("Parse: %s len: %d", th.tag.typeName(), th.len)
+	ctx.debugPrint("Parse: %s len: %d", th.tag.typeName(), th.len)
 	if th.tag.tagConstructed {
-		debugPrint("[")
+		ctx.debugPrint("[")
 		buf := th.data
 		for ok := true; len(buf) > 0 && ok; {
 			var asn AsnData
-			if buf, ok, err = asn.Parse(buf); ok {
+			if buf, ok, err = asn.parse(buf, ctx, depth+1); ok {
 				th.sub = append(th.sub, &asn)
 			}
 		}
-		debugPrint("]")
+		ctx.debugPrint("]")
 		if err != nil {
 			return data, false, err
 		}
@@ -366,20 +379,63 @@ func (th *AsnData) Parse(data []byte) ([]byte, bool, error) {
 	return data[len(th.fdata):], true, nil
 }
 
+// parseIndefinite reads the contents of a constructed value of indefinite
+// length, which starts at pos and runs up to the end-of-contents octets.
+// data covers the contents without them, fdata includes them.
+func (th *AsnData) parseIndefinite(data []byte, pos int, ctx *AsnContext, depth int) ([]byte, bool, error) {
+	th.reset()
+	ctx.debugPrint("Parse: %s indefinite len", th.tag.typeName())
+	ctx.debugPrint("[")
+	buf := data[pos:]
+	for {
+		var asn AsnData
+		rest, ok, err := asn.parse(buf, ctx, depth+1)
+		if err != nil || !ok {
+			return data, false, err
+		}
+		if asn.tag.isEOC() {
+			if asn.tag.tagConstructed || asn.len != 0 {
+				return data, false, Errorf("'%s' invalid end-of-contents", th.tag.typeName())
+			}
+			break
+		}
+		th.sub = append(th.sub, &asn)
+		buf = rest
+	}
+	ctx.debugPrint("]")
+	th.len = len(data) - pos - len(buf)
+	th.fdata = data[:pos+th.len+2]
+	th.data = data[pos : pos+th.len]
+	return data[len(th.fdata):], true, nil
+}
+
 func (th *AsnData) decode(sheme *Sheme, ctx *AsnContext) (res interface{}, err error) {
 	if sheme == nil {
 		return nil, Errorf("Error sheme is nil")
 	}
 
-	if debug {
+	opt := ctx.options()
+	if opt.Debug != nil {
 		defer func() {
 			if err == nil {
-				debugPrintln(sheme.Type(), "result:", res, "\n")
+				ctx.debugPrint("%s result: %v\n", sheme.Type(), res)
 			}
 		}()
 	}
 
-	markTag(th, sheme)
+	markTag(th, sheme, opt.Tagging)
+	for th.tag.inner != nil && th.tag.tagClass == th.tag.taggedC && th.tag.tagNumber == th.tag.taggedN && th.tag.tagConstructed && len(th.sub) == 1 {
+		// an explicit tag wrapping the own tag of the referenced type
+		el := *th.sub[0]
+		el.sheme = sheme
+		el.tag.assign(th.tag.inner)
+		th = &el
+	}
+	// the number of a tag of another class does not stand for a universal type
+	if th.tag.tagClass != classUniversal && sheme.TypeEn() > tagEOC &&
+		(!th.tag.tagged || th.tag.tagClass != th.tag.taggedC || th.tag.tagNumber != th.tag.taggedN) {
+		return nil, decodeTypeErr(th.tag.typeName(), sheme)
+	}
 
 	switch typeTag(sheme.Type()) {
 	case tagNULL:
@@ -387,7 +443,14 @@ func (th *AsnData) decode(sheme *Sheme, ctx *AsnContext) (res interface{}, err e
 	case tagBOOLEAN:
 		return th.parseBool(sheme, ctx)
 	case tagINTEGER:
-		return th.parseInt64(sheme, ctx)
+		if sheme.BigAttr() {
+			return th.parseBigInt(sheme, ctx)
+		}
+		ret, err := th.parseInt64(sheme, ctx)
+		if err != nil && ctx.wide {
+			return th.parseBigInt(sheme, ctx)
+		}
+		return ret, err
 	case tagENUMERATED:
 		return th.parseEnumerated(sheme, ctx)
 	case tagREAL:
@@ -398,6 +461,22 @@ func (th *AsnData) decode(sheme *Sheme, ctx *AsnContext) (res interface{}, err e
 		return th.parseNumericString(sheme, ctx)
 	case tagPrintableString:
 		return th.parsePrintableString(sheme, ctx)
+	case tagIA5String:
+		return th.parseIA5String(sheme, ctx)
+	case tagVisibleString:
+		return th.parseVisibleString(sheme, ctx)
+	case tagGraphicString:
+		return th.parseGraphicString(sheme, ctx)
+	case tagGeneralString:
+		return th.parseGeneralString(sheme, ctx)
+	case tagTeletexString:
+		return th.parseTeletexString(sheme, ctx)
+	case tagVideotexString:
+		return th.parseVideotexString(sheme, ctx)
+	case tagBMPString:
+		return th.parseBMPString(sheme, ctx)
+	case tagUniversalString:
+		return th.parseUniversalString(sheme, ctx)
 	case tagOCTET_STR:
 		return th.parseOctetString(sheme, ctx)
 	case tagBIT_STR:
@@ -411,13 +490,21 @@ func (th *AsnData) decode(sheme *Sheme, ctx *AsnContext) (res interface{}, err e
 	case tagGeneralizedTime:
 		return th.parseGeneralizedTime(sheme, ctx)
 	case tagSEQUENCE:
-		if sheme.OfAttr() != nil {
+		if sheme.isOf() {
 			return th.parseSequenceOf(sheme, ctx)
 		}
-		if sheme.FieldAttr() != nil {
+		if sheme.hasFields() {
 			return th.parseSequence(sheme, ctx)
 		}
 		return nil, decodeShemeErr("Sequence '%s' does not contain '$field' or '$of'", sheme.Name())
+	case tagSET:
+		if sheme.isOf() {
+			return th.parseSetOf(sheme, ctx)
+		}
+		if sheme.hasFields() {
+			return th.parseSet(sheme, ctx)
+		}
+		return nil, decodeShemeErr("Set '%s' does not contain '$field' or '$of'", sheme.Name())
 	case tagCHOICE:
 		return th.parseChoice(sheme, ctx)
 	case tagANY:
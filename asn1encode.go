@@ -1,6 +1,10 @@
 package asn1dynamic
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
 
 func encodeTypeErr(tgn string, sheme *Sheme) error {
 	return Errorf("encode: processing '%s' expected %s field but got %s", sheme.Name(), sheme.Type(), tgn)
@@ -15,6 +19,22 @@ func encodeShemeErr(frm string, arg ...interface{}) error {
 }
 
 func appendTagAndLength(th *AsnData, dst []byte) []byte {
+	dst = appendTag(th, dst)
+
+	if th.len >= 128 {
+		l := lengthLength(th.len)
+		dst = append(dst, 0x80|byte(l))
+		for ; l > 0; l-- {
+			dst = append(dst, byte(th.len>>uint((l-1)*8)))
+		}
+	} else {
+		dst = append(dst, byte(th.len))
+	}
+
+	return dst
+}
+
+func appendTag(th *AsnData, dst []byte) []byte {
 	b := uint8(th.tag.tagClass) << 6
 	if th.tag.tagConstructed {
 		b |= 0x20
@@ -27,85 +47,95 @@ func appendTagAndLength(th *AsnData, dst []byte) []byte {
 		b |= uint8(th.tag.tagNumber)
 		dst = append(dst, b)
 	}
+	return dst
+}
+
+// lengthLength returns the number of octets of a length in the long form.
+func lengthLength(i int) (numBytes int) {
+	numBytes = 1
+	for i > 255 {
+		numBytes++
+		i >>= 8
+	}
+	return
+}
 
+// size returns the length of the whole encoding of th: the identifier,
+// length and contents octets.
+func (th *AsnData) size() int {
+	n := th.len + 2
 	if th.len >= 128 {
-		l := lengthInt(th.len)
-		dst = append(dst, 0x80|byte(l))
-		dst = appendInt(dst, th.len)
-	} else {
-		dst = append(dst, byte(th.len))
+		n += lengthLength(th.len)
 	}
-
-	return dst
+	if th.tag.tagNumber >= 31 {
+		n += base128IntLength(int64(th.tag.tagNumber))
+	}
+	return n
 }
 
 func (th *AsnData) preprocess(parent *AsnData, idx int) int {
 	th.len = 0
 
-	debugPrint("Prepare: %s (%s)", th.tag.typeName(), th.sheme.Name())
-	if th.tag.tagClass == classUniversal && th.tag.tagNumber < tagEOC && len(th.sub) == 1 {
+	// a CHOICE is replaced by its alternative once within its tags
+	if th.tag.tagClass == classUniversal && th.tag.tagNumber < tagEOC && len(th.sub) == 1 && !(th.tag.tagged && th.tag.tagNumber == tagCHOICE) {
 		parent.sub[idx] = th.sub[0]
 		th = parent.sub[idx]
-		debugPrint("Cast: %s (%s)", th.tag.typeName(), th.sheme.Name())
 		return th.preprocess(parent, idx)
 	}
 
 	if th.tag.tagged {
 		if th.tag.implicit {
-			debugPrint("implicit %d", th.tag.taggedN)
-			th.tag.tagClass = classContextSpecific
+			th.tag.tagClass = th.tag.taggedC
 			th.tag.tagNumber = th.tag.taggedN
 		} else {
-			debugPrint("explicit %d", th.tag.taggedN)
 			th.tag.tagged = false
-			parent.sub[idx] = makeTag(classContextSpecific, th.tag.taggedN, 1)
+			parent.sub[idx] = makeTag(th.tag.taggedC, th.tag.taggedN, 1)
 			parent.sub[idx].sub[0] = th
+			if th.tag.inner != nil {
+				th.tag.assign(th.tag.inner)
+			}
 			th = parent.sub[idx]
 		}
 	}
 
 	if th.tag.tagConstructed {
-		debugPrint("[")
 		for i := 0; i < len(th.sub); i++ {
 			if th.sub[i] != nil {
-				len := th.sub[i].preprocess(th, i)
-				if len >= 128 {
-					th.len += lengthInt(len)
-				}
-				th.len += len + 2
+				th.sub[i].preprocess(th, i)
+				th.len += th.sub[i].size()
 			}
 		}
-		debugPrint("]")
 	} else {
 		th.len += len(th.data)
 	}
 
-	if th.tag.tagNumber >= 31 {
-		th.len += base128IntLength(int64(th.tag.tagNumber))
-	}
-
 	return th.len
 }
 
+// has reports whether the field f of the SEQUENCE or SET th is set.
+func (th *AsnData) has(f *Sheme) bool {
+	id := f.ID()
+	return id < len(th.sub) && th.sub[id] != nil
+}
+
 func (th *AsnData) encode(dst []byte) ([]byte, error) {
 	var err error
 	pos := len(dst)
 	dst = appendTagAndLength(th, dst)
 
-	debugPrint("Encode: %s len: %d", th.tag.typeName(), th.len)
-	if th.tag.tagConstructed {
-		debugPrint("[")
+	if th.tag.tagConstructed && th.sheme != nil && th.sheme.TypeEn() == tagSET {
+		dst, err = th.encodeSet(dst, false)
+	} else if th.tag.tagConstructed {
 		for i := 0; i < len(th.sub) && err == nil; i++ {
 			if th.sub[i] != nil {
 				dst, err = th.sub[i].encode(dst)
 			} else {
 				fld := th.sheme.FieldList()
-				if sh := fld.FindID(i); sh != nil && !sh.Optional() {
+				if sh := fld.FindID(i); sh != nil && !th.sheme.omissible(sh, th.has) {
 					err = encodeShemeErr("'%s' miss not optional field '%s'", th.sheme.Name(), sh.Name())
 				}
 			}
 		}
-		debugPrint("]")
 	} else {
 		dst = append(dst, th.data...)
 	}
@@ -113,12 +143,56 @@ func (th *AsnData) encode(dst []byte) ([]byte, error) {
 	return dst, err
 }
 
-func (th *AsnData) Encode() ([]byte, error) {
-	len := th.preprocess(nil, 0)
-	if len >= 128 {
-		len += lengthInt(len)
+// encodeSet writes the elements of a SET in the canonical order of DER:
+// fields sorted by tag, SET OF items by their encodings. With cer set the
+// elements are encoded as in CER.
+func (th *AsnData) encodeSet(dst []byte, cer bool) ([]byte, error) {
+	type item struct {
+		tag AsnTag
+		enc []byte
+	}
+	var err error
+	var items []item
+	for i := 0; i < len(th.sub) && err == nil; i++ {
+		if th.sub[i] != nil {
+			var enc []byte
+			if cer {
+				enc, err = th.sub[i].encodeCER(nil)
+			} else {
+				enc, err = th.sub[i].encode(nil)
+			}
+			if err == nil {
+				items = append(items, item{th.sub[i].tag, enc})
+			}
+		} else {
+			fld := th.sheme.FieldList()
+			if sh := fld.FindID(i); sh != nil && !th.sheme.omissible(sh, th.has) {
+				err = encodeShemeErr("'%s' miss not optional field '%s'", th.sheme.Name(), sh.Name())
+			}
+		}
+	}
+	if th.sheme.isOf() {
+		sort.SliceStable(items, func(i, j int) bool {
+			return bytes.Compare(items[i].enc, items[j].enc) < 0
+		})
+	} else {
+		sort.SliceStable(items, func(i, j int) bool {
+			if items[i].tag.tagClass != items[j].tag.tagClass {
+				return items[i].tag.tagClass < items[j].tag.tagClass
+			}
+			return items[i].tag.tagNumber < items[j].tag.tagNumber
+		})
+	}
+	for _, itm := range items {
+		dst = append(dst, itm.enc...)
 	}
-	len += 2
-	out := make([]byte, 0, len)
-	return th.encode(out)
+	return dst, err
+}
+
+func (th *AsnData) Encode() ([]byte, error) {
+	// an untagged CHOICE or a tagged element is replaced in its parent
+	top := &AsnData{sub: []*AsnData{th}}
+	th.preprocess(top, 0)
+	out := make([]byte, 0, top.sub[0].size())
+	return top.sub[0].encode(out)
 }
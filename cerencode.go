@@ -0,0 +1,106 @@
+package asn1dynamic
+
+// cerSegment is the number of contents octets of a string segment in CER.
+const cerSegment = 1000
+
+// EncodeCER encodes th in the Canonical Encoding Rules (X.690 clause 9 and
+// 11): constructed values have the indefinite length and strings longer
+// than 1000 octets are sent as a constructed series of segments. The values
+// themselves take their DER form.
+func (th *AsnData) EncodeCER() ([]byte, error) {
+	if err := th.canonical(); err != nil {
+		return nil, err
+	}
+	top := &AsnData{sub: []*AsnData{th}}
+	th.preprocess(top, 0)
+	return top.sub[0].encodeCER(nil)
+}
+
+func isStringType(tag int) bool {
+	switch tag {
+	case tagBIT_STR, tagOCTET_STR, tagObjDescriptor, tagUTF8String, tagNumericString,
+		tagPrintableString, tagTeletexString, tagVideotexString, tagIA5String,
+		tagUTCTime, tagGeneralizedTime, tagGraphicString, tagVisibleString,
+		tagGeneralString, tagUniversalString, tagBMPString:
+		return true
+	}
+	return false
+}
+
+func (th *AsnData) encodeCER(dst []byte) ([]byte, error) {
+	var err error
+	pos := len(dst)
+
+	if th.tag.tagConstructed {
+		dst = appendTag(th, dst)
+		dst = append(dst, 0x80)
+		if th.sheme != nil && th.sheme.TypeEn() == tagSET {
+			dst, err = th.encodeSet(dst, true)
+		} else {
+			for i := 0; i < len(th.sub) && err == nil; i++ {
+				if th.sub[i] != nil {
+					dst, err = th.sub[i].encodeCER(dst)
+				} else {
+					fld := th.sheme.FieldList()
+					if sh := fld.FindID(i); sh != nil && !th.sheme.omissible(sh, th.has) {
+						err = encodeShemeErr("'%s' miss not optional field '%s'", th.sheme.Name(), sh.Name())
+					}
+				}
+			}
+		}
+		dst = append(dst, 0x00, 0x00)
+	} else if th.sheme != nil && isStringType(th.sheme.TypeEn()) && len(th.data) > cerSegment {
+		dst = th.appendCERSegments(dst)
+	} else {
+		th.len = len(th.data)
+		dst = appendTagAndLength(th, dst)
+		dst = append(dst, th.data...)
+	}
+	th.fdata = dst[pos:]
+	return dst, err
+}
+
+// appendCERSegments writes a long string as a constructed value of primitive
+// segments of 1000 contents octets. The segments of a BIT STRING are BIT
+// STRING with the unused bits in the last one, those of other strings are
+// OCTET STRING.
+func (th *AsnData) appendCERSegments(dst []byte) []byte {
+	out := *th
+	out.tag.tagConstructed = true
+	dst = appendTag(&out, dst)
+	dst = append(dst, 0x80)
+
+	seg := makeTag(classUniversal, tagOCTET_STR, 0)
+	data := th.data
+	if th.sheme.TypeEn() == tagBIT_STR {
+		seg.tag.tagNumber = tagBIT_STR
+		pad := data[0]
+		for data = data[1:]; len(data) > 0; {
+			num := len(data)
+			if num > cerSegment-1 {
+				num = cerSegment - 1
+			}
+			seg.len = num + 1
+			dst = appendTagAndLength(seg, dst)
+			if num == len(data) {
+				dst = append(dst, pad)
+			} else {
+				dst = append(dst, 0)
+			}
+			dst = append(dst, data[:num]...)
+			data = data[num:]
+		}
+	} else {
+		for len(data) > 0 {
+			num := len(data)
+			if num > cerSegment {
+				num = cerSegment
+			}
+			seg.len = num
+			dst = appendTagAndLength(seg, dst)
+			dst = append(dst, data[:num]...)
+			data = data[num:]
+		}
+	}
+	return append(dst, 0x00, 0x00)
+}
@@ -0,0 +1,68 @@
+// Command asn1gen writes Go types with BER MarshalBER and UnmarshalBER
+// methods for the classes of a sheme.
+//
+//	asn1gen [-pkg name] [-o file] [-implicit] sheme [Class ...]
+//
+// A sheme file with the .asn or .asn1 extension is read as an ASN.1 module,
+// any other one as a JSON sheme. Without classes named every class of the
+// sheme is written.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anton-zolotarev/asn1dynamic"
+)
+
+func main() {
+	pkg := flag.String("pkg", "main", "package name of the output")
+	out := flag.String("o", "", "output file, the standard output by default")
+	implicit := flag.Bool("implicit", false, "tag implicitly where the sheme does not tell")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: asn1gen [flags] sheme [Class ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	tagging := asn1dynamic.ExplicitTags
+	if *implicit {
+		tagging = asn1dynamic.ImplicitTags
+	}
+	if err := run(flag.Arg(0), flag.Args()[1:], *pkg, *out, tagging); err != nil {
+		fmt.Fprintf(os.Stderr, "asn1gen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string, classes []string, pkg, out string, tagging asn1dynamic.Tagging) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var sheme *asn1dynamic.Sheme
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".asn" || ext == ".asn1" {
+		sheme, err = asn1dynamic.NewShemeASN1(data)
+	} else {
+		sheme, err = asn1dynamic.NewSheme(data)
+	}
+	if err != nil {
+		return err
+	}
+	src, err := asn1dynamic.GenerateGo(sheme, pkg, tagging, classes...)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(out, src, 0644)
+}
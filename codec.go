@@ -0,0 +1,185 @@
+package asn1dynamic
+
+import (
+	"io"
+	"os"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+// Tagging tells how a tag that a sheme marks neither '$implicit' nor
+// '$explicit' is applied in a module without '$tagging'. A tagged CHOICE or
+// ANY is explicit in any case.
+type Tagging int
+
+const (
+	ExplicitTags Tagging = iota
+	ImplicitTags
+)
+
+// Options of a Codec.
+type Options struct {
+	// Tagging applies to the tags not marked '$implicit' or '$explicit'.
+	Tagging Tagging
+	// Strict decodes by the Distinguished Encoding Rules only, see
+	// DecodeDER, and encodes in DER.
+	Strict bool
+	// Debug receives the trace of parsing, decoding and building the
+	// elements to encode when not nil.
+	Debug io.Writer
+	// MaxLength limits the size of an encoding to decode and MaxDepth the
+	// nesting of its elements. Zero leaves them unlimited.
+	MaxLength int
+	MaxDepth  int
+}
+
+// defaultOptions apply where no Codec is used: to the methods of AsnData and
+// Sheme and to the package functions. Only the deprecated ImplicitMode,
+// ExplicitMode and Debug change them.
+var defaultOptions Options
+
+// ImplicitMode makes the tags not marked '$implicit' or '$explicit'
+// implicit where no Codec is used.
+//
+// Deprecated: Use a Codec with the Tagging ImplicitTags. ImplicitMode is not
+// safe to call while encoding or decoding.
+func ImplicitMode() {
+	defaultOptions.Tagging = ImplicitTags
+}
+
+// ExplicitMode makes the tags not marked '$implicit' or '$explicit'
+// explicit where no Codec is used, which is the default.
+//
+// Deprecated: Use a Codec with the Tagging ExplicitTags. ExplicitMode is not
+// safe to call while encoding or decoding.
+func ExplicitMode() {
+	defaultOptions.Tagging = ExplicitTags
+}
+
+// Debug turns the trace printed to the standard output where no Codec is
+// used on or off and reports whether it is on.
+//
+// Deprecated: Use a Codec with a Debug writer. Debug is not safe to call
+// while encoding or decoding.
+func Debug(on ...bool) bool {
+	if len(on) > 0 {
+		defaultOptions.Debug = nil
+		if on[0] {
+			defaultOptions.Debug = os.Stdout
+		}
+	}
+	return defaultOptions.Debug != nil
+}
+
+// Codec encodes and decodes by its Options. A Codec holds no state between
+// calls, so it may be used by any number of goroutines at once, as may a
+// Sheme, and Codecs with different Options do not affect each other.
+type Codec struct {
+	opt Options
+}
+
+func NewCodec(opt Options) *Codec {
+	return &Codec{opt: opt}
+}
+
+func (c *Codec) Options() Options {
+	return c.opt
+}
+
+func (c *Codec) context() *AsnContext {
+	return &AsnContext{opt: &c.opt, der: c.opt.Strict}
+}
+
+// decodeContext is the context to decode th, the data parsed, in.
+func (c *Codec) decodeContext(th *AsnData) *AsnContext {
+	ctx := c.context()
+	ctx.buf = th.fdata
+	return ctx
+}
+
+// options returns the Options a decoding or encoding runs with, those of
+// the nearest context that has them.
+func (ctx *AsnContext) options() *Options {
+	for ; ctx != nil; ctx = ctx.parent {
+		if ctx.opt != nil {
+			return ctx.opt
+		}
+	}
+	return &defaultOptions
+}
+
+// parse reads data, a single element, within the limits of the codec.
+func (c *Codec) parse(data []byte) (*AsnData, error) {
+	if c.opt.MaxLength > 0 && len(data) > c.opt.MaxLength {
+		return nil, decodeDataErr("%d bytes exceed the limit of %d", len(data), c.opt.MaxLength)
+	}
+	th := &AsnData{}
+	rest, ok, err := th.parse(data, c.context(), 1)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, decodeDataErr("truncated data")
+	}
+	if len(rest) != 0 {
+		return nil, decodeDataErr("'%s' trailing data", th.tag.typeName())
+	}
+	if c.opt.Strict {
+		if err = th.checkDER(&AsnContext{buf: th.fdata}); err != nil {
+			return nil, err
+		}
+	}
+	return th, nil
+}
+
+// Decode parses data, a single element, and decodes it by sheme.
+func (c *Codec) Decode(sheme *Sheme, data []byte) (*simplejson.Json, error) {
+	th, err := c.parse(data)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := th.decode(sheme, c.decodeContext(th))
+	if err != nil {
+		return nil, err
+	}
+	return simplejson.Wrap(ret), nil
+}
+
+// DecodeInto parses data, a single element, and decodes it by sheme into
+// the Go value v points to, as AsnData.DecodeInto does.
+func (c *Codec) DecodeInto(sheme *Sheme, data []byte, v interface{}) error {
+	th, err := c.parse(data)
+	if err != nil {
+		return err
+	}
+	return th.decodeInto(sheme, v, c.decodeContext(th))
+}
+
+// Encode encodes val as the package function Encode does.
+func (c *Codec) Encode(sheme *Sheme, val interface{}) ([]byte, error) {
+	if js, ok := val.(*simplejson.Json); ok {
+		val = js.Interface()
+	}
+	el, err := build(sheme, val, c.context())
+	if err != nil {
+		return nil, err
+	}
+	return c.encode(el)
+}
+
+// EncodeStruct encodes the Go value v as the package function EncodeStruct
+// does.
+func (c *Codec) EncodeStruct(sheme *Sheme, v interface{}) ([]byte, error) {
+	val, err := extractValue(sheme, v)
+	if err != nil {
+		return nil, err
+	}
+	return c.Encode(sheme, val)
+}
+
+func (c *Codec) encode(el *AsnData) ([]byte, error) {
+	if c.opt.Strict {
+		return el.EncodeDER()
+	}
+	return el.Encode()
+}
@@ -0,0 +1,268 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+func TestCodecConcurrent(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"a":{"$id":0,"$type":"INTEGER","$tag":0},
+		"b":{"$id":1,"$type":"SEQUENCE","$tag":1,"$field":{"c":{"$id":0,"$type":"BOOLEAN","$tag":2}}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	val := map[string]interface{}{"a": 5, "b": map[string]interface{}{"c": true}}
+	codecs := []struct {
+		tagging Tagging
+		trace   bytes.Buffer
+		want    string
+	}{
+		{tagging: ExplicitTags, want: "300e" + "a003020105" + "a1073005a2030101ff"},
+		{tagging: ImplicitTags, want: "3008" + "800105" + "a1038201ff"},
+	}
+
+	var wg sync.WaitGroup
+	for i := range codecs {
+		tc := &codecs[i]
+		c := NewCodec(Options{Tagging: tc.tagging, Debug: &tc.trace})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				out, err := c.Encode(cls, val)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got := hex.EncodeToString(out); got != tc.want {
+					t.Errorf("tagging %d: %s, want %s", tc.tagging, got, tc.want)
+					return
+				}
+				js, err := c.Decode(cls, out)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if js.Get("b").Get("c").MustBool() != true {
+					t.Errorf("tagging %d: decoded %v", tc.tagging, js.Interface())
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, tc := range codecs {
+		if !strings.Contains(tc.trace.String(), "Parse: ") {
+			t.Errorf("tagging %d: no trace", tc.tagging)
+		}
+	}
+	// a Codec leaves the package functions to the default options
+	if out, err := Encode(cls, val); err != nil || hex.EncodeToString(out) != codecs[0].want {
+		t.Errorf("Encode: %x %v, want %s", out, err, codecs[0].want)
+	}
+}
+
+func TestCodecMaxDepth(t *testing.T) {
+	// four levels of SEQUENCE around a NULL, five levels of elements
+	data, _ := hex.DecodeString("3008" + "3006" + "3004" + "3002" + "0500")
+	for _, tc := range []struct {
+		depth int
+		ok    bool
+	}{{0, true}, {5, true}, {4, false}} {
+		_, err := NewCodec(Options{MaxDepth: tc.depth}).parse(data)
+		if ok := err == nil; ok != tc.ok {
+			t.Errorf("MaxDepth %d: %v", tc.depth, err)
+		}
+	}
+	// the limit holds for the indefinite length as well
+	data, _ = hex.DecodeString("3080" + "3080" + "3080" + "0500" + "0000" + "0000" + "0000")
+	if _, err := NewCodec(Options{MaxDepth: 3}).parse(data); err == nil {
+		t.Error("MaxDepth 3: parsed 4 levels")
+	}
+}
+
+func TestDeprecatedModes(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"a":{"$id":0,"$type":"INTEGER","$tag":0},
+		"b":{"$id":1,"$type":"SEQUENCE","$tag":1,"$field":{"c":{"$id":0,"$type":"BOOLEAN","$tag":2}}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	val := map[string]interface{}{"a": 5, "b": map[string]interface{}{"c": true}}
+	const (
+		explicitWant = "300e" + "a003020105" + "a1073005a2030101ff"
+		implicitWant = "3008" + "800105" + "a1038201ff"
+	)
+	defer ExplicitMode()
+	defer Debug(false)
+
+	for _, tc := range []struct {
+		mode func()
+		want string
+	}{{ImplicitMode, implicitWant}, {ExplicitMode, explicitWant}} {
+		tc.mode()
+		out, err := Encode(cls, val)
+		if err != nil || hex.EncodeToString(out) != tc.want {
+			t.Errorf("Encode: %x %v, want %s", out, err, tc.want)
+			continue
+		}
+		dec := NewDecoder()
+		if _, _, err := dec.Parse(out); err != nil {
+			t.Fatal(err)
+		}
+		js, err := dec.Decode(cls)
+		if err != nil || js.Get("b").Get("c").MustBool() != true {
+			t.Errorf("Decode %s: %v %v", tc.want, js, err)
+		}
+	}
+
+	// the Options of a Codec win over the default ones
+	ImplicitMode()
+	if out, err := NewCodec(Options{}).Encode(cls, val); err != nil || hex.EncodeToString(out) != explicitWant {
+		t.Errorf("Codec: %x %v, want %s", out, err, explicitWant)
+	}
+	ExplicitMode()
+	if out, err := NewCodec(Options{Tagging: ImplicitTags}).Encode(cls, val); err != nil || hex.EncodeToString(out) != implicitWant {
+		t.Errorf("Codec: %x %v, want %s", out, err, implicitWant)
+	}
+
+	if Debug() || !Debug(true) || !Debug() || Debug(false) {
+		t.Error("Debug does not turn the trace on and off")
+	}
+}
+
+func TestParseIndefinite(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"a":{"$id":0,"$type":"INTEGER"},
+		"b":{"$id":1,"$type":"SEQUENCE","$field":{"c":{"$id":0,"$type":"BOOLEAN"}}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		in    string
+		outer string // the element parsed, empty where it does not parse
+		inner string // its second element
+	}{
+		{"3080" + "020105" + "30800101ff0000" + "0000" + "0500",
+			"3080" + "020105" + "30800101ff0000" + "0000", "30800101ff0000"},
+		{"3080" + "020105" + "30030101ff" + "0000",
+			"3080" + "020105" + "30030101ff" + "0000", "30030101ff"},
+		{"300a" + "020105" + "30800101ff0000" + "0500",
+			"300a" + "020105" + "30800101ff0000", "30800101ff0000"},
+		// truncated before the end-of-contents octets of either level
+		{"3080" + "020105" + "30800101ff0000", "", ""},
+		{"3080" + "020105" + "30800101ff" + "0000", "", ""},
+		{"3080" + "020105" + "30800101ff00", "", ""},
+		{"3080" + "0201", "", ""},
+		// a primitive element has a definite length
+		{"3080" + "028001050000" + "0000", "", ""},
+	} {
+		data, _ := hex.DecodeString(tc.in)
+		th := &AsnData{}
+		rest, ok, err := th.Parse(data)
+		if tc.outer == "" {
+			if ok && err == nil {
+				t.Errorf("%s: parsed %x", tc.in, th.fdata)
+			}
+			continue
+		}
+		if !ok || err != nil {
+			t.Errorf("%s: %t %v", tc.in, ok, err)
+			continue
+		}
+		if got := hex.EncodeToString(th.fdata); got != tc.outer || len(th.sub) != 2 ||
+			hex.EncodeToString(th.sub[1].fdata) != tc.inner || len(rest) != len(data)-len(th.fdata) {
+			t.Errorf("%s: parsed %s, rest %x", tc.in, got, rest)
+			continue
+		}
+		// the contents leave out the end-of-contents octets
+		if got := hex.EncodeToString(th.sub[1].data); got != "0101ff" {
+			t.Errorf("%s: contents %s", tc.in, got)
+		}
+		js, err := th.Decode(sh.Class("T"))
+		if err != nil || js.Get("a").MustInt() != 5 || !js.Get("b").Get("c").MustBool() {
+			t.Errorf("%s: decoded %v %v", tc.in, js, err)
+		}
+	}
+}
+
+func TestEncodeValues(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(`V DEFINITIONS IMPLICIT TAGS ::= BEGIN
+T ::= SEQUENCE {
+  i INTEGER,
+  e ENUMERATED { a, b },
+  r REAL,
+  o OCTET STRING,
+  b BIT STRING,
+  id OBJECT IDENTIFIER,
+  t GeneralizedTime,
+  s UTF8String,
+  c CHOICE { x [0] INTEGER, y [1] BOOLEAN },
+  l SEQUENCE OF INTEGER,
+  n NULL OPTIONAL
+}
+END`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	const want = "3039" + "020107" + "0a0101" + "0903800103" + "0402aabb" + "030206c0" + "06022a03" +
+		"180f32303230303130323033303430355a" + "0c026869" + "8101ff" + "3006020101020102" + "0500"
+	for _, tc := range []struct {
+		name string
+		val  string // the value as JSON, empty for the one of want
+		ok   bool
+	}{
+		// numbers and strings are converted to the type of the sheme
+		{"json", `{"i":7,"e":"b","r":6,"o":"qrs=","b":{"Bytes":"wA==","BitLength":2},"id":[1,2,3],
+			"t":"2020-01-02T03:04:05Z","s":"hi","c":{"y":true},"l":[1,2],"n":null}`, true},
+		{"strings", `{"i":"7","e":"b","r":"6.0","o":"qrs=","b":{"Bytes":"wA==","BitLength":2},"id":"1.2.3",
+			"t":"2020-01-02T03:04:05Z","s":"hi","c":{"y":true},"l":["1",2],"n":null}`, true},
+		{"integer", `{"i":"seven","e":"b","r":6,"o":"","b":{},"id":"1.2","t":"2020-01-02T03:04:05Z","s":"","c":{"x":1},"l":[]}`, false},
+		{"item", `{"i":7,"e":"c","r":6,"o":"","b":{},"id":"1.2","t":"2020-01-02T03:04:05Z","s":"","c":{"x":1},"l":[]}`, false},
+		{"alternatives", `{"i":7,"e":"b","r":6,"o":"","b":{},"id":"1.2","t":"2020-01-02T03:04:05Z","s":"","c":{"x":1,"y":true},"l":[]}`, false},
+		{"missing", `{"i":7,"e":"b","r":6,"o":"","b":{},"id":"1.2","t":"2020-01-02T03:04:05Z","s":"","l":[]}`, false},
+	} {
+		js, err := simplejson.NewJson([]byte(tc.val))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := Encode(cls, js)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("%s: encoded %x", tc.name, out)
+			}
+			continue
+		}
+		if err != nil || hex.EncodeToString(out) != want {
+			t.Errorf("%s: encoded %x %v, want %s", tc.name, out, err, want)
+		}
+		// a NULL has no contents octets, which DER holds to
+		if _, err := NewCodec(Options{Strict: true}).Decode(cls, out); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+
+	// what Decode returns, edited, is encoded again
+	data, _ := hex.DecodeString(want)
+	js, err := NewCodec(Options{}).Decode(cls, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js.Set("i", 8)
+	js.Set("l", []interface{}{1, 3})
+	out, err := Encode(cls, js)
+	edited := strings.Replace(strings.Replace(want, "020107", "020108", 1), "020102", "020103", 1)
+	if err != nil || hex.EncodeToString(out) != edited {
+		t.Errorf("edited: encoded %x %v, want %s", out, err, edited)
+	}
+}
@@ -0,0 +1,86 @@
+package asn1dynamic
+
+import (
+	"strings"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+// DecodeDER decodes th like Decode, but rejects every encoding that is not
+// the one of the Distinguished Encoding Rules: indefinite or non-minimal
+// lengths, constructed strings, encoded DEFAULT values, unsorted SET and SET
+// OF, non-canonical times and REAL values. An error names the path of fields
+// to the offending element and its offset within th.
+func (th *AsnData) DecodeDER(sheme *Sheme) (*simplejson.Json, error) {
+	ctx := &AsnContext{der: true, buf: th.fdata}
+	if err := th.checkDER(ctx); err != nil {
+		return nil, err
+	}
+	ret, err := th.decode(sheme, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return simplejson.Wrap(ret), nil
+}
+
+// checkDER verifies the length octets of th and its elements.
+func (th *AsnData) checkDER(ctx *AsnContext) error {
+	var tag AsnTag
+	pos, err := tag.parse(th.fdata)
+	if err != nil {
+		return err
+	}
+	if l := th.fdata[pos]; l == 0x80 {
+		return ctx.locate(th, decodeDataErr("'%s' indefinite length in DER", th.tag.typeName()))
+	} else if l&0x80 != 0 && (th.fdata[pos+1] == 0 || th.len < 128) {
+		return ctx.locate(th, decodeDataErr("'%s' length not minimally-encoded", th.tag.typeName()))
+	}
+	ctxn := &AsnContext{parent: ctx, tag: th}
+	for _, sub := range th.sub {
+		if err := sub.checkDER(ctxn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locate adds to err, found in th, where th is: the path of the fields that
+// lead to it and its offset within the parsed data.
+func (ctx *AsnContext) locate(th *AsnData, err error) error {
+	path := []string{elementName(th, th.sheme)}
+	for c := ctx; c != nil; c = c.parent {
+		if c.tag != nil {
+			path = append(path, elementName(c.tag, c.sheme))
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	if off := ctx.offset(th); off >= 0 {
+		return Errorf("%w in '%s' at offset %d", err, strings.Join(path, "."), off)
+	}
+	return Errorf("%w in '%s'", err, strings.Join(path, "."))
+}
+
+// elementName names th in the path of an error: by the field of sheme or,
+// before it is decoded, by its tag.
+func elementName(th *AsnData, sheme *Sheme) string {
+	if name := sheme.Name(); name != "" {
+		return name
+	}
+	return th.tag.typeName()
+}
+
+// offset returns the offset of th within the parsed data, or -1 where the
+// data is not known or does not hold th.
+func (ctx *AsnContext) offset(th *AsnData) int {
+	for ; ctx != nil; ctx = ctx.parent {
+		if ctx.buf != nil {
+			if off := cap(ctx.buf) - cap(th.fdata); th.fdata != nil && off >= 0 && off < len(ctx.buf) {
+				return off
+			}
+			return -1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,137 @@
+package asn1dynamic
+
+import (
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// EncodeDER encodes th in the Distinguished Encoding Rules (X.690 clause 10
+// and 11). Definite minimal lengths and the order of SET components are
+// common to every encoding; canonical brings the values to their DER form
+// beforehand.
+func (th *AsnData) EncodeDER() ([]byte, error) {
+	if err := th.canonical(); err != nil {
+		return nil, err
+	}
+	return th.Encode()
+}
+
+// element skips the tag wrappers added by preprocess.
+func (th *AsnData) element() *AsnData {
+	for th.sheme == nil && len(th.sub) == 1 && th.sub[0] != nil {
+		th = th.sub[0]
+	}
+	return th
+}
+
+// value decodes a primitive element built by the Sheme methods. A copy is
+// decoded since decode marks the tag of th anew; its length is only known
+// after preprocess. A time is the one the element was built from.
+func (th *AsnData) value() (interface{}, error) {
+	if th.tm != nil {
+		return *th.tm, nil
+	}
+	tmp := *th
+	tmp.len = len(tmp.data)
+	return tmp.decode(th.sheme, &AsnContext{})
+}
+
+// isDefault reports whether el holds the DEFAULT value of the field sh.
+func isDefault(el *AsnData, sh *Sheme) bool {
+	def := sh.DefAttr()
+	if def == nil {
+		return false
+	}
+	el = el.element()
+	if el.sheme == nil || el.tag.tagConstructed {
+		return false
+	}
+	val, err := el.value()
+	if err != nil {
+		return false
+	}
+	return equalDefault(val, sh)
+}
+
+// equalDefault compares a decoded value with the DEFAULT of sh. The
+// default comes from JSON, so it is built by the type of sh and decoded
+// back to compare values of the same Go type.
+func equalDefault(val interface{}, sh *Sheme) bool {
+	def := sh.DefAttr()
+	if def == nil {
+		return false
+	}
+	el, err := build(sh, def, &AsnContext{})
+	if err != nil || el.tag.tagConstructed {
+		return false
+	}
+	if def, err = el.value(); err != nil {
+		return false
+	}
+	switch v := val.(type) {
+	case time.Time:
+		d, ok := def.(time.Time)
+		return ok && v.Equal(d)
+	case *big.Int:
+		d, ok := def.(*big.Int)
+		return ok && v.Cmp(d) == 0
+	}
+	return reflect.DeepEqual(val, def)
+}
+
+func (th *AsnData) canonical() error {
+	if th.sheme == nil {
+		for _, sub := range th.sub {
+			if sub != nil {
+				if err := sub.canonical(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	switch th.sheme.TypeEn() {
+	case tagSEQUENCE, tagSET:
+		if !th.sheme.isOf() {
+			// 11.5: a value equal to its DEFAULT is omitted
+			fld := th.sheme.FieldList()
+			for i, sub := range th.sub {
+				if sub == nil {
+					continue
+				}
+				if sh := fld.FindID(i); sh != nil && isDefault(sub, sh) {
+					th.sub[i] = nil
+				}
+			}
+		}
+	case tagUTCTime:
+		// 11.8: YYMMDDHHMMSSZ
+		val, err := th.value()
+		if err != nil {
+			return err
+		}
+		tm := val.(time.Time).UTC()
+		if tm.Year() < 1950 || tm.Year() >= 2050 {
+			return encodeDataErr("'%s' %s out of range value: %s", th.sheme.Name(), th.sheme.Type(), tm)
+		}
+		th.data = []byte(tm.Format("060102150405Z"))
+	case tagGeneralizedTime:
+		// 11.7: YYYYMMDDHHMMSS[.f]Z without trailing zeros
+		val, err := th.value()
+		if err != nil {
+			return err
+		}
+		th.data = []byte(val.(time.Time).UTC().Format("20060102150405.999999999Z"))
+	}
+
+	for _, sub := range th.sub {
+		if sub != nil {
+			if err := sub.canonical(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
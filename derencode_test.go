@@ -0,0 +1,210 @@
+package asn1dynamic
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDERTime(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"u":{"$id":0,"$type":"UTCTime","$format":"0601021504Z0700"},
+		"g":{"$id":1,"$type":"GeneralizedTime"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC)
+	out, err := NewCodec(Options{Strict: true}).Encode(sh.Class("T"), map[string]interface{}{"u": tm, "g": tm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the seconds and their fraction are kept whatever the '$format'
+	const want = "3022" + "170d3230303130323033303430355a" + "181132303230303130323033303430352e355a"
+	if got := hex.EncodeToString(out); got != want {
+		t.Errorf("encoded %s, want %s", got, want)
+	}
+}
+
+func TestDERDefault(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"o":{"$id":0,"$type":"OCTET_STRING","$default":"AAE="},
+		"b":{"$id":1,"$type":"BIT_STRING","$default":{"Bytes":"gA==","BitLength":1}},
+		"i":{"$id":2,"$type":"ObjectIdentifier","$default":"1.2.3"},
+		"t":{"$id":3,"$type":"GeneralizedTime","$default":"2020-01-02T03:04:05Z"},
+		"n":{"$id":4,"$type":"INTEGER","$default":7}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	c := NewCodec(Options{Strict: true})
+	out, err := c.Encode(cls, map[string]interface{}{
+		"o": []byte{0, 1},
+		"b": BitStr{Bytes: []byte{0x80}, BitLength: 1},
+		"i": OID{1, 2, 3},
+		"t": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"n": 7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(out); got != "3000" {
+		t.Errorf("encoded %s, want the DEFAULT values omitted", got)
+	}
+
+	// an encoded DEFAULT value is not DER
+	for _, in := range []string{"300404020001", "300403020780", "300406022a03", "3011180f32303230303130323033303430355a"} {
+		data, _ := hex.DecodeString(in)
+		if _, err := c.Decode(cls, data); err == nil {
+			t.Errorf("%s decoded in DER", in)
+		}
+	}
+}
+
+func TestDERErrorOffset(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fld  string
+		in   string // the encoding of f, at offset 5
+		path string
+		off  int
+	}{
+		{"length", `{"$type":"INTEGER"}`, "02810105", "SEQUENCE.INTEGER", 5},
+		{"integer", `{"$type":"INTEGER"}`, "02020001", "T.f", 5},
+		{"default", `{"$type":"INTEGER","$default":7}`, "020107", "T.f", 5},
+		{"set", `{"$type":"SET","$field":{"x":{"$id":0,"$type":"INTEGER","$tag":0},"y":{"$id":1,"$type":"INTEGER","$tag":1}}}`,
+			"310a" + "a103020101" + "a003020100", "T.f.[0]", 12},
+		{"set of", `{"$type":"SET","$of":{"$type":"INTEGER"}}`, "3106" + "020102" + "020101", "T.f.INTEGER", 10},
+		{"constructed string", `{"$type":"OCTET_STRING"}`, "2405" + "0403010203", "T.f", 5},
+		{"time", `{"$type":"UTCTime"}`, "170b" + hex.EncodeToString([]byte("2001020304Z")), "T.f", 5},
+		{"real", `{"$type":"REAL"}`, "0903800002", "T.f", 5},
+	} {
+		sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+			"pad":{"$id":0,"$type":"INTEGER"},"f":` + tc.fld[:len(tc.fld)-1] + `,"$id":1}}}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := hex.DecodeString("30" + fmt.Sprintf("%02x", 3+len(tc.in)/2) + "020100" + tc.in)
+		want := fmt.Sprintf("in '%s' at offset %d", tc.path, tc.off)
+
+		_, err = NewCodec(Options{Strict: true}).Decode(sh.Class("T"), data)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: Codec: %v, want %s", tc.name, err, want)
+		}
+		dec := NewDecoder()
+		if _, _, err := dec.Parse(data); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = dec.DecodeDER(sh.Class("T")); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: DecodeDER: %v, want %s", tc.name, err, want)
+		}
+	}
+}
+
+func TestDERRejections(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		tp   string
+		in   string // a BER encoding that is not DER
+		der  string // the DER encoding of the same value, if comparable
+	}{
+		{"indefinite length", `{"$type":"SEQUENCE","$field":{"a":{"$id":0,"$type":"INTEGER"}}}`, "3080" + "020101" + "0000", "3003020101"},
+		{"long length", `{"$type":"INTEGER"}`, "02810101", "020101"},
+		{"long length zero", `{"$type":"OCTET_STRING"}`, "0482000101", "040101"},
+		{"null length", `{"$type":"NULL"}`, "050100", "0500"},
+		{"constructed string", `{"$type":"OCTET_STRING"}`, "2403" + "040101", "040101"},
+		{"utc offset", `{"$type":"UTCTime"}`, "1711" + hex.EncodeToString([]byte("200102030405+0100")), ""},
+		{"utc seconds", `{"$type":"UTCTime"}`, "170b" + hex.EncodeToString([]byte("2001020304Z")), "170d" + hex.EncodeToString([]byte("200102030400Z"))},
+		{"generalized offset", `{"$type":"GeneralizedTime"}`, "1813" + hex.EncodeToString([]byte("20200102030405+0100")), ""},
+		{"real mantissa", `{"$type":"REAL"}`, "0903800002", "0903800101"},
+		{"real exponent", `{"$type":"REAL"}`, "090481000101", "0903800101"},
+		{"default", `{"$type":"SEQUENCE","$field":{"a":{"$id":0,"$type":"BOOLEAN","$default":false}}}`, "3003010100", "3000"},
+		{"set order", `{"$type":"SET","$field":{"a":{"$id":0,"$type":"INTEGER","$tag":0,"$implicit":true},"b":{"$id":1,"$type":"BOOLEAN","$tag":1,"$implicit":true}}}`,
+			"3106" + "8101ff" + "800101", "3106" + "800101" + "8101ff"},
+		{"set of order", `{"$type":"SET","$of":{"$type":"INTEGER"}}`, "3106" + "020102" + "020101", ""},
+	} {
+		sh, err := NewSheme([]byte(`{"T":` + tc.tp + `}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cls := sh.Class("T")
+		in, _ := hex.DecodeString(tc.in)
+		ber, err := NewCodec(Options{}).Decode(cls, in)
+		if err != nil {
+			t.Errorf("%s: BER: %v", tc.name, err)
+			continue
+		}
+		if _, err := NewCodec(Options{Strict: true}).Decode(cls, in); err == nil {
+			t.Errorf("%s: %s decoded in DER", tc.name, tc.in)
+		}
+		if tc.der == "" {
+			continue
+		}
+		// the value is taken in DER in its DER encoding
+		der, _ := hex.DecodeString(tc.der)
+		js, err := NewCodec(Options{Strict: true}).Decode(cls, der)
+		if err != nil || fmt.Sprint(js.Interface()) != fmt.Sprint(ber.Interface()) {
+			t.Errorf("%s: DER: %v %v, want %v", tc.name, js, err, ber.Interface())
+		}
+	}
+}
+
+func TestCERSegments(t *testing.T) {
+	sh, err := NewSheme([]byte(`{
+		"O":{"$type":"OCTET_STRING"},
+		"B":{"$type":"BIT_STRING"},
+		"S":{"$type":"IA5String"},
+		"Q":{"$type":"SEQUENCE","$field":{"o":{"$id":0,"$type":"OCTET_STRING"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	octets := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		return b
+	}
+	// tlv is the hex of a primitive element of a short or two octet length
+	tlv := func(tag byte, c []byte) string {
+		if len(c) < 128 {
+			return hex.EncodeToString(append([]byte{tag, byte(len(c))}, c...))
+		}
+		return hex.EncodeToString(append([]byte{tag, 0x82, byte(len(c) >> 8), byte(len(c))}, c...))
+	}
+	long := octets(2500)
+	bits := BitStr{Bytes: octets(2000), BitLength: 2000*8 - 3}
+	bits.Bytes[1999] = 0xf8
+	for _, tc := range []struct {
+		cls  string
+		val  interface{}
+		want string
+	}{
+		{"O", octets(1000), tlv(0x04, octets(1000))},
+		// longer strings are sent in segments of 1000 octets
+		{"O", long, "2480" + tlv(0x04, long[:1000]) + tlv(0x04, long[1000:2000]) + tlv(0x04, long[2000:]) + "0000"},
+		{"O", octets(1001), "2480" + tlv(0x04, octets(1000)) + tlv(0x04, octets(1001)[1000:]) + "0000"},
+		// those of a character string are OCTET STRING
+		{"S", strings.Repeat("a", 1001), "3680" + tlv(0x04, []byte(strings.Repeat("a", 1000))) + tlv(0x04, []byte("a")) + "0000"},
+		// those of a BIT STRING take the unused bits in the last one only
+		{"B", bits, "2380" + tlv(0x03, append([]byte{0}, bits.Bytes[:999]...)) + tlv(0x03, append([]byte{0}, bits.Bytes[999:1998]...)) +
+			tlv(0x03, append([]byte{3}, bits.Bytes[1998:]...)) + "0000"},
+		// constructed values have the indefinite length
+		{"Q", map[string]interface{}{"o": []byte{0xaa}}, "3080" + "0401aa" + "0000"},
+	} {
+		el, err := build(sh.Class(tc.cls), tc.val, &AsnContext{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := el.EncodeCER()
+		if err != nil || hex.EncodeToString(out) != tc.want {
+			t.Errorf("%s: encoded %x %v, want %s", tc.cls, out, err, tc.want)
+			continue
+		}
+		js, err := NewCodec(Options{}).Decode(sh.Class(tc.cls), out)
+		if err != nil || fmt.Sprint(js.Interface()) != fmt.Sprint(tc.val) {
+			t.Errorf("%s: decoded %v %v", tc.cls, js, err)
+		}
+	}
+}
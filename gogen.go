@@ -0,0 +1,809 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const goImportPath = "github.com/anton-zolotarev/asn1dynamic"
+
+type goClass struct {
+	owner *Sheme
+	name  string
+}
+
+// goGen writes the Go source made by GenerateGo. Every class becomes a Go
+// type; inline SEQUENCE, SET, CHOICE and ENUMERATED types are named after
+// the type and the field they belong to.
+type goGen struct {
+	tagging Tagging
+	decl    bytes.Buffer
+	types   map[string]bool
+	classes map[goClass]string
+	queue   []goClass
+	imports map[string]bool
+}
+
+// GenerateGo writes Go types for the classes of sheme, all of them when none
+// is named, along with the classes they refer to. Each class type has the
+// MarshalBER and UnmarshalBER methods, which call the primitives of the
+// package (see Element) instead of walking the sheme.
+//
+// INTEGER with $big is a big.Int, ENUMERATED an int64 type with a constant
+// for each item, CHOICE a struct with a pointer for each alternative and
+// ANY the raw element of the alternative. OPTIONAL and DEFAULT fields are
+// pointers, or nil slices for OCTET STRING and ANY, as are the fields of
+// an extension addition group; a DEFAULT field of a simple type takes its
+// default value when absent.
+//
+// tagging applies to the tags the sheme marks neither '$implicit' nor
+// '$explicit', as the Tagging of Options does.
+func GenerateGo(sheme *Sheme, pkg string, tagging Tagging, classes ...string) ([]byte, error) {
+	g := &goGen{
+		tagging: tagging,
+		types:   make(map[string]bool),
+		classes: make(map[goClass]string),
+		imports: map[string]bool{goImportPath: true},
+	}
+	if len(classes) == 0 {
+		classes = sheme.Classes()
+	}
+	for _, name := range classes {
+		cls, owner, cname := sheme.lookup(name)
+		if cls == nil {
+			return nil, Errorf("generate: unknown class '%s'", name)
+		}
+		if _, err := g.class(owner, cname); err != nil {
+			return nil, err
+		}
+	}
+	for len(g.queue) > 0 {
+		cls := g.queue[0]
+		g.queue = g.queue[1:]
+		if err := g.genClass(cls); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by asn1gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	imp := make([]string, 0, len(g.imports))
+	for k := range g.imports {
+		imp = append(imp, k)
+	}
+	sort.Strings(imp)
+	for _, k := range imp {
+		fmt.Fprintf(&out, "\t%q\n", k)
+	}
+	out.WriteString(")\n")
+	out.Write(g.decl.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, Errorf("generate: %s", err)
+	}
+	return src, nil
+}
+
+// goName converts a sheme name into an exported Go identifier.
+func goName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	var out []byte
+	up := true
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isWordChar(c) || c == '_' {
+			up = true
+			continue
+		}
+		if up {
+			c = strings.ToUpper(string(c))[0]
+			up = false
+		}
+		out = append(out, c)
+	}
+	if len(out) == 0 || isDigit(out[0]) {
+		out = append([]byte("X"), out...)
+	}
+	return string(out)
+}
+
+// outerTag returns the outer tag of a component as the encoder sets it:
+// whether it is tagged, the tag number and whether the tag is explicit.
+func (g *goGen) outerTag(sh *Sheme) (bool, int, bool) {
+	tmp := &AsnData{}
+	markTag(tmp, sh, g.tagging)
+	tp := sh.TypeEn()
+	return tmp.tag.tagged, tmp.tag.taggedN, tmp.tag.explicit || tp == tagANY
+}
+
+// inner returns the sheme within the outer tag of a component sh when the
+// tag is explicit and wraps the own tag of the type sh refers to, or nil.
+func (g *goGen) inner(sh *Sheme) *Sheme {
+	if tagged, _, explicit := g.outerTag(sh); !tagged || !explicit {
+		return nil
+	}
+	return sh.Inner()
+}
+
+// goTagClass returns the Go constant of the class of the tag of sh.
+func goTagClass(sh *Sheme) string {
+	switch sh.TagClass() {
+	case classApplication:
+		return "ClassApplication"
+	case classPrivate:
+		return "ClassPrivate"
+	}
+	return "ClassContextSpecific"
+}
+
+// goOptional reports whether a component may be absent from a value: it
+// is OPTIONAL, has a DEFAULT or belongs to an extension addition group,
+// which is left out as a whole.
+func goOptional(sh *Sheme) bool {
+	return sh.Optional() || sh.DefAttr() != nil || sh.Group() > 0
+}
+
+// nilable reports whether a value of sh may stand absent without a pointer.
+func nilable(sh *Sheme) bool {
+	tp := sh.TypeEn()
+	return tp == tagOCTET_STR || tp == tagANY
+}
+
+func (g *goGen) typeName(name string) (string, error) {
+	if g.types[name] {
+		return "", Errorf("generate: duplicate Go type '%s'", name)
+	}
+	g.types[name] = true
+	return name, nil
+}
+
+// class returns the Go type of a class, queued to be written.
+func (g *goGen) class(owner *Sheme, name string) (string, error) {
+	key := goClass{owner, name}
+	if tn, ok := g.classes[key]; ok {
+		return tn, nil
+	}
+	tn, err := g.typeName(goName(name))
+	if err != nil {
+		return "", err
+	}
+	g.classes[key] = tn
+	g.queue = append(g.queue, key)
+	return tn, nil
+}
+
+func (g *goGen) genClass(cls goClass) error {
+	sh := cls.owner.Class(cls.name)
+	tn := g.classes[cls]
+	if err := g.genType(tn, sh); err != nil {
+		return err
+	}
+	w := &g.decl
+	fmt.Fprintf(w, "\n// MarshalBER encodes v in BER.\nfunc (v *%s) MarshalBER() ([]byte, error) {\nvar dst []byte\n", tn)
+	if err := g.encode(w, sh, tn, "*v"); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "return dst, nil\n}\n")
+	fmt.Fprintf(w, "\n// UnmarshalBER decodes v from data, a single element of BER.\nfunc (v *%s) UnmarshalBER(data []byte) error {\n", tn)
+	fmt.Fprintf(w, "el, rest, err := asn1dynamic.ParseElement(data)\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(w, "if len(rest) != 0 {\nreturn asn1dynamic.Errorf(\"decode: invalid value. '%%s' trailing data\", %q)\n}\n", cls.name)
+	if err := g.decode(w, sh, tn, "*v"); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "return nil\n}\n")
+	return nil
+}
+
+// goType returns the Go type of the component raw, sh being its resolved
+// form, and writes an inline constructed type named name.
+func (g *goGen) goType(name string, raw, sh *Sheme) (string, error) {
+	if ref := raw.Ref(); ref != "" {
+		cls, owner, cname := raw.lookup(ref)
+		if cls == nil {
+			return "", Errorf("generate: unknown class '%s' referenced in '%s'", ref, raw.Name())
+		}
+		return g.class(owner, cname)
+	}
+	switch sh.TypeEn() {
+	case tagSEQUENCE, tagSET, tagCHOICE, tagENUMERATED:
+		tn, err := g.typeName(name)
+		if err != nil {
+			return "", err
+		}
+		return tn, g.genType(tn, sh)
+	}
+	return g.baseType(sh)
+}
+
+// baseType returns the Go type holding a value of a simple type.
+func (g *goGen) baseType(sh *Sheme) (string, error) {
+	switch tp := sh.TypeEn(); tp {
+	case tagNULL:
+		return "struct{}", nil
+	case tagBOOLEAN:
+		return "bool", nil
+	case tagINTEGER, tagENUMERATED:
+		if sh.BigAttr() {
+			g.imports["math/big"] = true
+			return "big.Int", nil
+		}
+		return "int64", nil
+	case tagREAL:
+		return "float64", nil
+	case tagBIT_STR:
+		return "asn1dynamic.BitStr", nil
+	case tagOCTET_STR, tagANY:
+		return "[]byte", nil
+	case tagOID:
+		return "asn1dynamic.OID", nil
+	case tagUTCTime, tagGeneralizedTime:
+		g.imports["time"] = true
+		return "time.Time", nil
+	case tagObjDescriptor, tagUTF8String, tagNumericString, tagPrintableString, tagTeletexString,
+		tagVideotexString, tagIA5String, tagGraphicString, tagVisibleString, tagGeneralString,
+		tagUniversalString, tagBMPString:
+		return "string", nil
+	}
+	return "", Errorf("generate: '%s' type %s not supported", sh.Name(), sh.Type())
+}
+
+// genType writes the declaration and the methods of the Go type tn for sh.
+func (g *goGen) genType(tn string, sh *Sheme) error {
+	switch sh.TypeEn() {
+	case tagSEQUENCE, tagSET:
+		if sh.OfAttr() != nil {
+			return g.genSequenceOf(tn, sh)
+		}
+		return g.genSequence(tn, sh)
+	case tagCHOICE:
+		return g.genChoice(tn, sh)
+	case tagENUMERATED:
+		return g.genEnumerated(tn, sh)
+	}
+	base, err := g.baseType(sh)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&g.decl, "\ntype %s %s\n", tn, base)
+	return nil
+}
+
+type goField struct {
+	sheme *Sheme
+	name  string
+	tn    string
+	ptr   bool
+}
+
+// x returns the expression of the value of the field within v.
+func (f *goField) x() string {
+	if f.ptr {
+		return "*v." + f.name
+	}
+	return "v." + f.name
+}
+
+func (g *goGen) fields(tn string, sh *Sheme, choice bool) ([]*goField, error) {
+	var out []*goField
+	seen := make(map[string]bool)
+	fld := sh.FieldList()
+	for f := fld.Begin(); f != nil; f = fld.Next() {
+		raw := sh.raw(sh.FieldAttr()[f.Name()].(map[string]interface{}), f.Name())
+		name := goName(f.Name())
+		if seen[name] {
+			return nil, Errorf("generate: duplicate Go field '%s' in '%s'", name, tn)
+		}
+		seen[name] = true
+		ftn, err := g.goType(tn+name, raw, f)
+		if err != nil {
+			return nil, err
+		}
+		opt := choice || goOptional(f)
+		out = append(out, &goField{sheme: f, name: name, tn: ftn, ptr: opt && !nilable(f)})
+	}
+	return out, nil
+}
+
+func (g *goGen) writeStruct(tn string, fld []*goField) {
+	var w bytes.Buffer
+	fmt.Fprintf(&w, "\ntype %s struct {\n", tn)
+	for _, f := range fld {
+		ptr := ""
+		if f.ptr {
+			ptr = "*"
+		}
+		fmt.Fprintf(&w, "%s %s%s `asn:%q`\n", f.name, ptr, f.tn, f.sheme.Name())
+	}
+	w.WriteString("}\n")
+	g.decl.Write(w.Bytes())
+}
+
+// match returns the condition of the element el carrying the outer tag of
+// sh. An untagged CHOICE takes the tags of its alternatives and an untagged
+// ANY takes any tag.
+func match(sh *Sheme, el string) string {
+	if sh.Tagged() {
+		return fmt.Sprintf("%s.Is(asn1dynamic.%s, %d)", el, goTagClass(sh), sh.Index())
+	}
+	switch tp := sh.TypeEn(); tp {
+	case tagCHOICE:
+		var alt []string
+		fld := sh.FieldList()
+		for f := fld.Begin(); f != nil; f = fld.Next() {
+			alt = append(alt, match(f, el))
+		}
+		return "(" + strings.Join(alt, " || ") + ")"
+	case tagANY:
+		return "true"
+	default:
+		return fmt.Sprintf("%s.Is(asn1dynamic.ClassUniversal, %d)", el, tp)
+	}
+}
+
+func (g *goGen) genSequence(tn string, sh *Sheme) error {
+	fld, err := g.fields(tn, sh, false)
+	if err != nil {
+		return err
+	}
+	g.writeStruct(tn, fld)
+
+	w := &g.decl
+	fmt.Fprintf(w, "\nfunc (v *%s) appendContent(dst []byte) ([]byte, error) {\n", tn)
+	for _, f := range fld {
+		opt := goOptional(f.sheme)
+		if def := g.defValue(f); def != "" {
+			// the default value is left out
+			fmt.Fprintf(w, "if v.%s != nil && *v.%s != %s {\n", f.name, f.name, def)
+		} else if opt {
+			fmt.Fprintf(w, "if v.%s != nil {\n", f.name)
+		} else {
+			w.WriteString("{\n")
+		}
+		if err = g.encode(w, f.sheme, f.tn, f.x()); err != nil {
+			return err
+		}
+		w.WriteString("}\n")
+	}
+	w.WriteString("return dst, nil\n}\n")
+
+	fmt.Fprintf(w, "\nfunc (v *%s) parseContent(data []byte) error {\n", tn)
+	w.WriteString("els, err := asn1dynamic.ParseElements(data)\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(w, "*v = %s{}\n", tn)
+	if sh.TypeEn() == tagSET {
+		err = g.parseSet(w, sh, fld)
+	} else {
+		err = g.parseSequence(w, sh, fld)
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteString("return nil\n}\n")
+	return nil
+}
+
+func (g *goGen) parseSequence(w *bytes.Buffer, sh *Sheme, fld []*goField) error {
+	w.WriteString("i := 0\n")
+	for _, f := range fld {
+		if goOptional(f.sheme) {
+			fmt.Fprintf(w, "if i < len(els) && %s {\nel := els[i]\n", match(f.sheme, "els[i]"))
+			g.alloc(w, f)
+			if err := g.decode(w, f.sheme, f.tn, f.x()); err != nil {
+				return err
+			}
+			w.WriteString("i++\n}")
+			if def := g.defValue(f); def != "" {
+				fmt.Fprintf(w, " else {\nv.%s = new(%s)\n*v.%s = %s\n}", f.name, f.tn, f.name, def)
+			}
+			w.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(w, "if i == len(els) {\nreturn asn1dynamic.Errorf(\"decode: invalid value. '%%s' miss field '%%s'\", %q, %q)\n}\n", sh.Name(), f.sheme.Name())
+		w.WriteString("{\nel := els[i]\n")
+		if err := g.decode(w, f.sheme, f.tn, f.x()); err != nil {
+			return err
+		}
+		w.WriteString("}\ni++\n")
+	}
+	if !sh.Extensible() {
+		fmt.Fprintf(w, "if i < len(els) {\nreturn asn1dynamic.Errorf(\"decode: invalid value. '%%s' unexpected field '%%s'\", %q, els[i])\n}\n", sh.Name())
+	}
+	return nil
+}
+
+func (g *goGen) parseSet(w *bytes.Buffer, sh *Sheme, fld []*goField) error {
+	// an untagged ANY takes what is left over
+	order := make([]int, 0, len(fld))
+	for i, f := range fld {
+		if match(f.sheme, "el") != "true" {
+			order = append(order, i)
+		}
+	}
+	for i, f := range fld {
+		if match(f.sheme, "el") == "true" {
+			order = append(order, i)
+		}
+	}
+
+	fmt.Fprintf(w, "var seen [%d]bool\nfor _, el := range els {\nswitch {\n", len(fld))
+	for _, i := range order {
+		f := fld[i]
+		fmt.Fprintf(w, "case %s:\n", match(f.sheme, "el"))
+		fmt.Fprintf(w, "if seen[%d] {\nreturn asn1dynamic.Errorf(\"decode: invalid value. '%%s' duplicate field '%%s'\", %q, %q)\n}\nseen[%d] = true\n", i, sh.Name(), f.sheme.Name(), i)
+		g.alloc(w, f)
+		if err := g.decode(w, f.sheme, f.tn, f.x()); err != nil {
+			return err
+		}
+	}
+	if !sh.Extensible() {
+		fmt.Fprintf(w, "default:\nreturn asn1dynamic.Errorf(\"decode: invalid value. '%%s' unexpected field '%%s'\", %q, el)\n", sh.Name())
+	}
+	w.WriteString("}\n}\n")
+	for i, f := range fld {
+		if !goOptional(f.sheme) {
+			fmt.Fprintf(w, "if !seen[%d] {\nreturn asn1dynamic.Errorf(\"decode: invalid value. '%%s' miss field '%%s'\", %q, %q)\n}\n", i, sh.Name(), f.sheme.Name())
+		} else if def := g.defValue(f); def != "" {
+			fmt.Fprintf(w, "if !seen[%d] {\nv.%s = new(%s)\n*v.%s = %s\n}\n", i, f.name, f.tn, f.name, def)
+		}
+	}
+	return nil
+}
+
+// alloc sets a pointer field before its value is decoded.
+func (g *goGen) alloc(w *bytes.Buffer, f *goField) {
+	if f.ptr {
+		fmt.Fprintf(w, "v.%s = new(%s)\n", f.name, f.tn)
+	}
+}
+
+// defValue returns the Go literal of the DEFAULT value of a field of a
+// simple type, or an empty string.
+func (g *goGen) defValue(f *goField) string {
+	def := f.sheme.DefAttr()
+	if def == nil || !f.ptr {
+		return ""
+	}
+	switch tp := f.sheme.TypeEn(); tp {
+	case tagBOOLEAN, tagREAL:
+		return fmt.Sprint(def)
+	case tagINTEGER:
+		if !f.sheme.BigAttr() {
+			return fmt.Sprint(def)
+		}
+	case tagENUMERATED:
+		if s, ok := def.(string); ok {
+			return f.tn + goName(s)
+		}
+	default:
+		if s, ok := def.(string); ok && tp != tagUTCTime && tp != tagGeneralizedTime {
+			if b, _ := g.baseType(f.sheme); b == "string" {
+				return strconv.Quote(s)
+			}
+		}
+	}
+	return ""
+}
+
+func (g *goGen) genSequenceOf(tn string, sh *Sheme) error {
+	of := sh.Of()
+	itn, err := g.goType(tn+"Item", sh.raw(sh.OfAttr(), sh.Name()), of)
+	if err != nil {
+		return err
+	}
+	w := &g.decl
+	fmt.Fprintf(w, "\ntype %s []%s\n", tn, itn)
+	fmt.Fprintf(w, "\nfunc (v *%s) appendContent(dst []byte) ([]byte, error) {\nfor i := range *v {\n", tn)
+	if err = g.encode(w, of, itn, "(*v)[i]"); err != nil {
+		return err
+	}
+	w.WriteString("}\nreturn dst, nil\n}\n")
+	fmt.Fprintf(w, "\nfunc (v *%s) parseContent(data []byte) error {\n", tn)
+	w.WriteString("els, err := asn1dynamic.ParseElements(data)\nif err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(w, "*v = make(%s, len(els))\nfor i, el := range els {\n", tn)
+	if err = g.decode(w, of, itn, "(*v)[i]"); err != nil {
+		return err
+	}
+	w.WriteString("}\nreturn nil\n}\n")
+	return nil
+}
+
+func (g *goGen) genChoice(tn string, sh *Sheme) error {
+	fld, err := g.fields(tn, sh, true)
+	if err != nil {
+		return err
+	}
+	g.writeStruct(tn, fld)
+
+	w := &g.decl
+	fmt.Fprintf(w, "\nfunc (v *%s) appendElement(dst []byte) ([]byte, error) {\nn := 0\n", tn)
+	for _, f := range fld {
+		fmt.Fprintf(w, "if v.%s != nil {\nn++\n", f.name)
+		if err = g.encode(w, f.sheme, f.tn, f.x()); err != nil {
+			return err
+		}
+		w.WriteString("}\n")
+	}
+	fmt.Fprintf(w, "if n != 1 {\nreturn nil, asn1dynamic.Errorf(\"encode: invalid value. '%%s' CHOICE takes one alternative\", %q)\n}\n", sh.Name())
+	w.WriteString("return dst, nil\n}\n")
+
+	fmt.Fprintf(w, "\nfunc (v *%s) parseElement(el asn1dynamic.Element) error {\n*v = %s{}\nswitch {\n", tn, tn)
+	for _, f := range fld {
+		fmt.Fprintf(w, "case %s:\n", match(f.sheme, "el"))
+		g.alloc(w, f)
+		if err = g.decode(w, f.sheme, f.tn, f.x()); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "default:\nreturn asn1dynamic.Errorf(\"decode: invalid value. '%%s' unknown alternative '%%s'\", %q, el)\n}\nreturn nil\n}\n", sh.Name())
+	return nil
+}
+
+func (g *goGen) genEnumerated(tn string, sh *Sheme) error {
+	enm := sh.EnumItems()
+	ids := make([]int, 0, len(enm))
+	for id := range enm {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	w := &g.decl
+	fmt.Fprintf(w, "\ntype %s int64\n\nconst (\n", tn)
+	for _, id := range ids {
+		fmt.Fprintf(w, "%s%s %s = %d\n", tn, goName(enm[id]), tn, id)
+	}
+	fmt.Fprintf(w, ")\n\nvar %sNames = map[%s]string{\n", unexported(tn), tn)
+	for _, id := range ids {
+		fmt.Fprintf(w, "%s%s: %q,\n", tn, goName(enm[id]), enm[id])
+	}
+	w.WriteString("}\n")
+	fmt.Fprintf(w, "\nfunc (v %s) String() string {\nif s, ok := %sNames[v]; ok {\nreturn s\n}\nreturn strconv.FormatInt(int64(v), 10)\n}\n", tn, unexported(tn))
+	g.imports["strconv"] = true
+	return nil
+}
+
+func unexported(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// recv returns the receiver of a method call on the value x.
+func recv(x string) string {
+	return strings.TrimPrefix(x, "*")
+}
+
+// addr returns the address of the value x.
+func addr(x string) string {
+	if strings.HasPrefix(x, "*") {
+		return x[1:]
+	}
+	return "&" + x
+}
+
+// conv returns x converted from the Go type tn to base.
+func conv(base, tn, x string) string {
+	if base == tn {
+		return x
+	}
+	return base + "(" + x + ")"
+}
+
+// constrained reports whether the value or size of sh is checked.
+func constrained(sh *Sheme) bool {
+	return !sh.Extensible() && (sh.MinAttr() > 0 || sh.MaxAttr() > 0)
+}
+
+// checkRange writes the check of the value or size n of a component
+// against its constraint.
+func (g *goGen) checkRange(w *bytes.Buffer, sh *Sheme, n string, enc bool) {
+	if !constrained(sh) {
+		return
+	}
+	var cond []string
+	if min := sh.MinAttr(); min > 0 {
+		cond = append(cond, fmt.Sprintf("%s < %d", n, min))
+	}
+	if max := sh.MaxAttr(); max > 0 {
+		cond = append(cond, fmt.Sprintf("%s > %d", n, max))
+	}
+	ret, pfx := "err", "decode"
+	if enc {
+		ret, pfx = "nil, err", "encode"
+	}
+	fmt.Fprintf(w, "if %s {\nerr := asn1dynamic.Errorf(\"%s: invalid value. '%%s' out of range: %%d\", %q, %s)\nreturn %s\n}\n",
+		strings.Join(cond, " || "), pfx, sh.Name(), n, ret)
+}
+
+// checkSize writes the check of the size of a string s of sh.
+func (g *goGen) checkSize(w *bytes.Buffer, sh *Sheme, s string, enc bool) {
+	if constrained(sh) {
+		g.checkRange(w, sh, g.size(sh, s), enc)
+	}
+}
+
+// size returns the expression of the size of a string s of sh.
+func (g *goGen) size(sh *Sheme, s string) string {
+	if tp := sh.TypeEn(); tp == tagBMPString || tp == tagUniversalString {
+		g.imports["unicode/utf8"] = true
+		return "utf8.RuneCountInString(" + s + ")"
+	}
+	return "len(" + s + ")"
+}
+
+// encode writes the statements appending the element of the value x of
+// the Go type tn, a component sh, to dst.
+func (g *goGen) encode(w *bytes.Buffer, sh *Sheme, tn, x string) error {
+	tagged, n, explicit := g.outerTag(sh)
+	cls := goTagClass(sh)
+	if in := g.inner(sh); in != nil {
+		w.WriteString("in, err := func() ([]byte, error) {\nvar dst []byte\n")
+		if err := g.encode(w, in, tn, x); err != nil {
+			return err
+		}
+		w.WriteString("return dst, nil\n}()\nif err != nil {\nreturn nil, err\n}\n")
+		fmt.Fprintf(w, "dst = asn1dynamic.AppendElement(dst, asn1dynamic.%s, %d, true, in)\n", cls, n)
+		return nil
+	}
+	tp := sh.TypeEn()
+	switch tp {
+	case tagCHOICE:
+		if !tagged {
+			fmt.Fprintf(w, "c, err := %s.appendElement(nil)\nif err != nil {\nreturn nil, err\n}\ndst = append(dst, c...)\n", recv(x))
+			return nil
+		}
+		fmt.Fprintf(w, "c, err := %s.appendElement(nil)\nif err != nil {\nreturn nil, err\n}\n", recv(x))
+		fmt.Fprintf(w, "dst = asn1dynamic.AppendElement(dst, asn1dynamic.%s, %d, true, c)\n", cls, n)
+		return nil
+	case tagANY:
+		if !tagged {
+			fmt.Fprintf(w, "dst = append(dst, %s...)\n", x)
+			return nil
+		}
+		fmt.Fprintf(w, "dst = asn1dynamic.AppendElement(dst, asn1dynamic.%s, %d, true, %s)\n", cls, n, x)
+		return nil
+	}
+
+	base, _ := g.baseType(sh)
+	c := "c"
+	switch tp {
+	case tagNULL:
+		c = "nil"
+	case tagBOOLEAN:
+		fmt.Fprintf(w, "c := asn1dynamic.AppendBoolean(nil, %s)\n", conv(base, tn, x))
+	case tagINTEGER:
+		if sh.BigAttr() {
+			fmt.Fprintf(w, "c := asn1dynamic.AppendBigInteger(nil, (*big.Int)(%s))\n", addr(x))
+			break
+		}
+		g.checkRange(w, sh, conv(base, tn, x), true)
+		fmt.Fprintf(w, "c := asn1dynamic.AppendInteger(nil, %s)\n", conv(base, tn, x))
+	case tagENUMERATED:
+		fmt.Fprintf(w, "if _, ok := %sNames[%s]; !ok {\nreturn nil, asn1dynamic.Errorf(\"encode: invalid value. '%%s' ENUMERATED wrong value: %%d\", %q, %s)\n}\n",
+			unexported(tn), x, sh.Name(), x)
+		fmt.Fprintf(w, "c := asn1dynamic.AppendInteger(nil, int64(%s))\n", x)
+	case tagREAL:
+		fmt.Fprintf(w, "c, err := asn1dynamic.AppendReal(nil, %s)\nif err != nil {\nreturn nil, err\n}\n", conv(base, tn, x))
+	case tagBIT_STR:
+		fmt.Fprintf(w, "c, err := asn1dynamic.AppendBitString(nil, %s)\nif err != nil {\nreturn nil, err\n}\n", conv(base, tn, x))
+	case tagOCTET_STR:
+		g.checkRange(w, sh, "len("+x+")", true)
+		c = conv(base, tn, x)
+	case tagOID:
+		fmt.Fprintf(w, "c, err := asn1dynamic.AppendObjectIdentifier(nil, %s)\nif err != nil {\nreturn nil, err\n}\n", conv(base, tn, x))
+	case tagUTCTime:
+		fmt.Fprintf(w, "c := asn1dynamic.AppendUTCTime(nil, %s, %q)\n", conv(base, tn, x), sh.FormatAttr())
+	case tagGeneralizedTime:
+		fmt.Fprintf(w, "c := asn1dynamic.AppendGeneralizedTime(nil, %s)\n", conv(base, tn, x))
+	case tagSEQUENCE, tagSET:
+		fmt.Fprintf(w, "c, err := %s.appendContent(nil)\nif err != nil {\nreturn nil, err\n}\n", recv(x))
+	default:
+		g.checkSize(w, sh, x, true)
+		fmt.Fprintf(w, "c, err := asn1dynamic.AppendString(nil, %d, %s)\nif err != nil {\nreturn nil, err\n}\n", tp, conv(base, tn, x))
+	}
+
+	cons := tp == tagSEQUENCE || tp == tagSET
+	switch {
+	case !tagged:
+		fmt.Fprintf(w, "dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, %d, %t, %s)\n", tp, cons, c)
+	case explicit:
+		fmt.Fprintf(w, "dst = asn1dynamic.AppendElement(dst, asn1dynamic.%s, %d, true, asn1dynamic.AppendElement(nil, asn1dynamic.ClassUniversal, %d, %t, %s))\n", cls, n, tp, cons, c)
+	default:
+		fmt.Fprintf(w, "dst = asn1dynamic.AppendElement(dst, asn1dynamic.%s, %d, %t, %s)\n", cls, n, cons, c)
+	}
+	return nil
+}
+
+// decode writes the statements decoding the element el into x, a value of
+// the Go type tn standing for a component sh.
+func (g *goGen) decode(w *bytes.Buffer, sh *Sheme, tn, x string) error {
+	tagged, n, explicit := g.outerTag(sh)
+	tp := sh.TypeEn()
+	name := strconv.Quote(sh.Name())
+	cons := tp == tagSEQUENCE || tp == tagSET
+	expect := func(class string, num int, cons bool) {
+		fn := "Expect"
+		if cons {
+			fn = "ExpectConstructed"
+		}
+		fmt.Fprintf(w, "if err := el.%s(%s, asn1dynamic.%s, %d); err != nil {\nreturn err\n}\n", fn, name, class, num)
+	}
+
+	if tagged {
+		expect(goTagClass(sh), n, explicit || cons)
+		if explicit {
+			w.WriteString("if err := el.Unwrap(); err != nil {\nreturn err\n}\n")
+		}
+	}
+	if in := g.inner(sh); in != nil {
+		return g.decode(w, in, tn, x)
+	}
+	switch tp {
+	case tagCHOICE:
+		fmt.Fprintf(w, "if err := %s.parseElement(el); err != nil {\nreturn err\n}\n", recv(x))
+		return nil
+	case tagANY:
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, "[]byte", "el.Raw"))
+		return nil
+	}
+	if !tagged || explicit {
+		expect("ClassUniversal", tp, cons)
+	}
+
+	base, _ := g.baseType(sh)
+	ret := func(call string) {
+		fmt.Fprintf(w, "b, err := %s\nif err != nil {\nreturn err\n}\n", call)
+	}
+	switch tp {
+	case tagNULL:
+	case tagBOOLEAN:
+		ret("asn1dynamic.ParseBoolean(el.Content)")
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	case tagINTEGER:
+		if sh.BigAttr() {
+			ret("asn1dynamic.ParseBigInteger(el.Content)")
+			fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "*b"))
+			break
+		}
+		ret("asn1dynamic.ParseInteger(el.Content)")
+		g.checkRange(w, sh, "b", false)
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	case tagENUMERATED:
+		ret("asn1dynamic.ParseInteger(el.Content)")
+		fmt.Fprintf(w, "%s = %s(b)\n", x, tn)
+		if !sh.Extensible() {
+			fmt.Fprintf(w, "if _, ok := %sNames[%s]; !ok {\nreturn asn1dynamic.Errorf(\"decode: invalid value. '%%s' ENUMERATED wrong value: %%d\", %s, b)\n}\n",
+				unexported(tn), x, name)
+		}
+	case tagREAL:
+		ret("asn1dynamic.ParseReal(el.Content)")
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	case tagBIT_STR:
+		fmt.Fprintf(w, "o, err := el.Octets(%d)\nif err != nil {\nreturn err\n}\n", tp)
+		ret("asn1dynamic.ParseBitString(o)")
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	case tagOCTET_STR:
+		fmt.Fprintf(w, "b, err := el.Octets(%d)\nif err != nil {\nreturn err\n}\n", tp)
+		g.checkRange(w, sh, "len(b)", false)
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	case tagOID:
+		ret("asn1dynamic.ParseObjectIdentifier(el.Content)")
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	case tagUTCTime:
+		ret(fmt.Sprintf("asn1dynamic.ParseUTCTime(el.Content, %q)", sh.FormatAttr()))
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	case tagGeneralizedTime:
+		ret("asn1dynamic.ParseGeneralizedTime(el.Content)")
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	case tagSEQUENCE, tagSET:
+		fmt.Fprintf(w, "if err := %s.parseContent(el.Content); err != nil {\nreturn err\n}\n", recv(x))
+	default:
+		fmt.Fprintf(w, "o, err := el.Octets(%d)\nif err != nil {\nreturn err\n}\n", tp)
+		ret(fmt.Sprintf("asn1dynamic.ParseString(%d, o)", tp))
+		g.checkSize(w, sh, "b", false)
+		fmt.Fprintf(w, "%s = %s\n", x, conv(tn, base, "b"))
+	}
+	return nil
+}
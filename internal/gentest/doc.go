@@ -0,0 +1,5 @@
+// Package gentest holds the Go types asn1gen writes for gentest.asn, which
+// its tests check against the dynamic codec.
+package gentest
+
+//go:generate go run ../../cmd/asn1gen -pkg gentest -o gen.go gentest.asn
@@ -0,0 +1,1247 @@
+// Code generated by asn1gen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"github.com/anton-zolotarev/asn1dynamic"
+	"strconv"
+	"time"
+)
+
+type Color int64
+
+const (
+	ColorRed   Color = 0
+	ColorBlue  Color = 1
+	ColorGreen Color = 5
+)
+
+var colorNames = map[Color]string{
+	ColorRed:   "red",
+	ColorBlue:  "blue",
+	ColorGreen: "green",
+}
+
+func (v Color) String() string {
+	if s, ok := colorNames[v]; ok {
+		return s
+	}
+	return strconv.FormatInt(int64(v), 10)
+}
+
+// MarshalBER encodes v in BER.
+func (v *Color) MarshalBER() ([]byte, error) {
+	var dst []byte
+	if _, ok := colorNames[*v]; !ok {
+		return nil, asn1dynamic.Errorf("encode: invalid value. '%s' ENUMERATED wrong value: %d", "Color", *v)
+	}
+	c := asn1dynamic.AppendInteger(nil, int64(*v))
+	dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 10, false, c)
+	return dst, nil
+}
+
+// UnmarshalBER decodes v from data, a single element of BER.
+func (v *Color) UnmarshalBER(data []byte) error {
+	el, rest, err := asn1dynamic.ParseElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' trailing data", "Color")
+	}
+	if err := el.Expect("Color", asn1dynamic.ClassUniversal, 10); err != nil {
+		return err
+	}
+	b, err := asn1dynamic.ParseInteger(el.Content)
+	if err != nil {
+		return err
+	}
+	*v = Color(b)
+	if _, ok := colorNames[*v]; !ok {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' ENUMERATED wrong value: %d", "Color", b)
+	}
+	return nil
+}
+
+type Item struct {
+	X int64   `asn:"x"`
+	Y *string `asn:"y"`
+}
+
+func (v *Item) appendContent(dst []byte) ([]byte, error) {
+	{
+		c := asn1dynamic.AppendInteger(nil, v.X)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 2, false, c)
+	}
+	if v.Y != nil {
+		c, err := asn1dynamic.AppendString(nil, 26, *v.Y)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 26, false, c)
+	}
+	return dst, nil
+}
+
+func (v *Item) parseContent(data []byte) error {
+	els, err := asn1dynamic.ParseElements(data)
+	if err != nil {
+		return err
+	}
+	*v = Item{}
+	i := 0
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Item", "x")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("x", asn1dynamic.ClassUniversal, 2); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		v.X = b
+	}
+	i++
+	if i < len(els) && els[i].Is(asn1dynamic.ClassUniversal, 26) {
+		el := els[i]
+		v.Y = new(string)
+		if err := el.Expect("y", asn1dynamic.ClassUniversal, 26); err != nil {
+			return err
+		}
+		o, err := el.Octets(26)
+		if err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseString(26, o)
+		if err != nil {
+			return err
+		}
+		*v.Y = b
+		i++
+	}
+	if i < len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' unexpected field '%s'", "Item", els[i])
+	}
+	return nil
+}
+
+// MarshalBER encodes v in BER.
+func (v *Item) MarshalBER() ([]byte, error) {
+	var dst []byte
+	c, err := v.appendContent(nil)
+	if err != nil {
+		return nil, err
+	}
+	dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 16, true, c)
+	return dst, nil
+}
+
+// UnmarshalBER decodes v from data, a single element of BER.
+func (v *Item) UnmarshalBER(data []byte) error {
+	el, rest, err := asn1dynamic.ParseElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' trailing data", "Item")
+	}
+	if err := el.ExpectConstructed("Item", asn1dynamic.ClassUniversal, 16); err != nil {
+		return err
+	}
+	if err := v.parseContent(el.Content); err != nil {
+		return err
+	}
+	return nil
+}
+
+type Label string
+
+// MarshalBER encodes v in BER.
+func (v *Label) MarshalBER() ([]byte, error) {
+	var dst []byte
+	if len(*v) < 1 || len(*v) > 10 {
+		err := asn1dynamic.Errorf("encode: invalid value. '%s' out of range: %d", "Label", len(*v))
+		return nil, err
+	}
+	c, err := asn1dynamic.AppendString(nil, 19, string(*v))
+	if err != nil {
+		return nil, err
+	}
+	dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 19, false, c)
+	return dst, nil
+}
+
+// UnmarshalBER decodes v from data, a single element of BER.
+func (v *Label) UnmarshalBER(data []byte) error {
+	el, rest, err := asn1dynamic.ParseElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' trailing data", "Label")
+	}
+	if err := el.Expect("Label", asn1dynamic.ClassUniversal, 19); err != nil {
+		return err
+	}
+	o, err := el.Octets(19)
+	if err != nil {
+		return err
+	}
+	b, err := asn1dynamic.ParseString(19, o)
+	if err != nil {
+		return err
+	}
+	if len(b) < 1 || len(b) > 10 {
+		err := asn1dynamic.Errorf("decode: invalid value. '%s' out of range: %d", "Label", len(b))
+		return err
+	}
+	*v = Label(b)
+	return nil
+}
+
+type Level int64
+
+const (
+	LevelLow  Level = 0
+	LevelHigh Level = 1
+)
+
+var levelNames = map[Level]string{
+	LevelLow:  "low",
+	LevelHigh: "high",
+}
+
+func (v Level) String() string {
+	if s, ok := levelNames[v]; ok {
+		return s
+	}
+	return strconv.FormatInt(int64(v), 10)
+}
+
+// MarshalBER encodes v in BER.
+func (v *Level) MarshalBER() ([]byte, error) {
+	var dst []byte
+	if _, ok := levelNames[*v]; !ok {
+		return nil, asn1dynamic.Errorf("encode: invalid value. '%s' ENUMERATED wrong value: %d", "Level", *v)
+	}
+	c := asn1dynamic.AppendInteger(nil, int64(*v))
+	dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 10, false, c)
+	return dst, nil
+}
+
+// UnmarshalBER decodes v from data, a single element of BER.
+func (v *Level) UnmarshalBER(data []byte) error {
+	el, rest, err := asn1dynamic.ParseElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' trailing data", "Level")
+	}
+	if err := el.Expect("Level", asn1dynamic.ClassUniversal, 10); err != nil {
+		return err
+	}
+	b, err := asn1dynamic.ParseInteger(el.Content)
+	if err != nil {
+		return err
+	}
+	*v = Level(b)
+	return nil
+}
+
+type Pick struct {
+	I *int64 `asn:"i"`
+	S *Item  `asn:"s"`
+}
+
+func (v *Pick) appendElement(dst []byte) ([]byte, error) {
+	n := 0
+	if v.I != nil {
+		n++
+		c := asn1dynamic.AppendInteger(nil, *v.I)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 5, false, c)
+	}
+	if v.S != nil {
+		n++
+		c, err := v.S.appendContent(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 6, true, c)
+	}
+	if n != 1 {
+		return nil, asn1dynamic.Errorf("encode: invalid value. '%s' CHOICE takes one alternative", "Pick")
+	}
+	return dst, nil
+}
+
+func (v *Pick) parseElement(el asn1dynamic.Element) error {
+	*v = Pick{}
+	switch {
+	case el.Is(asn1dynamic.ClassContextSpecific, 5):
+		v.I = new(int64)
+		if err := el.Expect("i", asn1dynamic.ClassContextSpecific, 5); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		*v.I = b
+	case el.Is(asn1dynamic.ClassContextSpecific, 6):
+		v.S = new(Item)
+		if err := el.ExpectConstructed("s", asn1dynamic.ClassContextSpecific, 6); err != nil {
+			return err
+		}
+		if err := v.S.parseContent(el.Content); err != nil {
+			return err
+		}
+	default:
+		return asn1dynamic.Errorf("decode: invalid value. '%s' unknown alternative '%s'", "Pick", el)
+	}
+	return nil
+}
+
+// MarshalBER encodes v in BER.
+func (v *Pick) MarshalBER() ([]byte, error) {
+	var dst []byte
+	c, err := v.appendElement(nil)
+	if err != nil {
+		return nil, err
+	}
+	dst = append(dst, c...)
+	return dst, nil
+}
+
+// UnmarshalBER decodes v from data, a single element of BER.
+func (v *Pick) UnmarshalBER(data []byte) error {
+	el, rest, err := asn1dynamic.ParseElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' trailing data", "Pick")
+	}
+	if err := v.parseElement(el); err != nil {
+		return err
+	}
+	return nil
+}
+
+type Props struct {
+	P int64  `asn:"p"`
+	Q *bool  `asn:"q"`
+	W string `asn:"w"`
+}
+
+func (v *Props) appendContent(dst []byte) ([]byte, error) {
+	{
+		c := asn1dynamic.AppendInteger(nil, v.P)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 0, false, c)
+	}
+	if v.Q != nil {
+		c := asn1dynamic.AppendBoolean(nil, *v.Q)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 1, false, c)
+	}
+	{
+		c, err := asn1dynamic.AppendString(nil, 22, v.W)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 2, false, c)
+	}
+	return dst, nil
+}
+
+func (v *Props) parseContent(data []byte) error {
+	els, err := asn1dynamic.ParseElements(data)
+	if err != nil {
+		return err
+	}
+	*v = Props{}
+	var seen [3]bool
+	for _, el := range els {
+		switch {
+		case el.Is(asn1dynamic.ClassContextSpecific, 0):
+			if seen[0] {
+				return asn1dynamic.Errorf("decode: invalid value. '%s' duplicate field '%s'", "Props", "p")
+			}
+			seen[0] = true
+			if err := el.Expect("p", asn1dynamic.ClassContextSpecific, 0); err != nil {
+				return err
+			}
+			b, err := asn1dynamic.ParseInteger(el.Content)
+			if err != nil {
+				return err
+			}
+			v.P = b
+		case el.Is(asn1dynamic.ClassContextSpecific, 1):
+			if seen[1] {
+				return asn1dynamic.Errorf("decode: invalid value. '%s' duplicate field '%s'", "Props", "q")
+			}
+			seen[1] = true
+			v.Q = new(bool)
+			if err := el.Expect("q", asn1dynamic.ClassContextSpecific, 1); err != nil {
+				return err
+			}
+			b, err := asn1dynamic.ParseBoolean(el.Content)
+			if err != nil {
+				return err
+			}
+			*v.Q = b
+		case el.Is(asn1dynamic.ClassContextSpecific, 2):
+			if seen[2] {
+				return asn1dynamic.Errorf("decode: invalid value. '%s' duplicate field '%s'", "Props", "w")
+			}
+			seen[2] = true
+			if err := el.Expect("w", asn1dynamic.ClassContextSpecific, 2); err != nil {
+				return err
+			}
+			o, err := el.Octets(22)
+			if err != nil {
+				return err
+			}
+			b, err := asn1dynamic.ParseString(22, o)
+			if err != nil {
+				return err
+			}
+			v.W = b
+		default:
+			return asn1dynamic.Errorf("decode: invalid value. '%s' unexpected field '%s'", "Props", el)
+		}
+	}
+	if !seen[0] {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Props", "p")
+	}
+	if !seen[2] {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Props", "w")
+	}
+	return nil
+}
+
+// MarshalBER encodes v in BER.
+func (v *Props) MarshalBER() ([]byte, error) {
+	var dst []byte
+	c, err := v.appendContent(nil)
+	if err != nil {
+		return nil, err
+	}
+	dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 17, true, c)
+	return dst, nil
+}
+
+// UnmarshalBER decodes v from data, a single element of BER.
+func (v *Props) UnmarshalBER(data []byte) error {
+	el, rest, err := asn1dynamic.ParseElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' trailing data", "Props")
+	}
+	if err := el.ExpectConstructed("Props", asn1dynamic.ClassUniversal, 17); err != nil {
+		return err
+	}
+	if err := v.parseContent(el.Content); err != nil {
+		return err
+	}
+	return nil
+}
+
+type RecList []Item
+
+func (v *RecList) appendContent(dst []byte) ([]byte, error) {
+	for i := range *v {
+		c, err := (*v)[i].appendContent(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 16, true, c)
+	}
+	return dst, nil
+}
+
+func (v *RecList) parseContent(data []byte) error {
+	els, err := asn1dynamic.ParseElements(data)
+	if err != nil {
+		return err
+	}
+	*v = make(RecList, len(els))
+	for i, el := range els {
+		if err := el.ExpectConstructed("list", asn1dynamic.ClassUniversal, 16); err != nil {
+			return err
+		}
+		if err := (*v)[i].parseContent(el.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type RecInts []int64
+
+func (v *RecInts) appendContent(dst []byte) ([]byte, error) {
+	for i := range *v {
+		c := asn1dynamic.AppendInteger(nil, (*v)[i])
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 2, false, c)
+	}
+	return dst, nil
+}
+
+func (v *RecInts) parseContent(data []byte) error {
+	els, err := asn1dynamic.ParseElements(data)
+	if err != nil {
+		return err
+	}
+	*v = make(RecInts, len(els))
+	for i, el := range els {
+		if err := el.Expect("ints", asn1dynamic.ClassUniversal, 2); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		(*v)[i] = b
+	}
+	return nil
+}
+
+type RecCh struct {
+	A *int64  `asn:"a"`
+	B *string `asn:"b"`
+}
+
+func (v *RecCh) appendElement(dst []byte) ([]byte, error) {
+	n := 0
+	if v.A != nil {
+		n++
+		c := asn1dynamic.AppendInteger(nil, *v.A)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 2, false, c)
+	}
+	if v.B != nil {
+		n++
+		c, err := asn1dynamic.AppendString(nil, 12, *v.B)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 12, false, c)
+	}
+	if n != 1 {
+		return nil, asn1dynamic.Errorf("encode: invalid value. '%s' CHOICE takes one alternative", "ch")
+	}
+	return dst, nil
+}
+
+func (v *RecCh) parseElement(el asn1dynamic.Element) error {
+	*v = RecCh{}
+	switch {
+	case el.Is(asn1dynamic.ClassUniversal, 2):
+		v.A = new(int64)
+		if err := el.Expect("a", asn1dynamic.ClassUniversal, 2); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		*v.A = b
+	case el.Is(asn1dynamic.ClassUniversal, 12):
+		v.B = new(string)
+		if err := el.Expect("b", asn1dynamic.ClassUniversal, 12); err != nil {
+			return err
+		}
+		o, err := el.Octets(12)
+		if err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseString(12, o)
+		if err != nil {
+			return err
+		}
+		*v.B = b
+	default:
+		return asn1dynamic.Errorf("decode: invalid value. '%s' unknown alternative '%s'", "ch", el)
+	}
+	return nil
+}
+
+type Rec struct {
+	Id    int64              `asn:"id"`
+	Flag  bool               `asn:"flag"`
+	Color Color              `asn:"color"`
+	Name  *string            `asn:"name"`
+	Data  []byte             `asn:"data"`
+	Bits  asn1dynamic.BitStr `asn:"bits"`
+	Oid   asn1dynamic.OID    `asn:"oid"`
+	When  time.Time          `asn:"when"`
+	R     float64            `asn:"r"`
+	N     struct{}           `asn:"n"`
+	List  RecList            `asn:"list"`
+	Ints  RecInts            `asn:"ints"`
+	Ch    RecCh              `asn:"ch"`
+	T1    *int64             `asn:"t1"`
+	T2    *Item              `asn:"t2"`
+	T3    Label              `asn:"t3"`
+	T4    Pick               `asn:"t4"`
+	Bmp   string             `asn:"bmp"`
+	Gt    time.Time          `asn:"gt"`
+	St    Props              `asn:"st"`
+	Dflt  *int64             `asn:"dflt"`
+	Pick  *Pick              `asn:"pick"`
+	Tkt   Ticket             `asn:"tkt"`
+	Level Level              `asn:"level"`
+}
+
+func (v *Rec) appendContent(dst []byte) ([]byte, error) {
+	{
+		if v.Id > 1000 {
+			err := asn1dynamic.Errorf("encode: invalid value. '%s' out of range: %d", "id", v.Id)
+			return nil, err
+		}
+		c := asn1dynamic.AppendInteger(nil, v.Id)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 2, false, c)
+	}
+	{
+		c := asn1dynamic.AppendBoolean(nil, v.Flag)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 1, false, c)
+	}
+	{
+		if _, ok := colorNames[v.Color]; !ok {
+			return nil, asn1dynamic.Errorf("encode: invalid value. '%s' ENUMERATED wrong value: %d", "color", v.Color)
+		}
+		c := asn1dynamic.AppendInteger(nil, int64(v.Color))
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 10, false, c)
+	}
+	if v.Name != nil {
+		c, err := asn1dynamic.AppendString(nil, 22, *v.Name)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 22, false, c)
+	}
+	{
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 4, false, v.Data)
+	}
+	{
+		c, err := asn1dynamic.AppendBitString(nil, v.Bits)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 3, false, c)
+	}
+	{
+		c, err := asn1dynamic.AppendObjectIdentifier(nil, v.Oid)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 6, false, c)
+	}
+	{
+		c := asn1dynamic.AppendUTCTime(nil, v.When, "")
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 23, false, c)
+	}
+	{
+		c, err := asn1dynamic.AppendReal(nil, v.R)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 9, false, c)
+	}
+	{
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 5, false, nil)
+	}
+	{
+		c, err := v.List.appendContent(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 16, true, c)
+	}
+	{
+		c, err := v.Ints.appendContent(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 16, true, c)
+	}
+	{
+		c, err := v.Ch.appendElement(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, c...)
+	}
+	if v.T1 != nil {
+		c := asn1dynamic.AppendInteger(nil, *v.T1)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 1, true, asn1dynamic.AppendElement(nil, asn1dynamic.ClassUniversal, 2, false, c))
+	}
+	if v.T2 != nil {
+		c, err := v.T2.appendContent(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 2, true, c)
+	}
+	{
+		if len(v.T3) < 1 || len(v.T3) > 10 {
+			err := asn1dynamic.Errorf("encode: invalid value. '%s' out of range: %d", "t3", len(v.T3))
+			return nil, err
+		}
+		c, err := asn1dynamic.AppendString(nil, 19, string(v.T3))
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 3, false, c)
+	}
+	{
+		c, err := v.T4.appendElement(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 4, true, c)
+	}
+	{
+		c, err := asn1dynamic.AppendString(nil, 30, v.Bmp)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 30, false, c)
+	}
+	{
+		c := asn1dynamic.AppendGeneralizedTime(nil, v.Gt)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 24, false, c)
+	}
+	{
+		c, err := v.St.appendContent(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 17, true, c)
+	}
+	if v.Dflt != nil && *v.Dflt != 7 {
+		c := asn1dynamic.AppendInteger(nil, *v.Dflt)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 2, false, c)
+	}
+	if v.Pick != nil {
+		c, err := v.Pick.appendElement(nil)
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, c...)
+	}
+	{
+		in, err := func() ([]byte, error) {
+			var dst []byte
+			c, err := v.Tkt.appendContent(nil)
+			if err != nil {
+				return nil, err
+			}
+			dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassApplication, 1, true, asn1dynamic.AppendElement(nil, asn1dynamic.ClassUniversal, 16, true, c))
+			return dst, nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassContextSpecific, 7, true, in)
+	}
+	{
+		if _, ok := levelNames[v.Level]; !ok {
+			return nil, asn1dynamic.Errorf("encode: invalid value. '%s' ENUMERATED wrong value: %d", "level", v.Level)
+		}
+		c := asn1dynamic.AppendInteger(nil, int64(v.Level))
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 10, false, c)
+	}
+	return dst, nil
+}
+
+func (v *Rec) parseContent(data []byte) error {
+	els, err := asn1dynamic.ParseElements(data)
+	if err != nil {
+		return err
+	}
+	*v = Rec{}
+	i := 0
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "id")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("id", asn1dynamic.ClassUniversal, 2); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		if b > 1000 {
+			err := asn1dynamic.Errorf("decode: invalid value. '%s' out of range: %d", "id", b)
+			return err
+		}
+		v.Id = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "flag")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("flag", asn1dynamic.ClassUniversal, 1); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseBoolean(el.Content)
+		if err != nil {
+			return err
+		}
+		v.Flag = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "color")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("color", asn1dynamic.ClassUniversal, 10); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		v.Color = Color(b)
+		if _, ok := colorNames[v.Color]; !ok {
+			return asn1dynamic.Errorf("decode: invalid value. '%s' ENUMERATED wrong value: %d", "color", b)
+		}
+	}
+	i++
+	if i < len(els) && els[i].Is(asn1dynamic.ClassUniversal, 22) {
+		el := els[i]
+		v.Name = new(string)
+		if err := el.Expect("name", asn1dynamic.ClassUniversal, 22); err != nil {
+			return err
+		}
+		o, err := el.Octets(22)
+		if err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseString(22, o)
+		if err != nil {
+			return err
+		}
+		*v.Name = b
+		i++
+	}
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "data")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("data", asn1dynamic.ClassUniversal, 4); err != nil {
+			return err
+		}
+		b, err := el.Octets(4)
+		if err != nil {
+			return err
+		}
+		v.Data = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "bits")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("bits", asn1dynamic.ClassUniversal, 3); err != nil {
+			return err
+		}
+		o, err := el.Octets(3)
+		if err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseBitString(o)
+		if err != nil {
+			return err
+		}
+		v.Bits = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "oid")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("oid", asn1dynamic.ClassUniversal, 6); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseObjectIdentifier(el.Content)
+		if err != nil {
+			return err
+		}
+		v.Oid = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "when")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("when", asn1dynamic.ClassUniversal, 23); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseUTCTime(el.Content, "")
+		if err != nil {
+			return err
+		}
+		v.When = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "r")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("r", asn1dynamic.ClassUniversal, 9); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseReal(el.Content)
+		if err != nil {
+			return err
+		}
+		v.R = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "n")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("n", asn1dynamic.ClassUniversal, 5); err != nil {
+			return err
+		}
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "list")
+	}
+	{
+		el := els[i]
+		if err := el.ExpectConstructed("list", asn1dynamic.ClassUniversal, 16); err != nil {
+			return err
+		}
+		if err := v.List.parseContent(el.Content); err != nil {
+			return err
+		}
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "ints")
+	}
+	{
+		el := els[i]
+		if err := el.ExpectConstructed("ints", asn1dynamic.ClassUniversal, 16); err != nil {
+			return err
+		}
+		if err := v.Ints.parseContent(el.Content); err != nil {
+			return err
+		}
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "ch")
+	}
+	{
+		el := els[i]
+		if err := v.Ch.parseElement(el); err != nil {
+			return err
+		}
+	}
+	i++
+	if i < len(els) && els[i].Is(asn1dynamic.ClassContextSpecific, 1) {
+		el := els[i]
+		v.T1 = new(int64)
+		if err := el.ExpectConstructed("t1", asn1dynamic.ClassContextSpecific, 1); err != nil {
+			return err
+		}
+		if err := el.Unwrap(); err != nil {
+			return err
+		}
+		if err := el.Expect("t1", asn1dynamic.ClassUniversal, 2); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		*v.T1 = b
+		i++
+	}
+	if i < len(els) && els[i].Is(asn1dynamic.ClassContextSpecific, 2) {
+		el := els[i]
+		v.T2 = new(Item)
+		if err := el.ExpectConstructed("t2", asn1dynamic.ClassContextSpecific, 2); err != nil {
+			return err
+		}
+		if err := v.T2.parseContent(el.Content); err != nil {
+			return err
+		}
+		i++
+	}
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "t3")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("t3", asn1dynamic.ClassContextSpecific, 3); err != nil {
+			return err
+		}
+		o, err := el.Octets(19)
+		if err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseString(19, o)
+		if err != nil {
+			return err
+		}
+		if len(b) < 1 || len(b) > 10 {
+			err := asn1dynamic.Errorf("decode: invalid value. '%s' out of range: %d", "t3", len(b))
+			return err
+		}
+		v.T3 = Label(b)
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "t4")
+	}
+	{
+		el := els[i]
+		if err := el.ExpectConstructed("t4", asn1dynamic.ClassContextSpecific, 4); err != nil {
+			return err
+		}
+		if err := el.Unwrap(); err != nil {
+			return err
+		}
+		if err := v.T4.parseElement(el); err != nil {
+			return err
+		}
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "bmp")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("bmp", asn1dynamic.ClassUniversal, 30); err != nil {
+			return err
+		}
+		o, err := el.Octets(30)
+		if err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseString(30, o)
+		if err != nil {
+			return err
+		}
+		v.Bmp = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "gt")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("gt", asn1dynamic.ClassUniversal, 24); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseGeneralizedTime(el.Content)
+		if err != nil {
+			return err
+		}
+		v.Gt = b
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "st")
+	}
+	{
+		el := els[i]
+		if err := el.ExpectConstructed("st", asn1dynamic.ClassUniversal, 17); err != nil {
+			return err
+		}
+		if err := v.St.parseContent(el.Content); err != nil {
+			return err
+		}
+	}
+	i++
+	if i < len(els) && els[i].Is(asn1dynamic.ClassUniversal, 2) {
+		el := els[i]
+		v.Dflt = new(int64)
+		if err := el.Expect("dflt", asn1dynamic.ClassUniversal, 2); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		*v.Dflt = b
+		i++
+	} else {
+		v.Dflt = new(int64)
+		*v.Dflt = 7
+	}
+	if i < len(els) && (els[i].Is(asn1dynamic.ClassContextSpecific, 5) || els[i].Is(asn1dynamic.ClassContextSpecific, 6)) {
+		el := els[i]
+		v.Pick = new(Pick)
+		if err := v.Pick.parseElement(el); err != nil {
+			return err
+		}
+		i++
+	}
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "tkt")
+	}
+	{
+		el := els[i]
+		if err := el.ExpectConstructed("tkt", asn1dynamic.ClassContextSpecific, 7); err != nil {
+			return err
+		}
+		if err := el.Unwrap(); err != nil {
+			return err
+		}
+		if err := el.ExpectConstructed("tkt", asn1dynamic.ClassApplication, 1); err != nil {
+			return err
+		}
+		if err := el.Unwrap(); err != nil {
+			return err
+		}
+		if err := el.ExpectConstructed("tkt", asn1dynamic.ClassUniversal, 16); err != nil {
+			return err
+		}
+		if err := v.Tkt.parseContent(el.Content); err != nil {
+			return err
+		}
+	}
+	i++
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Rec", "level")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("level", asn1dynamic.ClassUniversal, 10); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		v.Level = Level(b)
+	}
+	i++
+	return nil
+}
+
+// MarshalBER encodes v in BER.
+func (v *Rec) MarshalBER() ([]byte, error) {
+	var dst []byte
+	c, err := v.appendContent(nil)
+	if err != nil {
+		return nil, err
+	}
+	dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 16, true, c)
+	return dst, nil
+}
+
+// UnmarshalBER decodes v from data, a single element of BER.
+func (v *Rec) UnmarshalBER(data []byte) error {
+	el, rest, err := asn1dynamic.ParseElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' trailing data", "Rec")
+	}
+	if err := el.ExpectConstructed("Rec", asn1dynamic.ClassUniversal, 16); err != nil {
+		return err
+	}
+	if err := v.parseContent(el.Content); err != nil {
+		return err
+	}
+	return nil
+}
+
+type Ticket struct {
+	X int64 `asn:"x"`
+}
+
+func (v *Ticket) appendContent(dst []byte) ([]byte, error) {
+	{
+		c := asn1dynamic.AppendInteger(nil, v.X)
+		dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassUniversal, 2, false, c)
+	}
+	return dst, nil
+}
+
+func (v *Ticket) parseContent(data []byte) error {
+	els, err := asn1dynamic.ParseElements(data)
+	if err != nil {
+		return err
+	}
+	*v = Ticket{}
+	i := 0
+	if i == len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' miss field '%s'", "Ticket", "x")
+	}
+	{
+		el := els[i]
+		if err := el.Expect("x", asn1dynamic.ClassUniversal, 2); err != nil {
+			return err
+		}
+		b, err := asn1dynamic.ParseInteger(el.Content)
+		if err != nil {
+			return err
+		}
+		v.X = b
+	}
+	i++
+	if i < len(els) {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' unexpected field '%s'", "Ticket", els[i])
+	}
+	return nil
+}
+
+// MarshalBER encodes v in BER.
+func (v *Ticket) MarshalBER() ([]byte, error) {
+	var dst []byte
+	c, err := v.appendContent(nil)
+	if err != nil {
+		return nil, err
+	}
+	dst = asn1dynamic.AppendElement(dst, asn1dynamic.ClassApplication, 1, true, asn1dynamic.AppendElement(nil, asn1dynamic.ClassUniversal, 16, true, c))
+	return dst, nil
+}
+
+// UnmarshalBER decodes v from data, a single element of BER.
+func (v *Ticket) UnmarshalBER(data []byte) error {
+	el, rest, err := asn1dynamic.ParseElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return asn1dynamic.Errorf("decode: invalid value. '%s' trailing data", "Ticket")
+	}
+	if err := el.ExpectConstructed("Ticket", asn1dynamic.ClassApplication, 1); err != nil {
+		return err
+	}
+	if err := el.Unwrap(); err != nil {
+		return err
+	}
+	if err := el.ExpectConstructed("Ticket", asn1dynamic.ClassUniversal, 16); err != nil {
+		return err
+	}
+	if err := v.parseContent(el.Content); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package gentest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/anton-zolotarev/asn1dynamic"
+)
+
+func sheme(t *testing.T) *asn1dynamic.Sheme {
+	src, err := ioutil.ReadFile("gentest.asn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh, err := asn1dynamic.NewShemeASN1(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sh
+}
+
+func TestRoundTrip(t *testing.T) {
+	cls := sheme(t).Class("Rec")
+	c := asn1dynamic.NewCodec(asn1dynamic.Options{})
+	for _, tc := range []struct {
+		name string
+		val  string
+	}{
+		{"all", `{"id":300,"flag":true,"color":"green","name":"nm","data":"3q0=","bits":{"Bytes":"oA==","BitLength":3},
+			"oid":[1,2,840,113549],"when":"2020-01-02T03:04:00Z","r":-1.5,"n":null,
+			"list":[{"x":1},{"x":2,"y":"yy"}],"ints":[-5,6],"ch":{"b":"привет"},
+			"t1":9,"t2":{"x":3},"t3":"lbl","t4":{"s":{"x":4}},"bmp":"Ωmega","gt":"2021-05-06T07:08:09Z",
+			"st":{"w":"ww","p":1,"q":false},"dflt":8,"pick":{"i":77},"tkt":{"x":5},"level":"high"}`},
+		{"mandatory", `{"id":0,"flag":false,"color":"red","data":"","bits":{"Bytes":"","BitLength":0},"oid":[1,2],
+			"when":"2020-01-02T03:04:00Z","r":0,"n":null,"list":[],"ints":[],"ch":{"a":1},
+			"t3":"l","t4":{"i":1},"bmp":"","gt":"2021-05-06T07:08:09Z","st":{"w":"","p":0},"tkt":{"x":0},"level":"low"}`},
+	} {
+		var val interface{}
+		if err := json.Unmarshal([]byte(tc.val), &val); err != nil {
+			t.Fatal(err)
+		}
+		ber, err := c.Encode(cls, val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the generated code reads what the codec writes and writes it back
+		var r Rec
+		if err := r.UnmarshalBER(ber); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		out, err := r.MarshalBER()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(out, ber) {
+			t.Errorf("%s: encoded %x, want %x", tc.name, out, ber)
+		}
+		want, _ := c.Decode(cls, ber)
+		got, err := c.Decode(cls, out)
+		if err != nil || fmt.Sprint(got.Interface()) != fmt.Sprint(want.Interface()) {
+			t.Errorf("%s: decoded %v %v, want %v", tc.name, got, err, want)
+		}
+	}
+}
+
+func TestEnumerated(t *testing.T) {
+	for _, tc := range []struct {
+		in string
+		v  interface {
+			UnmarshalBER([]byte) error
+		}
+		ok bool
+	}{
+		{"0a0105", new(Color), true},
+		{"0a0102", new(Color), false},
+		// an extensible ENUMERATED takes the items of later versions
+		{"0a0101", new(Level), true},
+		{"0a0102", new(Level), true},
+	} {
+		data, _ := hex.DecodeString(tc.in)
+		if err := tc.v.UnmarshalBER(data); (err == nil) != tc.ok {
+			t.Errorf("%T %s: %v", tc.v, tc.in, err)
+		}
+	}
+	// neither is an unknown value of Color sent
+	if _, err := (&Rec{Color: 2}).MarshalBER(); err == nil {
+		t.Error("encoded an unknown Color")
+	}
+}
@@ -0,0 +1,55 @@
+package asn1dynamic
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func TestJERSpecialReal(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"REAL"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	for _, tc := range []struct {
+		val float64
+		jer string
+		ber string
+	}{
+		{math.Inf(1), `"INF"`, "090140"},
+		{math.Inf(-1), `"-INF"`, "090141"},
+		{math.NaN(), `"NaN"`, "090142"},
+		{math.Copysign(0, -1), `"-0"`, "090143"},
+		{0, `0`, "0900"},
+		{-1.5, `-1.5`, "0903c0ff03"},
+	} {
+		doc, err := cls.EncodeJER(tc.val)
+		if err != nil || string(doc) != tc.jer {
+			t.Errorf("%v: encoded %s %v, want %s", tc.val, doc, err, tc.jer)
+			continue
+		}
+		el, err := cls.DecodeJER(doc)
+		if err != nil {
+			t.Errorf("%s: %v", doc, err)
+			continue
+		}
+		ber, err := el.Encode()
+		if err != nil || hex.EncodeToString(ber) != tc.ber {
+			t.Errorf("%s: encoded %x %v, want %s", doc, ber, err, tc.ber)
+			continue
+		}
+		js, err := NewCodec(Options{}).Decode(cls, ber)
+		got, _ := js.Float64()
+		if err != nil || math.Float64bits(got) != math.Float64bits(tc.val) && !(math.IsNaN(got) && math.IsNaN(tc.val)) {
+			t.Errorf("%s: decoded %v %v, want %v", tc.ber, got, err, tc.val)
+		}
+	}
+
+	// the special values are spelled as X.697 does
+	for _, doc := range []string{`"Infinity"`, `"inf"`, `"+INF"`, `"nan"`, `"0"`, `null`} {
+		if _, err := cls.DecodeJER([]byte(doc)); err == nil {
+			t.Errorf("%s decoded", doc)
+		}
+	}
+}
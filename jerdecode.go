@@ -0,0 +1,243 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+)
+
+// DecodeJER reads a text of the JSON Encoding Rules into the AsnData tree
+// of sheme, ready to be encoded.
+func (sheme *Sheme) DecodeJER(data []byte) (AsnElm, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, decodeDataErr("JER %s", err)
+	}
+	if dec.More() {
+		return nil, decodeDataErr("JER trailing data")
+	}
+	return jerElement(sheme, v, &AsnContext{})
+}
+
+func jerErr(sh *Sheme, val interface{}) error {
+	return decodeDataErr("'%s' wrong %s value: %v", sh.Name(), sh.Type(), val)
+}
+
+// jerElement makes the AsnData of a JER value by sh.
+func jerElement(sh *Sheme, val interface{}, ctx *AsnContext) (AsnElm, error) {
+	ctx.debugPrint("DecodeJER: '%s' (%s)", sh.Name(), sh.Type())
+
+	switch tp := sh.TypeEn(); tp {
+	case tagANY:
+		if ctx.od == "" {
+			return nil, decodeDataErr("'%s' miss ObjectDescriptor", sh.Name())
+		}
+		alt := sh.Field(ctx.od)
+		if alt == nil {
+			return nil, decodeDataErr("'%s' unknown ObjectDescriptor %s", sh.Name(), ctx.od)
+		}
+		seq, err := sh.Any()
+		if err != nil {
+			return nil, err
+		}
+		el, err := jerElement(alt, val, &AsnContext{parent: ctx})
+		if err = seq.AnySetByName(alt.Name(), el, err); err != nil {
+			return nil, err
+		}
+		return seq, nil
+	case tagNULL:
+		if val != nil {
+			return nil, jerErr(sh, val)
+		}
+		return sh.Null()
+	case tagBOOLEAN:
+		v, ok := val.(bool)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		return sh.Boolean(v)
+	case tagINTEGER:
+		n, ok := val.(json.Number)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		v, ok := valueInt(n)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		return sh.BigInteger(v)
+	case tagENUMERATED:
+		// a value unknown to the sheme comes by its number
+		name, id, ok := sh.enumItem(val)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		if name == "" {
+			return sh.enumerated(id)
+		}
+		return sh.Enumerated(name)
+	case tagREAL:
+		switch v := val.(type) {
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				return sh.Real(f)
+			}
+		case string:
+			switch v {
+			case "INF":
+				return sh.Real(math.Inf(1))
+			case "-INF":
+				return sh.Real(math.Inf(-1))
+			case "NaN":
+				return sh.Real(math.NaN())
+			case "-0":
+				return sh.Real(math.Copysign(0, -1))
+			}
+		}
+		return nil, jerErr(sh, val)
+	case tagOCTET_STR:
+		s, ok := val.(string)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		v, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, jerErr(sh, val)
+		}
+		return sh.OctetString(v)
+	case tagBIT_STR:
+		// a BIT STRING of fixed size is a hex string, any other one holds
+		// its length as well
+		s, ok := val.(string)
+		n := oerFixedSize(sh)
+		if n < 0 {
+			obj, _ := val.(map[string]interface{})
+			s, ok = obj["value"].(string)
+			num, _ := obj["length"].(json.Number)
+			l, err := num.Int64()
+			if !ok || err != nil || len(obj) != 2 {
+				return nil, jerErr(sh, val)
+			}
+			n = int(l)
+		}
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		data, err := hex.DecodeString(s)
+		if err != nil || n < 0 || len(data) != (n+7)/8 {
+			return nil, jerErr(sh, val)
+		}
+		return sh.BitString(BitStr{Bytes: data, BitLength: n})
+	case tagOID:
+		s, ok := val.(string)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		v, ok := parseOID(s)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		return sh.ObjectIdentifier(v)
+	case tagUTCTime, tagGeneralizedTime:
+		s, ok := val.(string)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		return timeElement(sh, s, ctx)
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return jerSequenceOf(sh, val, ctx)
+		}
+		return jerSequence(sh, val, ctx)
+	case tagCHOICE:
+		obj, ok := val.(map[string]interface{})
+		if !ok || len(obj) != 1 {
+			return nil, jerErr(sh, val)
+		}
+		for k, itm := range obj {
+			alt := sh.Field(k)
+			if alt == nil {
+				return nil, decodeDataErr("'%s' unknown alternative '%s'", sh.Name(), k)
+			}
+			seq, err := sh.Choice()
+			if err != nil {
+				return nil, err
+			}
+			el, err := jerElement(alt, itm, &AsnContext{parent: ctx})
+			if err = seq.ChoiceSetByName(k, el, err); err != nil {
+				return nil, err
+			}
+			return seq, nil
+		}
+	default:
+		s, ok := val.(string)
+		if !ok {
+			return nil, jerErr(sh, val)
+		}
+		if tp == tagObjDescriptor {
+			ctx.od = s
+		}
+		return buildString(sh, tp, s)
+	}
+	return nil, jerErr(sh, val)
+}
+
+func jerSequence(sh *Sheme, val interface{}, ctx *AsnContext) (AsnElm, error) {
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, jerErr(sh, val)
+	}
+	for k := range obj {
+		if sh.Field(k) == nil {
+			return nil, decodeDataErr("'%s' unexpected field '%s'", sh.Name(), k)
+		}
+	}
+	seq, err := buildConstructed(sh)
+	if err != nil {
+		return nil, err
+	}
+	// the fields are read in the order of the sheme, an ObjectDescriptor
+	// comes before the ANY it selects
+	ctxn := &AsnContext{parent: ctx}
+	present := func(f *Sheme) bool {
+		_, ok := obj[f.Name()]
+		return ok
+	}
+	fld := sh.FieldList()
+	for f := fld.Begin(); f != nil; f = fld.Next() {
+		itm, ok := obj[f.Name()]
+		if !ok {
+			if !sh.omissible(f, present) {
+				return nil, decodeDataErr("'%s' miss not optional field '%s'", sh.Name(), f.Name())
+			}
+			continue
+		}
+		el, err := jerElement(f, itm, ctxn)
+		if err = seq.SeqFieldByName(f.Name(), el, err); err != nil {
+			return nil, err
+		}
+	}
+	return seq, nil
+}
+
+func jerSequenceOf(sh *Sheme, val interface{}, ctx *AsnContext) (AsnElm, error) {
+	arr, ok := val.([]interface{})
+	if !ok {
+		return nil, jerErr(sh, val)
+	}
+	seq, err := buildConstructed(sh)
+	if err != nil {
+		return nil, err
+	}
+	of := sh.Of()
+	for _, itm := range arr {
+		el, err := jerElement(of, itm, &AsnContext{parent: ctx})
+		if err = seq.SeqItem(el, err); err != nil {
+			return nil, err
+		}
+	}
+	return seq, nil
+}
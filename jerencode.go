@@ -0,0 +1,237 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+// jerWriter builds a JSON Encoding Rules (X.697) text, the components of a
+// SEQUENCE in the order of the sheme.
+type jerWriter struct {
+	buf bytes.Buffer
+}
+
+// EncodeJER writes val, a value shaped as the result of Decode, in the JSON
+// Encoding Rules.
+func (sheme *Sheme) EncodeJER(val interface{}) ([]byte, error) {
+	if js, ok := val.(*simplejson.Json); ok {
+		val = js.Interface()
+	}
+	w := &jerWriter{}
+	if err := w.value(sheme, val, &AsnContext{}); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+func (w *jerWriter) str(s string) {
+	enc := json.NewEncoder(&w.buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// the encoder ends the value with a newline
+	w.buf.Truncate(w.buf.Len() - 1)
+}
+
+func (w *jerWriter) value(sh *Sheme, val interface{}, ctx *AsnContext) error {
+	ctx.debugPrint("EncodeJER: '%s' (%s)", sh.Name(), sh.Type())
+
+	switch tp := sh.TypeEn(); tp {
+	case tagANY:
+		if ctx.od == "" {
+			return encodeDataErr("'%s' miss ObjectDescriptor", sh.Name())
+		}
+		alt := sh.Field(ctx.od)
+		if alt == nil {
+			return encodeDataErr("'%s' unknown ObjectDescriptor %s", sh.Name(), ctx.od)
+		}
+		return w.value(alt, val, &AsnContext{parent: ctx})
+	case tagNULL:
+		w.buf.WriteString("null")
+	case tagBOOLEAN:
+		v, ok := val.(bool)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		w.buf.WriteString(strconv.FormatBool(v))
+	case tagINTEGER:
+		v, ok := valueInt(val)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		w.buf.WriteString(v.String())
+	case tagENUMERATED:
+		v, id, ok := sh.enumItem(val)
+		if !ok {
+			return encodeDataErr("'%s' %s wrong value: '%v'", sh.Name(), sh.Type(), val)
+		}
+		if v == "" {
+			// a value unknown to the sheme is written by its number
+			w.buf.WriteString(strconv.Itoa(id))
+			break
+		}
+		w.str(v)
+	case tagREAL:
+		v, ok := valueReal(val)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		switch {
+		case math.IsInf(v, 1):
+			w.str("INF")
+		case math.IsInf(v, -1):
+			w.str("-INF")
+		case math.IsNaN(v):
+			w.str("NaN")
+		case v == 0 && math.Signbit(v):
+			w.str("-0")
+		default:
+			w.buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	case tagOCTET_STR:
+		v, ok := val.([]byte)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		w.str(strings.ToUpper(hex.EncodeToString(v)))
+	case tagBIT_STR:
+		// a BIT STRING of fixed size is a hex string, any other one holds
+		// its length as well
+		v, ok := val.(BitStr)
+		if !ok || v.BitLength < 0 || len(v.Bytes) < (v.BitLength+7)/8 {
+			return valueErr(sh, val)
+		}
+		data := strings.ToUpper(hex.EncodeToString(v.Bytes[:(v.BitLength+7)/8]))
+		if n := oerFixedSize(sh); n >= 0 {
+			if v.BitLength != n {
+				return encodeDataErr("'%s' %s size %d out of constraint", sh.Name(), sh.Type(), v.BitLength)
+			}
+			w.str(data)
+			return nil
+		}
+		w.buf.WriteString(`{"value":`)
+		w.str(data)
+		w.buf.WriteString(`,"length":` + strconv.Itoa(v.BitLength) + "}")
+	case tagOID:
+		v, ok := val.(OID)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		w.str(v.String())
+	case tagUTCTime, tagGeneralizedTime:
+		// the same characters as the BER contents
+		v, ok := val.(time.Time)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		var el AsnElm
+		var err error
+		if tp == tagUTCTime {
+			el, err = sh.UTCTime(v)
+		} else {
+			el, err = sh.GeneralizedTime(v)
+		}
+		if err != nil {
+			return err
+		}
+		w.str(string(this(el).data))
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return w.sequenceOf(sh, val, ctx)
+		}
+		return w.sequence(sh, val, ctx)
+	case tagCHOICE:
+		v, ok := val.(map[string]interface{})
+		if !ok || len(v) != 1 {
+			return valueErr(sh, val)
+		}
+		for k, itm := range v {
+			alt, err := findField(sh, k)
+			if err != nil {
+				return err
+			}
+			w.buf.WriteByte('{')
+			w.str(k)
+			w.buf.WriteByte(':')
+			if err = w.value(alt, itm, &AsnContext{parent: ctx}); err != nil {
+				return err
+			}
+			w.buf.WriteByte('}')
+		}
+	default:
+		v, ok := val.(string)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		if tp == tagObjDescriptor {
+			ctx.od = v
+		}
+		w.str(v)
+	}
+	return nil
+}
+
+func (w *jerWriter) sequence(sh *Sheme, val interface{}, ctx *AsnContext) error {
+	v, ok := val.(map[string]interface{})
+	if !ok {
+		return valueErr(sh, val)
+	}
+	for k := range v {
+		if _, err := findField(sh, k); err != nil {
+			return err
+		}
+	}
+	w.buf.WriteByte('{')
+	ctxn := &AsnContext{parent: ctx}
+	present := func(f *Sheme) bool {
+		_, ok := v[f.Name()]
+		return ok
+	}
+	fld := sh.FieldList()
+	first := true
+	for f := fld.Begin(); f != nil; f = fld.Next() {
+		itm, ok := v[f.Name()]
+		if !ok {
+			if !sh.omissible(f, present) {
+				return encodeShemeErr("'%s' miss not optional field '%s'", sh.Name(), f.Name())
+			}
+			continue
+		}
+		if !first {
+			w.buf.WriteByte(',')
+		}
+		first = false
+		w.str(f.Name())
+		w.buf.WriteByte(':')
+		if err := w.value(f, itm, ctxn); err != nil {
+			return err
+		}
+	}
+	w.buf.WriteByte('}')
+	return nil
+}
+
+func (w *jerWriter) sequenceOf(sh *Sheme, val interface{}, ctx *AsnContext) error {
+	v, ok := val.([]interface{})
+	if !ok {
+		return valueErr(sh, val)
+	}
+	of := sh.Of()
+	w.buf.WriteByte('[')
+	for i, itm := range v {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		if err := w.value(of, itm, &AsnContext{parent: ctx}); err != nil {
+			return err
+		}
+	}
+	w.buf.WriteByte(']')
+	return nil
+}
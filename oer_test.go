@@ -0,0 +1,78 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+const oerModule = `
+OER-Test DEFINITIONS IMPLICIT TAGS ::= BEGIN
+Opt ::= SEQUENCE { a BOOLEAN OPTIONAL, b INTEGER (0..255) OPTIONAL, c BOOLEAN }
+Ext ::= SEQUENCE { a INTEGER (0..255), ..., e1 BOOLEAN, e2 INTEGER (0..255) }
+Fixed ::= SEQUENCE { u16 INTEGER (0..65535), s32 INTEGER (-1..65535), u32 INTEGER (0..70000), n INTEGER (0..MAX) }
+Alt ::= CHOICE { a INTEGER (0..255), b [70] BOOLEAN, ..., c [5] UTF8String }
+Color ::= ENUMERATED { red, green, ..., blue }
+Grp ::= SEQUENCE { a INTEGER (0..255), ..., [[ b BOOLEAN, c INTEGER (0..255) OPTIONAL ]], d BOOLEAN }
+END
+`
+
+func TestOERVectors(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(oerModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		class string
+		val   interface{}
+		want  string
+	}{
+		// the preamble marks the optional fields present
+		{"Opt", map[string]interface{}{"b": 5, "c": true}, "4005ff"},
+		// the extension bit, then the bitmap of the additions as a BIT
+		// STRING and each addition as an open type
+		{"Ext", map[string]interface{}{"a": 1}, "0001"},
+		{"Ext", map[string]interface{}{"a": 1, "e2": 7}, "80010206400107"},
+		// a constrained INTEGER takes a fixed number of octets, signed if
+		// its lower bound is negative; a semi-constrained one a length
+		{"Fixed", map[string]interface{}{"u16": 300, "s32": 300, "u32": 300, "n": 300}, "012c0000012c0000012c02012c"},
+		// the tag of the alternative, the number in base 128 from 63, and
+		// an addition as an open type
+		{"Alt", map[string]interface{}{"a": 7}, "0207"},
+		{"Alt", map[string]interface{}{"b": true}, "bf46ff"},
+		{"Alt", map[string]interface{}{"c": "hi"}, "8503026869"},
+		// a group as one open type holding a SEQUENCE of its components
+		{"Grp", map[string]interface{}{"a": 1, "b": true}, "8001020680" + "0200ff"},
+		{"Grp", map[string]interface{}{"a": 1, "d": true}, "8001020640" + "01ff"},
+		// an ENUMERATED value by its number, one unknown to the sheme too
+		{"Color", "blue", "02"},
+		{"Color", 200, "8200c8"},
+	} {
+		cls := sh.Class(tc.class)
+		el, err := build(cls, tc.val, &AsnContext{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, canonical := range []bool{false, true} {
+			out, err := el.encodeOER(canonical)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(out); got != tc.want {
+				t.Errorf("%s %v (canonical %t): %s, want %s", tc.class, tc.val, canonical, got, tc.want)
+				continue
+			}
+			js, err := cls.decodeOER(out, canonical)
+			if err != nil {
+				t.Fatalf("%s: decode: %s", tc.class, err)
+			}
+			dl, err := build(cls, js.Interface(), &AsnContext{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again, err := dl.encodeOER(canonical); err != nil || !bytes.Equal(again, out) {
+				t.Errorf("%s: decoded to %v", tc.class, js.Interface())
+			}
+		}
+	}
+}
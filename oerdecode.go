@@ -0,0 +1,437 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+// oerReader reads an OER encoding. In the canonical variant every encoding
+// that COER forbids is rejected.
+type oerReader struct {
+	data      []byte
+	pos       int
+	canonical bool
+}
+
+func (r *oerReader) getBytes(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, decodeDataErr("OER data too short")
+	}
+	out := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return out, nil
+}
+
+func (r *oerReader) getByte() (byte, error) {
+	b, err := r.getBytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *oerReader) getLength() (int, error) {
+	b, err := r.getByte()
+	if err != nil || b < 0x80 {
+		return int(b), err
+	}
+	num := int(b & 0x7f)
+	if num == 0 || num > 4 {
+		return 0, decodeDataErr("OER length of %d octets", num)
+	}
+	data, err := r.getBytes(num)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, b := range data {
+		n = n<<8 | int(b)
+	}
+	if r.canonical && (data[0] == 0 || n < 128) {
+		return 0, decodeDataErr("OER length not minimally-encoded")
+	}
+	return n, nil
+}
+
+func (r *oerReader) getOctets() ([]byte, error) {
+	n, err := r.getLength()
+	if err != nil {
+		return nil, err
+	}
+	return r.getBytes(n)
+}
+
+// getBitmap reads a bitmap of n bits packed in octets; the padding bits
+// must be zero in COER.
+func (r *oerReader) getBitmap(n int) ([]bool, error) {
+	data, err := r.getBytes((n + 7) / 8)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]bool, n)
+	for i := range out {
+		out[i] = data[i/8]&(0x80>>uint(i%8)) != 0
+	}
+	if r.canonical && n%8 != 0 && data[len(data)-1]&(0xff>>uint(n%8)) != 0 {
+		return nil, decodeDataErr("OER bitmap padding bits not zero")
+	}
+	return out, nil
+}
+
+// getUnsigned reads an unsigned number preceded by its length.
+func (r *oerReader) getUnsigned() (*big.Int, error) {
+	data, err := r.getOctets()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, decodeDataErr("OER empty integer")
+	}
+	if r.canonical && len(data) > 1 && data[0] == 0 {
+		return nil, decodeDataErr("OER integer not minimally-encoded")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// twosComplement returns the value of data in two's complement form.
+func twosComplement(data []byte) *big.Int {
+	v := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(data))))
+	}
+	return v
+}
+
+func (r *oerReader) getInteger(sh *Sheme) (*big.Int, error) {
+	n, signed := oerIntSize(sh)
+	if n > 0 {
+		data, err := r.getBytes(n)
+		if err != nil {
+			return nil, err
+		}
+		if signed {
+			return twosComplement(data), nil
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+	if !signed {
+		return r.getUnsigned()
+	}
+	data, err := r.getOctets()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, decodeDataErr("'%s' empty integer", sh.Name())
+	}
+	if r.canonical {
+		tmp := AsnData{data: data, len: len(data)}
+		if err := checkInteger(&tmp); err != nil {
+			return nil, err
+		}
+	}
+	return twosComplement(data), nil
+}
+
+// DecodeOER decodes data encoded in the Octet Encoding Rules. The result
+// has the shape of the one of Decode.
+func (sheme *Sheme) DecodeOER(data []byte) (*simplejson.Json, error) {
+	return sheme.decodeOER(data, false)
+}
+
+// DecodeCOER decodes data encoded in the canonical Octet Encoding Rules and
+// rejects any other encoding of the same value.
+func (sheme *Sheme) DecodeCOER(data []byte) (*simplejson.Json, error) {
+	return sheme.decodeOER(data, true)
+}
+
+func (sheme *Sheme) decodeOER(data []byte, canonical bool) (*simplejson.Json, error) {
+	r := &oerReader{data: data, canonical: canonical}
+	ret, err := r.decode(sheme, &AsnContext{der: canonical})
+	if err != nil {
+		return nil, err
+	}
+	if canonical && r.pos != len(data) {
+		return nil, decodeDataErr("'%s' %d trailing octets", sheme.Name(), len(data)-r.pos)
+	}
+	return simplejson.Wrap(ret), nil
+}
+
+// value decodes BER contents rebuilt from OER, see perReader.value.
+func (r *oerReader) value(sh *Sheme, tag int, data []byte, ctx *AsnContext) (interface{}, error) {
+	tmp := &AsnData{fdata: data, data: data, len: len(data)}
+	tmp.tag.tagNumber = tag
+	return tmp.decode(sh, ctx)
+}
+
+// open reads an open type and decodes it with sh.
+func (r *oerReader) open(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	data, err := r.getOctets()
+	if err != nil {
+		return nil, err
+	}
+	sub := &oerReader{data: data, canonical: r.canonical}
+	ret, err := sub.decode(sh, ctx)
+	if err == nil && r.canonical && sub.pos != len(data) {
+		err = decodeDataErr("'%s' %d trailing octets", sh.Name(), len(data)-sub.pos)
+	}
+	return ret, err
+}
+
+func (r *oerReader) decode(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	ctx.debugPrint("DecodeOER: '%s' (%s)", sh.Name(), sh.Type())
+
+	switch tp := sh.TypeEn(); tp {
+	case tagCHOICE:
+		return r.decodeChoice(sh, ctx)
+	case tagANY:
+		if ctx.od == "" {
+			return nil, decodeDataErr("'%s' miss ObjectDescriptor", sh.Name())
+		}
+		alt := sh.Field(ctx.od)
+		if alt == nil {
+			return nil, decodeDataErr("'%s' unknown ObjectDescriptor %s", sh.Name(), ctx.od)
+		}
+		return r.open(alt, &AsnContext{parent: ctx, der: ctx.der})
+	case tagNULL:
+		return r.value(sh, tp, nil, ctx)
+	case tagBOOLEAN:
+		b, err := r.getByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != 0 && !r.canonical {
+			// any other value than 0 is TRUE in OER
+			b = 0xff
+		}
+		return r.value(sh, tp, []byte{b}, ctx)
+	case tagINTEGER:
+		v, err := r.getInteger(sh)
+		if err != nil {
+			return nil, err
+		}
+		return r.value(sh, tp, encodeBigInt(v), ctx)
+	case tagENUMERATED:
+		b, err := r.getByte()
+		if err != nil {
+			return nil, err
+		}
+		if b < 0x80 {
+			return r.value(sh, tp, []byte{b}, ctx)
+		}
+		data, err := r.getBytes(int(b & 0x7f))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return nil, decodeDataErr("'%s' empty value", sh.Name())
+		}
+		v := twosComplement(data)
+		if r.canonical && v.Sign() >= 0 && v.Cmp(big.NewInt(128)) < 0 {
+			return nil, decodeDataErr("'%s' value %s not in the short form", sh.Name(), v)
+		}
+		return r.value(sh, tp, encodeBigInt(v), ctx)
+	case tagBIT_STR:
+		if n := oerFixedSize(sh); n >= 0 {
+			data, err := r.getBytes((n + 7) / 8)
+			if err != nil {
+				return nil, err
+			}
+			return r.value(sh, tp, append([]byte{byte((8 - n%8) % 8)}, data...), ctx)
+		}
+		data, err := r.getOctets()
+		if err != nil {
+			return nil, err
+		}
+		return r.value(sh, tp, data, ctx)
+	case tagOCTET_STR, tagNumericString, tagPrintableString, tagIA5String, tagVisibleString,
+		tagBMPString, tagUniversalString:
+		var data []byte
+		var err error
+		if n := oerFixedSize(sh); n >= 0 {
+			data, err = r.getBytes(n * perCharWidth(tp))
+		} else {
+			data, err = r.getOctets()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return r.value(sh, tp, data, ctx)
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return r.decodeSequenceOf(sh, ctx)
+		}
+		if sh.hasFields() {
+			return r.decodeSequence(sh, ctx)
+		}
+		return nil, decodeShemeErr("'%s' does not contain '$field' or '$of'", sh.Name())
+	case tagEOC:
+	default:
+		data, err := r.getOctets()
+		if err != nil {
+			return nil, err
+		}
+		return r.value(sh, tp, data, ctx)
+	}
+	return nil, Errorf("oerReader.decode: '%s' of unknown type '%s'", sh.Name(), sh.Type())
+}
+
+func (r *oerReader) decodeSequence(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	root, add := perFields(sh)
+	lead := 0
+	if sh.Extensible() {
+		lead++
+	}
+	ret := make(map[string]interface{})
+	ctxn := &AsnContext{parent: ctx, der: ctx.der}
+	ext, err := r.getFields(sh, root, lead, ret, ctxn)
+	if err != nil {
+		return nil, err
+	}
+	if len(ext) > 0 && ext[0] {
+		data, err := r.getOctets()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < 2 || data[0] > 7 {
+			return nil, decodeDataErr("'%s' invalid extension bitmap", sh.Name())
+		}
+		bm := &oerReader{data: data[1:], canonical: r.canonical}
+		present, err := bm.getBitmap(8*len(data[1:]) - int(data[0]))
+		if err != nil {
+			return nil, err
+		}
+		groups := perGroups(add)
+		for i, b := range present {
+			if !b {
+				continue
+			}
+			if i >= len(groups) {
+				// additions unknown to the sheme are skipped
+				if _, err := r.getOctets(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if f := groups[i][0]; f.Group() == 0 {
+				if ret[f.Name()], err = r.open(f, ctxn); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			data, err := r.getOctets()
+			if err != nil {
+				return nil, err
+			}
+			sub := &oerReader{data: data, canonical: r.canonical}
+			if _, err := sub.getFields(sh, groups[i], 0, ret, ctxn); err != nil {
+				return nil, err
+			}
+			if r.canonical && sub.pos != len(data) {
+				return nil, decodeDataErr("'%s' %d trailing octets", sh.Name(), len(data)-sub.pos)
+			}
+		}
+	}
+	for _, f := range append(root, add...) {
+		if _, f2 := ret[f.Name()]; !f2 {
+			if def := f.DefAttr(); def != nil {
+				ret[f.Name()] = def
+			}
+		}
+	}
+	return ret, nil
+}
+
+// getFields reads the fields fld of a SEQUENCE or SET sh into ret: the
+// preamble of lead bits and the presence of the optional ones, then those
+// present. It returns the lead bits.
+func (r *oerReader) getFields(sh *Sheme, fld []*Sheme, lead int, ret map[string]interface{}, ctx *AsnContext) ([]bool, error) {
+	num := lead
+	for _, f := range fld {
+		if f.Optional() || f.DefAttr() != nil {
+			num++
+		}
+	}
+	pre, err := r.getBitmap(num)
+	if err != nil {
+		return nil, err
+	}
+	pre, lbits := pre[lead:], pre[:lead]
+	for _, f := range fld {
+		if f.Optional() || f.DefAttr() != nil {
+			present := pre[0]
+			pre = pre[1:]
+			if !present {
+				continue
+			}
+		}
+		if ret[f.Name()], err = r.decode(f, ctx); err != nil {
+			return nil, err
+		}
+		if r.canonical && equalDefault(ret[f.Name()], f) {
+			return nil, decodeDataErr("'%s' DEFAULT value of '%s' encoded in COER", sh.Name(), f.Name())
+		}
+	}
+	return lbits, nil
+}
+
+func (r *oerReader) decodeSequenceOf(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	of := sh.Of()
+	qty, err := r.getUnsigned()
+	if err != nil {
+		return nil, err
+	}
+	if !qty.IsInt64() || qty.Int64() > int64(len(r.data)-r.pos) && of.TypeEn() != tagNULL {
+		return nil, decodeDataErr("'%s' quantity %s too large", sh.Name(), qty)
+	}
+	ret := make([]interface{}, qty.Int64())
+	ctxn := &AsnContext{parent: ctx, der: ctx.der}
+	var prev []byte
+	for i := range ret {
+		pos := r.pos
+		if ret[i], err = r.decode(of, ctxn); err != nil {
+			return nil, err
+		}
+		if r.canonical && sh.TypeEn() == tagSET {
+			if i > 0 && bytes.Compare(prev, r.data[pos:r.pos]) > 0 {
+				return nil, decodeDataErr("'%s' item %d out of COER order", sh.Name(), i)
+			}
+			prev = r.data[pos:r.pos]
+		}
+	}
+	return ret, nil
+}
+
+func (r *oerReader) decodeChoice(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	b, err := r.getByte()
+	if err != nil {
+		return nil, err
+	}
+	class, num := int(b>>6), int(b&0x3f)
+	if num == 0x3f {
+		if num, r.pos, err = parseBase128Int(r.data, r.pos); err != nil {
+			return nil, err
+		}
+		if r.canonical && num < 63 {
+			return nil, decodeDataErr("'%s' tag not minimally-encoded", sh.Name())
+		}
+	}
+	alt := sh.FieldList().FindTag(class, num)
+	if alt == nil {
+		return nil, decodeDataErr("'%s' unknown alternative [%d]", sh.Name(), num)
+	}
+
+	var val interface{}
+	ctxn := &AsnContext{parent: ctx, der: ctx.der}
+	if alt.Extension() && sh.Extensible() {
+		val, err = r.open(alt, ctxn)
+	} else {
+		val, err = r.decode(alt, ctxn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{alt.Name(): val}, nil
+}
@@ -0,0 +1,361 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+)
+
+// oerWriter builds an Octet Encoding Rules (X.696) encoding. In the
+// canonical variant the values are brought to their DER form beforehand
+// and the items of a SET OF are sorted.
+type oerWriter struct {
+	buf       []byte
+	canonical bool
+}
+
+// putLength writes a length determinant in the short or the minimal long
+// form (X.696 8.6).
+func (w *oerWriter) putLength(n int) {
+	if n < 128 {
+		w.buf = append(w.buf, byte(n))
+		return
+	}
+	l := lengthLength(n)
+	w.buf = append(w.buf, 0x80|byte(l))
+	for ; l > 0; l-- {
+		w.buf = append(w.buf, byte(n>>uint((l-1)*8)))
+	}
+}
+
+func (w *oerWriter) putOctets(data []byte) {
+	w.putLength(len(data))
+	w.buf = append(w.buf, data...)
+}
+
+// putOpen writes el as an open type: its encoding preceded by its length.
+func (w *oerWriter) putOpen(el *AsnData, sh *Sheme) error {
+	sub := &oerWriter{canonical: w.canonical}
+	if err := sub.encode(el, sh); err != nil {
+		return err
+	}
+	w.putOctets(sub.buf)
+	return nil
+}
+
+// packBits packs a bitmap into octets, the first bit being the most
+// significant one.
+func packBits(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
+
+// oerIntSize returns the number of octets of an INTEGER of fixed size and
+// whether it is signed; a size of 0 calls for a length determinant.
+// Extensible constraints are not visible to OER.
+func oerIntSize(sh *Sheme) (int, bool) {
+	lo, hi, hasLo, hasHi := perBounds(sh, false)
+	if sh.Extensible() || !hasLo {
+		return 0, true
+	}
+	if lo >= 0 {
+		switch {
+		case !hasHi:
+			return 0, false
+		case hi <= 0xff:
+			return 1, false
+		case hi <= 0xffff:
+			return 2, false
+		case hi <= 0xffffffff:
+			return 4, false
+		}
+		return 8, false
+	}
+	switch {
+	case !hasHi:
+		return 0, true
+	case lo >= -0x80 && hi <= 0x7f:
+		return 1, true
+	case lo >= -0x8000 && hi <= 0x7fff:
+		return 2, true
+	case lo >= -0x80000000 && hi <= 0x7fffffff:
+		return 4, true
+	}
+	return 8, true
+}
+
+// oerFixedSize returns the size of a string type with a fixed size, or -1.
+func oerFixedSize(sh *Sheme) int {
+	lo, hi, _, hasHi := perBounds(sh, true)
+	if sh.Extensible() || !hasHi || lo != hi {
+		return -1
+	}
+	return hi
+}
+
+// EncodeOER encodes th in the Octet Encoding Rules, driven by the sheme of
+// th. $min and $max give the size of fixed-size INTEGER and string values.
+func (th *AsnData) EncodeOER() ([]byte, error) {
+	return th.encodeOER(false)
+}
+
+// EncodeCOER encodes th in the canonical variant of the Octet Encoding
+// Rules.
+func (th *AsnData) EncodeCOER() ([]byte, error) {
+	if err := th.canonical(); err != nil {
+		return nil, err
+	}
+	return th.encodeOER(true)
+}
+
+func (th *AsnData) encodeOER(canonical bool) ([]byte, error) {
+	el := th.element()
+	if el.sheme == nil {
+		return nil, encodeShemeErr("'%s' no sheme description", el.tag.typeName())
+	}
+	w := &oerWriter{canonical: canonical}
+	if err := w.encode(el, el.sheme); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+func (w *oerWriter) putInteger(v *big.Int, sh *Sheme) error {
+	lo, hi, hasLo, hasHi := perBounds(sh, false)
+	if !sh.Extensible() && (hasLo && v.Cmp(big.NewInt(int64(lo))) < 0 || hasHi && v.Cmp(big.NewInt(int64(hi))) > 0) {
+		return encodeDataErr("'%s' %s out of range value: %s", sh.Name(), sh.Type(), v)
+	}
+	n, signed := oerIntSize(sh)
+	switch {
+	case n > 0:
+		u := v.Int64()
+		for i := n - 1; i >= 0; i-- {
+			w.buf = append(w.buf, byte(u>>uint(8*i)))
+		}
+	case signed:
+		w.putOctets(encodeBigInt(v))
+	default:
+		u := v.Bytes()
+		if len(u) == 0 {
+			u = []byte{0}
+		}
+		w.putOctets(u)
+	}
+	return nil
+}
+
+func (w *oerWriter) encode(el *AsnData, sh *Sheme) error {
+	if el = el.element(); el.sheme == nil {
+		return encodeShemeErr("'%s' no sheme description", sh.Name())
+	}
+
+	switch tp := sh.TypeEn(); tp {
+	case tagCHOICE:
+		return w.encodeChoice(el, sh)
+	case tagANY:
+		el, alt, err := alternative(el, sh)
+		if err != nil {
+			return err
+		}
+		return w.putOpen(el, alt)
+	case tagNULL:
+	case tagBOOLEAN:
+		val, err := el.value()
+		if err != nil {
+			return err
+		}
+		if val.(bool) {
+			w.buf = append(w.buf, 0xff)
+		} else {
+			w.buf = append(w.buf, 0x00)
+		}
+	case tagINTEGER:
+		val, err := el.value()
+		if err != nil {
+			return err
+		}
+		if v, ok := val.(int64); ok {
+			val = big.NewInt(v)
+		}
+		return w.putInteger(val.(*big.Int), sh)
+	case tagENUMERATED:
+		val, err := el.value()
+		if err != nil {
+			return err
+		}
+		// a value unknown to the sheme goes by its number as well
+		_, id, ok := sh.enumItem(val)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		if id >= 0 && id < 128 {
+			w.buf = append(w.buf, byte(id))
+		} else {
+			data := encodeInt(id)
+			w.buf = append(w.buf, 0x80|byte(len(data)))
+			w.buf = append(w.buf, data...)
+		}
+	case tagBIT_STR:
+		if n := oerFixedSize(sh); n >= 0 {
+			num := 8*(len(el.data)-1) - int(el.data[0])
+			if num != n {
+				return encodeDataErr("'%s' %s size %d out of constraint", sh.Name(), sh.Type(), num)
+			}
+			w.buf = append(w.buf, el.data[1:]...)
+		} else {
+			w.putOctets(el.data)
+		}
+	case tagOCTET_STR, tagNumericString, tagPrintableString, tagIA5String, tagVisibleString,
+		tagBMPString, tagUniversalString:
+		if n := oerFixedSize(sh); n >= 0 {
+			if len(el.data) != n*perCharWidth(tp) {
+				return encodeDataErr("'%s' %s size %d out of constraint", sh.Name(), sh.Type(), len(el.data)/perCharWidth(tp))
+			}
+			w.buf = append(w.buf, el.data...)
+		} else {
+			w.putOctets(el.data)
+		}
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return w.encodeSequenceOf(el, sh)
+		}
+		return w.encodeSequence(el, sh)
+	default:
+		// REAL, OBJECT IDENTIFIER, the times and the other character
+		// strings take their BER contents
+		w.putOctets(el.data)
+	}
+	return nil
+}
+
+func (w *oerWriter) encodeSequence(el *AsnData, sh *Sheme) error {
+	root, add := perFields(sh)
+	groups := perGroups(add)
+	more := el.hasAny(add)
+	// the preamble holds the extension bit and the presence of the
+	// optional fields
+	var pre []bool
+	if sh.Extensible() {
+		pre = append(pre, more)
+	}
+	if err := w.putFields(el, sh, root, pre...); err != nil {
+		return err
+	}
+	if !more {
+		return nil
+	}
+	// the presence of the additions is a BIT STRING, each one an open type,
+	// a group '[[ ]]' as a SEQUENCE of its components
+	bm := make([]bool, len(groups))
+	for i, grp := range groups {
+		bm[i] = el.hasAny(grp)
+	}
+	data := packBits(bm)
+	w.putLength(len(data) + 1)
+	w.buf = append(w.buf, byte(8*len(data)-len(bm)))
+	w.buf = append(w.buf, data...)
+	for _, grp := range groups {
+		if !el.hasAny(grp) {
+			continue
+		}
+		if f := grp[0]; f.Group() == 0 {
+			if err := w.putOpen(el.sub[f.ID()], f); err != nil {
+				return err
+			}
+			continue
+		}
+		sub := &oerWriter{canonical: w.canonical}
+		if err := sub.putFields(el, sh, grp); err != nil {
+			return err
+		}
+		w.putOctets(sub.buf)
+	}
+	return nil
+}
+
+// putFields writes the fields fld of the SEQUENCE or SET el: the preamble
+// of the bits pre and the presence of the optional ones, then those set.
+func (w *oerWriter) putFields(el *AsnData, sh *Sheme, fld []*Sheme, pre ...bool) error {
+	for _, f := range fld {
+		if f.Optional() || f.DefAttr() != nil {
+			pre = append(pre, el.has(f))
+		}
+	}
+	w.buf = append(w.buf, packBits(pre)...)
+	for _, f := range fld {
+		if !el.has(f) {
+			if !sh.omissible(f, el.has) {
+				return encodeShemeErr("'%s' miss not optional field '%s'", sh.Name(), f.Name())
+			}
+			continue
+		}
+		if err := w.encode(el.sub[f.ID()], f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *oerWriter) encodeSequenceOf(el *AsnData, sh *Sheme) error {
+	of := sh.Of()
+	// the quantity is an unsigned number preceded by its length
+	qty := big.NewInt(int64(len(el.sub))).Bytes()
+	if len(qty) == 0 {
+		qty = []byte{0}
+	}
+	w.putOctets(qty)
+	if !w.canonical || sh.TypeEn() != tagSET {
+		for _, sub := range el.sub {
+			if err := w.encode(sub, of); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	items := make([][]byte, len(el.sub))
+	for i, sub := range el.sub {
+		tmp := &oerWriter{canonical: true}
+		if err := tmp.encode(sub, of); err != nil {
+			return err
+		}
+		items[i] = tmp.buf
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return bytes.Compare(items[i], items[j]) < 0
+	})
+	for _, itm := range items {
+		w.buf = append(w.buf, itm...)
+	}
+	return nil
+}
+
+// appendOERTag writes the tag of a CHOICE alternative: the class in the two
+// high bits and the number, in base 128 beyond 62 (X.696 8.7).
+func appendOERTag(dst []byte, class, num int) []byte {
+	if num < 63 {
+		return append(dst, byte(class<<6|num))
+	}
+	dst = append(dst, byte(class<<6|0x3f))
+	return appendBase128Int(dst, int64(num))
+}
+
+func (w *oerWriter) encodeChoice(el *AsnData, sh *Sheme) error {
+	el, alt, err := alternative(el, sh)
+	if err != nil {
+		return err
+	}
+	tag, ok := alt.outerTag()
+	if !ok {
+		return encodeShemeErr("'%s' alternative '%s' has no tag", sh.Name(), alt.Name())
+	}
+	w.buf = appendOERTag(w.buf, tag[0], tag[1])
+	if alt.Extension() && sh.Extensible() {
+		return w.putOpen(el, alt)
+	}
+	return w.encode(el, alt)
+}
@@ -0,0 +1,144 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const perModule = `
+PER-Test DEFINITIONS IMPLICIT TAGS ::= BEGIN
+Small ::= INTEGER (0..7)
+Wide ::= INTEGER (0..65536)
+Name ::= IA5String (SIZE(1..8))
+Ext ::= SEQUENCE { a INTEGER, ..., e1 BOOLEAN, e2 UTF8String }
+Alt ::= CHOICE { x [3] NULL, y [1] INTEGER, ..., z [7] BOOLEAN }
+Color ::= ENUMERATED { red, green, ..., blue }
+Grp ::= SEQUENCE { a INTEGER (0..255), ..., [[ b BOOLEAN, c INTEGER (0..255) OPTIONAL ]], d BOOLEAN }
+Data ::= OCTET STRING
+Text ::= IA5String
+Bits ::= BIT STRING
+List ::= SEQUENCE OF BOOLEAN
+END
+`
+
+func perSheme(t *testing.T) *Sheme {
+	t.Helper()
+	sh, err := NewShemeASN1([]byte(perModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sh
+}
+
+// perRoundTrip encodes val of class in the aligned or unaligned PER and
+// checks that the encoding decodes to a value that encodes the same.
+func perRoundTrip(t *testing.T, cls *Sheme, val interface{}, aligned bool) []byte {
+	t.Helper()
+	el, err := build(cls, val, &AsnContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := el.encodePER(aligned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := cls.decodePER(out, aligned)
+	if err != nil {
+		t.Fatalf("%s: decode: %s", cls.Name(), err)
+	}
+	el, err = build(cls, js.Interface(), &AsnContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := el.encodePER(aligned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, again) {
+		t.Errorf("%s: decoded to %v", cls.Name(), js.Interface())
+	}
+	return out
+}
+
+func TestPERVectors(t *testing.T) {
+	sh := perSheme(t)
+	for _, tc := range []struct {
+		class      string
+		val        interface{}
+		uper, aper string
+	}{
+		{"Small", 5, "a0", "a0"},
+		{"Name", "ab", "387100", "206162"},
+		{"Wide", 300, "009600", "40012c"},
+		// the extension bit, the bitmap of the additions and an addition
+		// as an open type
+		{"Ext", map[string]interface{}{"a": 1}, "008080", "000101"},
+		{"Ext", map[string]interface{}{"a": 1, "e2": "hi"}, "80808140c09a1a40", "800101028003026869"},
+		// the root alternatives in the order of their tags, an addition
+		// as an open type
+		{"Alt", map[string]interface{}{"y": 5}, "004140", "000105"},
+		{"Alt", map[string]interface{}{"x": nil}, "40", "40"},
+		{"Alt", map[string]interface{}{"z": true}, "800180", "800180"},
+		// a group as one open type holding a SEQUENCE of its components
+		{"Grp", map[string]interface{}{"a": 1, "b": true}, "8081805000", "800103000140"},
+		{"Grp", map[string]interface{}{"a": 1, "d": true}, "8081406000", "800102800180"},
+		// the index in the root or among the additions
+		{"Color", "green", "40", "40"},
+		{"Color", "blue", "80", "80"},
+	} {
+		cls := sh.Class(tc.class)
+		if got := hex.EncodeToString(perRoundTrip(t, cls, tc.val, false)); got != tc.uper {
+			t.Errorf("%s %v: UPER %s, want %s", tc.class, tc.val, got, tc.uper)
+		}
+		if got := hex.EncodeToString(perRoundTrip(t, cls, tc.val, true)); got != tc.aper {
+			t.Errorf("%s %v: APER %s, want %s", tc.class, tc.val, got, tc.aper)
+		}
+	}
+}
+
+func TestPERFragments(t *testing.T) {
+	sh := perSheme(t)
+	for _, tc := range []struct {
+		class string
+		val   interface{}
+		// the fragment headers and the final length, with the offsets of
+		// the octets they are found at in the aligned encoding
+		at   []int
+		want []string
+	}{
+		{"Data", make([]byte, 16384), []int{0, 16385}, []string{"c1", "00"}},
+		{"Data", make([]byte, 70000), []int{0, 65537}, []string{"c4", "9170"}},
+		{"Text", strings.Repeat("x", 50000), []int{0, 49153}, []string{"c3", "8350"}},
+		{"Bits", BitStr{Bytes: make([]byte, 5000), BitLength: 39999}, []int{0, 4097}, []string{"c2", "9c3f"}},
+		{"List", make([]interface{}, 20000), nil, nil},
+	} {
+		if lst, ok := tc.val.([]interface{}); ok {
+			for i := range lst {
+				lst[i] = i%3 == 0
+			}
+		}
+		cls := sh.Class(tc.class)
+		perRoundTrip(t, cls, tc.val, false)
+		out := perRoundTrip(t, cls, tc.val, true)
+		for i, at := range tc.at {
+			n := len(tc.want[i]) / 2
+			if got := hex.EncodeToString(out[at : at+n]); got != tc.want[i] {
+				t.Errorf("%s: %s at %d, want %s", tc.class, got, at, tc.want[i])
+			}
+		}
+	}
+}
+
+func TestPERUnknownEnumerated(t *testing.T) {
+	cls := perSheme(t).Class("Color")
+	el, err := build(cls, 200, &AsnContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// there is no index to send a value unknown to the sheme by
+	if _, err := el.encodePER(true); err == nil {
+		t.Error("encoded an unknown value")
+	}
+}
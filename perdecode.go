@@ -0,0 +1,587 @@
+package asn1dynamic
+
+import (
+	"math/big"
+	"math/bits"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+// perReader reads the bits of a PER encoding, see perWriter.
+type perReader struct {
+	data    []byte
+	pos     int
+	aligned bool
+}
+
+func (r *perReader) getBit() (bool, error) {
+	if r.pos >= 8*len(r.data) {
+		return false, decodeDataErr("PER data too short")
+	}
+	b := r.data[r.pos/8]&(0x80>>uint(r.pos%8)) != 0
+	r.pos++
+	return b, nil
+}
+
+func (r *perReader) getBits(n int) (uint64, error) {
+	var val uint64
+	for i := 0; i < n; i++ {
+		b, err := r.getBit()
+		if err != nil {
+			return 0, err
+		}
+		val <<= 1
+		if b {
+			val |= 1
+		}
+	}
+	return val, nil
+}
+
+func (r *perReader) getBytes(n int) ([]byte, error) {
+	if r.pos+8*n > 8*len(r.data) {
+		return nil, decodeDataErr("PER data too short")
+	}
+	if r.pos%8 == 0 {
+		out := r.data[r.pos/8 : r.pos/8+n]
+		r.pos += 8 * n
+		return out, nil
+	}
+	out := make([]byte, n)
+	for i := range out {
+		b, _ := r.getBits(8)
+		out[i] = byte(b)
+	}
+	return out, nil
+}
+
+func (r *perReader) align() {
+	if r.aligned {
+		r.pos = (r.pos + 7) / 8 * 8
+	}
+}
+
+func (r *perReader) getConstrained(ub uint64) (uint64, error) {
+	switch {
+	case ub == 0:
+		return 0, nil
+	case !r.aligned || ub < 255:
+		return r.getBits(bits.Len64(ub))
+	case ub == 255:
+		r.align()
+		return r.getBits(8)
+	case ub < 65536:
+		r.align()
+		return r.getBits(16)
+	}
+	n, err := r.getBits(bits.Len64(uint64(octetsFor(ub) - 1)))
+	if err != nil {
+		return 0, err
+	}
+	r.align()
+	return r.getBits(8 * int(n+1))
+}
+
+// getFragment reads a length determinant and reports whether it is the
+// size of a fragment that more units follow, see perWriter.putChunks.
+func (r *perReader) getFragment() (int, bool, error) {
+	r.align()
+	n, err := r.getBits(8)
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case n&0x80 == 0:
+		return int(n), false, nil
+	case n&0xc0 == 0x80:
+		lo, err := r.getBits(8)
+		return int(n&0x3f)<<8 | int(lo), false, err
+	case n >= 0xc1 && n <= 0xc4:
+		return int(n&0x3f) * perFragment, true, nil
+	}
+	return 0, false, decodeDataErr("PER wrong length determinant %x", n)
+}
+
+func (r *perReader) getLength() (int, error) {
+	n, more, err := r.getFragment()
+	if err == nil && more {
+		err = decodeDataErr("PER unexpected fragmented length")
+	}
+	return n, err
+}
+
+// getChunks reads units with an unconstrained length, get reading the
+// units of every fragment, and returns their number.
+func (r *perReader) getChunks(get func(n int) error) (int, error) {
+	total := 0
+	for {
+		n, more, err := r.getFragment()
+		if err != nil {
+			return 0, err
+		}
+		if err = get(n); err != nil {
+			return 0, err
+		}
+		total += n
+		if !more {
+			return total, nil
+		}
+	}
+}
+
+func (r *perReader) getOctets() ([]byte, error) {
+	out := []byte{}
+	_, err := r.getChunks(func(n int) error {
+		data, err := r.getBytes(n)
+		out = append(out, data...)
+		return err
+	})
+	return out, err
+}
+
+func (r *perReader) getSmall() (int, error) {
+	b, err := r.getBit()
+	if err != nil {
+		return 0, err
+	}
+	if !b {
+		n, err := r.getBits(6)
+		return int(n), err
+	}
+	data, err := r.getOctets()
+	if err != nil {
+		return 0, err
+	}
+	return int(new(big.Int).SetBytes(data).Int64()), nil
+}
+
+func (r *perReader) getSmallLength() (int, error) {
+	b, err := r.getBit()
+	if err != nil {
+		return 0, err
+	}
+	if !b {
+		n, err := r.getBits(6)
+		return int(n) + 1, err
+	}
+	return r.getLength()
+}
+
+// getSize reads a value written by perWriter.putSize, get reading the
+// units as in getChunks, and returns their number.
+func (r *perReader) getSize(unit int, sh *Sheme, chars bool, get func(n int) error) (int, error) {
+	if sh == nil {
+		return r.getChunks(get)
+	}
+	lo, hi, _, hasHi := perBounds(sh, true)
+	if sh.Extensible() {
+		out, err := r.getBit()
+		if err != nil {
+			return 0, err
+		}
+		if out {
+			return r.getChunks(get)
+		}
+	}
+	if hasHi && hi < 65536 {
+		n, aligned := hi, hi*unit > 16
+		if lo != hi {
+			u, err := r.getConstrained(uint64(hi - lo))
+			if err != nil {
+				return 0, err
+			}
+			n, aligned = int(u)+lo, aligned || !chars && unit > 0
+		}
+		if aligned {
+			r.align()
+		}
+		return n, get(n)
+	}
+	return r.getChunks(get)
+}
+
+func (r *perReader) getInteger(sh *Sheme) (*big.Int, error) {
+	lo, hi, hasLo, hasHi := perBounds(sh, false)
+	if sh.Extensible() {
+		out, err := r.getBit()
+		if err != nil {
+			return nil, err
+		}
+		if out {
+			hasLo, hasHi = false, false
+		}
+	}
+	switch {
+	case hasLo && hasHi:
+		u, err := r.getConstrained(uint64(hi - lo))
+		if err != nil {
+			return nil, err
+		}
+		v := new(big.Int).SetUint64(u)
+		return v.Add(v, big.NewInt(int64(lo))), nil
+	case hasLo:
+		data, err := r.getOctets()
+		if err != nil {
+			return nil, err
+		}
+		v := new(big.Int).SetBytes(data)
+		return v.Add(v, big.NewInt(int64(lo))), nil
+	}
+	data, err := r.getOctets()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, decodeDataErr("'%s' empty integer", sh.Name())
+	}
+	v := new(big.Int).SetBytes(data)
+	if data[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(data))))
+	}
+	return v, nil
+}
+
+// DecodeAPER decodes data encoded in the aligned variant of the Packed
+// Encoding Rules. The result has the shape of the one of Decode.
+func (sheme *Sheme) DecodeAPER(data []byte) (*simplejson.Json, error) {
+	return sheme.decodePER(data, true)
+}
+
+// DecodeUPER decodes data encoded in the unaligned variant of the Packed
+// Encoding Rules.
+func (sheme *Sheme) DecodeUPER(data []byte) (*simplejson.Json, error) {
+	return sheme.decodePER(data, false)
+}
+
+func (sheme *Sheme) decodePER(data []byte, aligned bool) (*simplejson.Json, error) {
+	r := &perReader{data: data, aligned: aligned}
+	ret, err := r.decode(sheme, &AsnContext{})
+	if err != nil {
+		return nil, err
+	}
+	return simplejson.Wrap(ret), nil
+}
+
+// value decodes BER contents rebuilt from PER, so that the result and its
+// checks are those of Decode.
+func (r *perReader) value(sh *Sheme, tag int, data []byte, ctx *AsnContext) (interface{}, error) {
+	tmp := &AsnData{fdata: data, data: data, len: len(data)}
+	tmp.tag.tagNumber = tag
+	return tmp.decode(sh, ctx)
+}
+
+func (r *perReader) decode(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	ctx.debugPrint("DecodePER: '%s' (%s)", sh.Name(), sh.Type())
+
+	switch tp := sh.TypeEn(); tp {
+	case tagCHOICE:
+		return r.decodeChoice(sh, ctx)
+	case tagANY:
+		return r.decodeAny(sh, ctx)
+	case tagNULL:
+		return r.value(sh, tp, nil, ctx)
+	case tagBOOLEAN:
+		b, err := r.getBit()
+		if err != nil {
+			return nil, err
+		}
+		data := []byte{0}
+		if b {
+			data[0] = 0xff
+		}
+		return r.value(sh, tp, data, ctx)
+	case tagINTEGER:
+		v, err := r.getInteger(sh)
+		if err != nil {
+			return nil, err
+		}
+		return r.value(sh, tp, encodeBigInt(v), ctx)
+	case tagENUMERATED:
+		root, add := perEnumItems(sh)
+		ext := false
+		if sh.Extensible() {
+			var err error
+			if ext, err = r.getBit(); err != nil {
+				return nil, err
+			}
+		}
+		var id int
+		if ext {
+			i, err := r.getSmall()
+			if err != nil {
+				return nil, err
+			}
+			if i >= len(add) {
+				return nil, decodeDataErr("'%s' unknown extension value %d", sh.Name(), i)
+			}
+			id = add[i]
+		} else {
+			i, err := r.getConstrained(uint64(len(root) - 1))
+			if err != nil {
+				return nil, err
+			}
+			if int(i) >= len(root) {
+				return nil, decodeDataErr("'%s' wrong value index %d", sh.Name(), i)
+			}
+			id = root[i]
+		}
+		return r.value(sh, tp, encodeInt(id), ctx)
+	case tagBIT_STR:
+		data := []byte{0}
+		num, err := r.getSize(1, sh, false, func(n int) error {
+			// a fragment holds whole octets but the last
+			full, err := r.getBytes(n / 8)
+			if err != nil {
+				return err
+			}
+			data = append(data, full...)
+			if n%8 != 0 {
+				b, err := r.getBits(n % 8)
+				if err != nil {
+					return err
+				}
+				data = append(data, byte(b<<uint(8-n%8)))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		data[0] = byte((8 - num%8) % 8)
+		return r.value(sh, tp, data, ctx)
+	case tagOCTET_STR:
+		data := []byte{}
+		_, err := r.getSize(8, sh, false, func(n int) error {
+			full, err := r.getBytes(n)
+			data = append(data, full...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return r.value(sh, tp, data, ctx)
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return r.decodeSequenceOf(sh, ctx)
+		}
+		if sh.hasFields() {
+			return r.decodeSequence(sh, ctx)
+		}
+		return nil, decodeShemeErr("'%s' does not contain '$field' or '$of'", sh.Name())
+	default:
+		if b := perCharBits(tp, r.aligned); b > 0 {
+			return r.decodeChars(sh, ctx, b)
+		}
+		if tp == tagEOC {
+			break
+		}
+		data, err := r.getOctets()
+		if err != nil {
+			return nil, err
+		}
+		return r.value(sh, tp, data, ctx)
+	}
+	return nil, Errorf("perReader.decode: '%s' of unknown type '%s'", sh.Name(), sh.Type())
+}
+
+func (r *perReader) decodeChars(sh *Sheme, ctx *AsnContext, b int) (interface{}, error) {
+	tp := sh.TypeEn()
+	width := perCharWidth(tp)
+	cons := sh
+	if tp == tagUTCTime || tp == tagGeneralizedTime {
+		cons = nil
+	}
+	data := []byte{}
+	_, err := r.getSize(b, cons, true, func(n int) error {
+		for i := 0; i < n; i++ {
+			c, err := r.getBits(b)
+			if err != nil {
+				return err
+			}
+			if tp == tagNumericString {
+				if c != 0 {
+					c += '0' - 1
+				} else {
+					c = ' '
+				}
+			}
+			for j := width - 1; j >= 0; j-- {
+				data = append(data, byte(c>>uint(8*j)))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.value(sh, tp, data, ctx)
+}
+
+func (r *perReader) decodeSequence(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	root, add := perFields(sh)
+	more := false
+	if sh.Extensible() {
+		var err error
+		if more, err = r.getBit(); err != nil {
+			return nil, err
+		}
+	}
+	ret := make(map[string]interface{})
+	ctxn := &AsnContext{parent: ctx}
+	if err := r.getFields(root, ret, ctxn); err != nil {
+		return nil, err
+	}
+	if more {
+		n, err := r.getSmallLength()
+		if err != nil {
+			return nil, err
+		}
+		bitmap := make([]bool, n)
+		for i := range bitmap {
+			if bitmap[i], err = r.getBit(); err != nil {
+				return nil, err
+			}
+		}
+		groups := perGroups(add)
+		for i, b := range bitmap {
+			if !b {
+				continue
+			}
+			data, err := r.getOctets()
+			if err != nil {
+				return nil, err
+			}
+			// additions unknown to the sheme are skipped
+			if i >= len(groups) {
+				continue
+			}
+			sub := &perReader{data: data, aligned: r.aligned}
+			if f := groups[i][0]; f.Group() == 0 {
+				ret[f.Name()], err = sub.decode(f, ctxn)
+			} else {
+				err = sub.getFields(groups[i], ret, ctxn)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, f := range append(root, add...) {
+		if _, f2 := ret[f.Name()]; !f2 {
+			if def := f.DefAttr(); def != nil {
+				ret[f.Name()] = def
+			}
+		}
+	}
+	return ret, nil
+}
+
+// getFields reads the fields fld of a SEQUENCE or SET into ret: the
+// preamble of the optional ones, then those present.
+func (r *perReader) getFields(fld []*Sheme, ret map[string]interface{}, ctx *AsnContext) error {
+	present := make(map[string]bool)
+	for _, f := range fld {
+		if f.Optional() || f.DefAttr() != nil {
+			b, err := r.getBit()
+			if err != nil {
+				return err
+			}
+			present[f.Name()] = b
+		} else {
+			present[f.Name()] = true
+		}
+	}
+	var err error
+	for _, f := range fld {
+		if present[f.Name()] {
+			if ret[f.Name()], err = r.decode(f, ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *perReader) decodeSequenceOf(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	of := sh.Of()
+	ret := []interface{}{}
+	ctxn := &AsnContext{parent: ctx}
+	_, err := r.getSize(0, sh, false, func(n int) error {
+		for i := 0; i < n; i++ {
+			val, err := r.decode(of, ctxn)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, val)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (r *perReader) decodeChoice(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	root, add := perFields(sh)
+	if len(root) == 0 {
+		return nil, decodeShemeErr("'%s' cannot find any field in sheme", sh.Name())
+	}
+	ext := false
+	if sh.Extensible() {
+		var err error
+		if ext, err = r.getBit(); err != nil {
+			return nil, err
+		}
+	}
+	var alt *Sheme
+	var val interface{}
+	ctxn := &AsnContext{parent: ctx}
+	if ext {
+		i, err := r.getSmall()
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.getOctets()
+		if err != nil {
+			return nil, err
+		}
+		if i >= len(add) {
+			return nil, decodeDataErr("'%s' unknown extension alternative %d", sh.Name(), i)
+		}
+		alt = add[i]
+		sub := &perReader{data: data, aligned: r.aligned}
+		if val, err = sub.decode(alt, ctxn); err != nil {
+			return nil, err
+		}
+	} else {
+		i, err := r.getConstrained(uint64(len(root) - 1))
+		if err != nil {
+			return nil, err
+		}
+		if int(i) >= len(root) {
+			return nil, decodeDataErr("'%s' wrong alternative index %d", sh.Name(), i)
+		}
+		alt = root[i]
+		if val, err = r.decode(alt, ctxn); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{alt.Name(): val}, nil
+}
+
+func (r *perReader) decodeAny(sh *Sheme, ctx *AsnContext) (interface{}, error) {
+	if ctx.od == "" {
+		return nil, decodeDataErr("'%s' miss ObjectDescriptor", sh.Name())
+	}
+	alt := sh.Field(ctx.od)
+	if alt == nil {
+		return nil, decodeDataErr("'%s' unknown ObjectDescriptor %s", sh.Name(), ctx.od)
+	}
+	data, err := r.getOctets()
+	if err != nil {
+		return nil, err
+	}
+	sub := &perReader{data: data, aligned: r.aligned}
+	return sub.decode(alt, &AsnContext{parent: ctx})
+}
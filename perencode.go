@@ -0,0 +1,618 @@
+package asn1dynamic
+
+import (
+	"math/big"
+	"math/bits"
+	"sort"
+)
+
+// perWriter collects the bits of a Packed Encoding Rules (X.691) encoding,
+// most significant bit first. In the aligned variant some fields start at
+// an octet boundary.
+type perWriter struct {
+	buf     []byte
+	bits    int
+	aligned bool
+}
+
+func (w *perWriter) putBit(b bool) {
+	if w.bits%8 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if b {
+		w.buf[len(w.buf)-1] |= 0x80 >> uint(w.bits%8)
+	}
+	w.bits++
+}
+
+// putBits writes the n low bits of val.
+func (w *perWriter) putBits(val uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.putBit(val>>uint(i)&1 != 0)
+	}
+}
+
+func (w *perWriter) putBytes(data []byte) {
+	if w.bits%8 != 0 {
+		for _, b := range data {
+			w.putBits(uint64(b), 8)
+		}
+		return
+	}
+	w.buf = append(w.buf, data...)
+	w.bits += 8 * len(data)
+}
+
+func (w *perWriter) align() {
+	if w.aligned {
+		w.bits = 8 * len(w.buf)
+	}
+}
+
+// complete returns the encoding padded to whole octets, at least one.
+func (w *perWriter) complete() []byte {
+	if len(w.buf) == 0 {
+		return []byte{0}
+	}
+	return w.buf
+}
+
+func octetsFor(u uint64) int {
+	if u == 0 {
+		return 1
+	}
+	return (bits.Len64(u) + 7) / 8
+}
+
+// putConstrained writes a constrained whole number u in the range 0..ub
+// (X.691 11.5).
+func (w *perWriter) putConstrained(u, ub uint64) {
+	switch {
+	case ub == 0:
+	case !w.aligned || ub < 255:
+		w.putBits(u, bits.Len64(ub))
+	case ub == 255:
+		w.align()
+		w.putBits(u, 8)
+	case ub < 65536:
+		w.align()
+		w.putBits(u, 16)
+	default:
+		n := octetsFor(u)
+		w.putBits(uint64(n-1), bits.Len64(uint64(octetsFor(ub)-1)))
+		w.align()
+		w.putBits(u, 8*n)
+	}
+}
+
+// perFragment is the unit of the size of a fragment (X.691 11.9.3.8).
+const perFragment = 16384
+
+// putLength writes an unconstrained length determinant (X.691 11.9).
+// Longer lengths are written in fragments by putChunks.
+func (w *perWriter) putLength(n int) error {
+	w.align()
+	switch {
+	case n < 128:
+		w.putBits(uint64(n), 8)
+	case n < perFragment:
+		w.putBits(0x8000|uint64(n), 16)
+	default:
+		return encodeDataErr("PER length %d needs fragmentation", n)
+	}
+	return nil
+}
+
+// putChunks writes n units with an unconstrained length, put writing the
+// units from..to. From 16K units on they go in fragments of up to 64K
+// units, each preceded by its size in units of 16K, and the rest after a
+// final length, which may be zero (X.691 11.9.3.8).
+func (w *perWriter) putChunks(n int, put func(from, to int) error) error {
+	from := 0
+	for n-from >= perFragment {
+		m := (n - from) / perFragment
+		if m > 4 {
+			m = 4
+		}
+		w.align()
+		w.putBits(0xc0|uint64(m), 8)
+		if err := put(from, from+m*perFragment); err != nil {
+			return err
+		}
+		from += m * perFragment
+	}
+	if err := w.putLength(n - from); err != nil {
+		return err
+	}
+	return put(from, n)
+}
+
+func (w *perWriter) putOctets(data []byte) error {
+	return w.putChunks(len(data), func(from, to int) error {
+		w.putBytes(data[from:to])
+		return nil
+	})
+}
+
+// putSmall writes a normally small non-negative whole number (X.691 11.6).
+func (w *perWriter) putSmall(n int) error {
+	if n < 64 {
+		w.putBit(false)
+		w.putBits(uint64(n), 6)
+		return nil
+	}
+	w.putBit(true)
+	return w.putOctets(big.NewInt(int64(n)).Bytes())
+}
+
+// putSmallLength writes a normally small length (X.691 11.9.3.4).
+func (w *perWriter) putSmallLength(n int) error {
+	if n <= 64 {
+		w.putBit(false)
+		w.putBits(uint64(n-1), 6)
+		return nil
+	}
+	w.putBit(true)
+	return w.putLength(n)
+}
+
+// perBounds returns the value or size constraint of sh. A size is never
+// negative, so its lower bound is always known.
+func perBounds(sh *Sheme, size bool) (lo, hi int, hasLo, hasHi bool) {
+	hasLo, hasHi = sh.bounds()
+	lo, hi = sh.MinAttr(), sh.MaxAttr()
+	if size && !hasLo {
+		lo, hasLo = 0, true
+	}
+	return
+}
+
+// perCharBits returns the number of bits of a character of a known-multiplier
+// character string, or 0 for the types encoded as plain octets.
+func perCharBits(tag int, aligned bool) int {
+	switch tag {
+	case tagNumericString:
+		return 4
+	case tagPrintableString, tagIA5String, tagVisibleString, tagUTCTime, tagGeneralizedTime:
+		if aligned {
+			return 8
+		}
+		return 7
+	case tagBMPString:
+		return 16
+	case tagUniversalString:
+		return 32
+	}
+	return 0
+}
+
+// perCharWidth returns the number of octets of a character in the BER
+// contents of a known-multiplier string.
+func perCharWidth(tag int) int {
+	switch tag {
+	case tagBMPString:
+		return 2
+	case tagUniversalString:
+		return 4
+	}
+	return 1
+}
+
+// perFields splits the components of sh into the extension root and the
+// extension additions, which need an extensible type. The root of a SET
+// and the alternatives of a CHOICE are taken in the canonical order of
+// their tags.
+func perFields(sh *Sheme) (root, add []*Sheme) {
+	fld := sh.FieldList()
+	for f := fld.Begin(); f != nil; f = fld.Next() {
+		if f.Extension() && sh.Extensible() {
+			add = append(add, f)
+		} else {
+			root = append(root, f)
+		}
+	}
+	if tp := sh.TypeEn(); tp == tagSET || tp == tagCHOICE {
+		tag := func(f *Sheme) AsnTag {
+			if f.Tagged() {
+				return AsnTag{tagClass: f.TagClass(), tagNumber: f.Index()}
+			}
+			return AsnTag{tagClass: classUniversal, tagNumber: f.TypeEn()}
+		}
+		sort.SliceStable(root, func(i, j int) bool {
+			return tagLess(tag(root[i]), tag(root[j]))
+		})
+		if tp == tagCHOICE {
+			sort.SliceStable(add, func(i, j int) bool {
+				return tagLess(tag(add[i]), tag(add[j]))
+			})
+		}
+	}
+	return
+}
+
+// perGroups returns the additions of a SEQUENCE or SET by the open types
+// they are sent in: an addition alone or the components of a group '[[ ]]'
+// together, as a SEQUENCE of them (X.691 19.9).
+func perGroups(add []*Sheme) [][]*Sheme {
+	var out [][]*Sheme
+	for i, f := range add {
+		if g := f.Group(); g > 0 && i > 0 && add[i-1].Group() == g {
+			out[len(out)-1] = append(out[len(out)-1], f)
+		} else {
+			out = append(out, []*Sheme{f})
+		}
+	}
+	return out
+}
+
+// hasAny reports whether any of the fields fld of th is set.
+func (th *AsnData) hasAny(fld []*Sheme) bool {
+	for _, f := range fld {
+		if th.has(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// perEnumItems returns the values of the root and the additions of an
+// ENUMERATED in ascending order.
+func perEnumItems(sh *Sheme) (root, add []int) {
+	ext := make(map[string]bool)
+	for _, name := range sh.ExtensionItems() {
+		ext[name] = true
+	}
+	for id, name := range sh.EnumItems() {
+		if ext[name] && sh.Extensible() {
+			add = append(add, id)
+		} else {
+			root = append(root, id)
+		}
+	}
+	sort.Ints(root)
+	sort.Ints(add)
+	return
+}
+
+func indexOf(lst []int, val int) int {
+	for i, v := range lst {
+		if v == val {
+			return i
+		}
+	}
+	return -1
+}
+
+// EncodeAPER encodes th in the aligned variant of the Packed Encoding
+// Rules, driven by the sheme of th. $min and $max are the value and size
+// constraints, $extensible adds the extension bit.
+func (th *AsnData) EncodeAPER() ([]byte, error) {
+	return th.encodePER(true)
+}
+
+// EncodeUPER encodes th in the unaligned variant of the Packed Encoding
+// Rules.
+func (th *AsnData) EncodeUPER() ([]byte, error) {
+	return th.encodePER(false)
+}
+
+func (th *AsnData) encodePER(aligned bool) ([]byte, error) {
+	el := th.element()
+	if el.sheme == nil {
+		return nil, encodeShemeErr("'%s' no sheme description", el.tag.typeName())
+	}
+	w := &perWriter{aligned: aligned}
+	if err := w.encode(el, el.sheme); err != nil {
+		return nil, err
+	}
+	return w.complete(), nil
+}
+
+// putSize writes a value of n units under the size constraint of sh, if
+// any: its length, then the units by put as in putChunks. unit is the size
+// of a unit in bits, 0 for the components of a SEQUENCE OF; chars selects
+// the rules of known-multiplier strings.
+func (w *perWriter) putSize(n, unit int, sh *Sheme, chars bool, put func(from, to int) error) error {
+	if sh == nil {
+		return w.putChunks(n, put)
+	}
+	lo, hi, _, hasHi := perBounds(sh, true)
+	out := n < lo || hasHi && n > hi
+	if sh.Extensible() {
+		w.putBit(out)
+		if out {
+			return w.putChunks(n, put)
+		}
+	} else if out {
+		return encodeDataErr("'%s' %s size %d out of constraint", sh.Name(), sh.Type(), n)
+	}
+	if hasHi && hi < 65536 {
+		// a fixed size has no length, and only contents of more than
+		// two octets are aligned
+		aligned := hi*unit > 16
+		if lo != hi {
+			w.putConstrained(uint64(n-lo), uint64(hi-lo))
+			aligned = aligned || !chars && unit > 0
+		}
+		if aligned {
+			w.align()
+		}
+		return put(0, n)
+	}
+	return w.putChunks(n, put)
+}
+
+func (w *perWriter) putInteger(v *big.Int, sh *Sheme) error {
+	lo, hi, hasLo, hasHi := perBounds(sh, false)
+	blo, bhi := big.NewInt(int64(lo)), big.NewInt(int64(hi))
+	out := hasLo && v.Cmp(blo) < 0 || hasHi && v.Cmp(bhi) > 0
+	if sh.Extensible() {
+		w.putBit(out)
+		if out {
+			return w.putOctets(encodeBigInt(v))
+		}
+	} else if out {
+		return encodeDataErr("'%s' %s out of range value: %s", sh.Name(), sh.Type(), v)
+	}
+	switch {
+	case hasLo && hasHi:
+		u := new(big.Int).Sub(v, blo)
+		w.putConstrained(u.Uint64(), uint64(hi-lo))
+	case hasLo:
+		u := new(big.Int).Sub(v, blo).Bytes()
+		if len(u) == 0 {
+			u = []byte{0}
+		}
+		return w.putOctets(u)
+	default:
+		return w.putOctets(encodeBigInt(v))
+	}
+	return nil
+}
+
+// putOpen writes el as an open type: the complete encoding of el preceded
+// by its length.
+func (w *perWriter) putOpen(el *AsnData, sh *Sheme) error {
+	sub := &perWriter{aligned: w.aligned}
+	if err := sub.encode(el, sh); err != nil {
+		return err
+	}
+	return w.putOctets(sub.complete())
+}
+
+func (w *perWriter) encode(el *AsnData, sh *Sheme) error {
+	if el = el.element(); el.sheme == nil {
+		return encodeShemeErr("'%s' no sheme description", sh.Name())
+	}
+
+	switch tp := sh.TypeEn(); tp {
+	case tagCHOICE:
+		return w.encodeChoice(el, sh)
+	case tagANY:
+		return w.encodeAny(el, sh)
+	case tagNULL:
+	case tagBOOLEAN:
+		val, err := el.value()
+		if err != nil {
+			return err
+		}
+		w.putBit(val.(bool))
+	case tagINTEGER:
+		val, err := el.value()
+		if err != nil {
+			return err
+		}
+		if v, ok := val.(int64); ok {
+			val = big.NewInt(v)
+		}
+		return w.putInteger(val.(*big.Int), sh)
+	case tagENUMERATED:
+		val, err := el.value()
+		if err != nil {
+			return err
+		}
+		name, id, ok := sh.enumItem(val)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		if name == "" {
+			// PER sends the index of an addition, which a value unknown to
+			// the sheme does not have
+			return encodeDataErr("'%s' %s value %d unknown to the sheme", sh.Name(), sh.Type(), id)
+		}
+		root, add := perEnumItems(sh)
+		if i := indexOf(root, id); i >= 0 {
+			if sh.Extensible() {
+				w.putBit(false)
+			}
+			w.putConstrained(uint64(i), uint64(len(root)-1))
+		} else {
+			w.putBit(true)
+			return w.putSmall(indexOf(add, id))
+		}
+	case tagBIT_STR:
+		data := el.data[1:]
+		return w.putSize(8*len(data)-int(el.data[0]), 1, sh, false, func(from, to int) error {
+			// a fragment holds whole octets but the last
+			w.putBytes(data[from/8 : to/8])
+			if to%8 != 0 {
+				w.putBits(uint64(data[to/8]>>uint(8-to%8)), to%8)
+			}
+			return nil
+		})
+	case tagOCTET_STR:
+		return w.putSize(len(el.data), 8, sh, false, func(from, to int) error {
+			w.putBytes(el.data[from:to])
+			return nil
+		})
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return w.encodeSequenceOf(el, sh)
+		}
+		return w.encodeSequence(el, sh)
+	default:
+		if b := perCharBits(tp, w.aligned); b > 0 {
+			return w.encodeChars(el, sh, b)
+		}
+		// REAL, OBJECT IDENTIFIER and the other character strings take
+		// their BER contents
+		return w.putOctets(el.data)
+	}
+	return nil
+}
+
+func (w *perWriter) encodeChars(el *AsnData, sh *Sheme, b int) error {
+	tp := sh.TypeEn()
+	width := perCharWidth(tp)
+	cons := sh
+	if tp == tagUTCTime || tp == tagGeneralizedTime {
+		// the time types are a VisibleString with no constraint
+		cons = nil
+	}
+	return w.putSize(len(el.data)/width, b, cons, true, func(from, to int) error {
+		for i := from * width; i < to*width; i += width {
+			var c uint64
+			for j := 0; j < width; j++ {
+				c = c<<8 | uint64(el.data[i+j])
+			}
+			if tp == tagNumericString {
+				// the characters of a NumericString are numbered from space
+				if c != ' ' {
+					c -= '0' - 1
+				} else {
+					c = 0
+				}
+			}
+			w.putBits(c, b)
+		}
+		return nil
+	})
+}
+
+func (w *perWriter) encodeSequence(el *AsnData, sh *Sheme) error {
+	root, add := perFields(sh)
+	groups := perGroups(add)
+	more := el.hasAny(add)
+	if sh.Extensible() {
+		w.putBit(more)
+	}
+	if err := w.putFields(el, sh, root); err != nil {
+		return err
+	}
+	if !more {
+		return nil
+	}
+	// the additions present are marked in a bitmap and sent as open types
+	if err := w.putSmallLength(len(groups)); err != nil {
+		return err
+	}
+	for _, grp := range groups {
+		w.putBit(el.hasAny(grp))
+	}
+	for _, grp := range groups {
+		if !el.hasAny(grp) {
+			continue
+		}
+		if f := grp[0]; f.Group() == 0 {
+			if err := w.putOpen(el.sub[f.ID()], f); err != nil {
+				return err
+			}
+			continue
+		}
+		sub := &perWriter{aligned: w.aligned}
+		if err := sub.putFields(el, sh, grp); err != nil {
+			return err
+		}
+		if err := w.putOctets(sub.complete()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putFields writes the fields fld of the SEQUENCE or SET el: the preamble
+// of the optional ones, then those set.
+func (w *perWriter) putFields(el *AsnData, sh *Sheme, fld []*Sheme) error {
+	for _, f := range fld {
+		if f.Optional() || f.DefAttr() != nil {
+			w.putBit(el.has(f))
+		}
+	}
+	for _, f := range fld {
+		if !el.has(f) {
+			if !sh.omissible(f, el.has) {
+				return encodeShemeErr("'%s' miss not optional field '%s'", sh.Name(), f.Name())
+			}
+			continue
+		}
+		if err := w.encode(el.sub[f.ID()], f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *perWriter) encodeSequenceOf(el *AsnData, sh *Sheme) error {
+	of := sh.Of()
+	return w.putSize(len(el.sub), 0, sh, false, func(from, to int) error {
+		for _, sub := range el.sub[from:to] {
+			if err := w.encode(sub, of); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// alternative returns the chosen element of a CHOICE or ANY and its sheme.
+// Encode replaces the CHOICE itself by the alternative in the tree.
+func alternative(el *AsnData, sh *Sheme) (*AsnData, *Sheme, error) {
+	if el.sheme.TypeEn() == sh.TypeEn() && el.sheme.Name() == sh.Name() && len(el.sub) == 1 {
+		if el.sub[0] == nil {
+			return nil, nil, encodeShemeErr("'%s' no alternative chosen", sh.Name())
+		}
+		el = el.sub[0].element()
+	}
+	if el.sheme == nil {
+		return nil, nil, encodeShemeErr("'%s' no sheme description", sh.Name())
+	}
+	alt, err := findField(sh, el.sheme.Name())
+	return el, alt, err
+}
+
+func (w *perWriter) encodeChoice(el *AsnData, sh *Sheme) error {
+	el, alt, err := alternative(el, sh)
+	if err != nil {
+		return err
+	}
+	root, add := perFields(sh)
+	for i, f := range root {
+		if f.Name() == alt.Name() {
+			if sh.Extensible() {
+				w.putBit(false)
+			}
+			w.putConstrained(uint64(i), uint64(len(root)-1))
+			return w.encode(el, alt)
+		}
+	}
+	for i, f := range add {
+		if f.Name() == alt.Name() {
+			w.putBit(true)
+			if err := w.putSmall(i); err != nil {
+				return err
+			}
+			return w.putOpen(el, alt)
+		}
+	}
+	return encodeShemeErr("'%s' does not contain the field '%s'", sh.Name(), alt.Name())
+}
+
+// encodeAny writes the value of an ANY as an open type; the decoder selects
+// its sheme by the ObjectDescriptor, as in BER.
+func (w *perWriter) encodeAny(el *AsnData, sh *Sheme) error {
+	el, alt, err := alternative(el, sh)
+	if err != nil {
+		return err
+	}
+	return w.putOpen(el, alt)
+}
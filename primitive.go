@@ -0,0 +1,482 @@
+package asn1dynamic
+
+import (
+	"encoding/binary"
+	"math/big"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The primitives below read and write single BER elements and the contents
+// of the universal types without a sheme. They are the building blocks of
+// the code made by GenerateGo.
+
+// Tag classes of an Element.
+const (
+	ClassUniversal       = classUniversal
+	ClassApplication     = classApplication
+	ClassContextSpecific = classContextSpecific
+	ClassPrivate         = classPrivate
+)
+
+// Element is a single BER element. Content and Raw share memory with the
+// data it was parsed from; Content of an element of indefinite length does
+// not hold the end-of-contents octets.
+type Element struct {
+	Class       int
+	Number      int
+	Constructed bool
+	Content     []byte
+	Raw         []byte
+}
+
+func (el Element) String() string {
+	tag := AsnTag{tagClass: el.Class, tagNumber: el.Number}
+	return tag.typeName()
+}
+
+// Is reports whether el carries the tag of the given class and number.
+func (el Element) Is(class, number int) bool {
+	return el.Class == class && el.Number == number
+}
+
+// Expect checks the tag of el, name tells the value in the error.
+func (el Element) Expect(name string, class, number int) error {
+	if !el.Is(class, number) {
+		tag := AsnTag{tagClass: class, tagNumber: number}
+		return Errorf("decode: processing '%s' expected %s field but got %s", name, tag.typeName(), el)
+	}
+	return nil
+}
+
+// ExpectConstructed checks the tag of el and that it is constructed.
+func (el Element) ExpectConstructed(name string, class, number int) error {
+	if err := el.Expect(name, class, number); err != nil {
+		return err
+	}
+	if !el.Constructed {
+		return decodeDataErr("'%s' not constructed", el)
+	}
+	return nil
+}
+
+// Unwrap replaces el by the only element within it, the value of an
+// explicit tag.
+func (el *Element) Unwrap() error {
+	if !el.Constructed {
+		return decodeDataErr("'%s' explicit tag not constructed", el)
+	}
+	in, rest, err := ParseElement(el.Content)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return decodeDataErr("'%s' explicit tag holds more than one element", el)
+	}
+	*el = in
+	return nil
+}
+
+// Octets returns the contents of a string of the universal type number,
+// joining the segments of the constructed form.
+func (el Element) Octets(number int) ([]byte, error) {
+	if !el.Constructed {
+		return el.Content, nil
+	}
+	var asn AsnData
+	if _, ok, err := asn.Parse(el.Raw); err != nil || !ok {
+		return nil, decodeDataErr("'%s' invalid segments", el)
+	}
+	asn.tag.tagClass = classUniversal
+	asn.tag.tagNumber = number
+	out, err := asn.flatten(&AsnContext{})
+	if err != nil {
+		return nil, err
+	}
+	if out.data == nil {
+		return []byte{}, nil
+	}
+	return out.data, nil
+}
+
+// ParseElement reads the element at the start of data and returns the rest
+// of data.
+func ParseElement(data []byte) (Element, []byte, error) {
+	var el Element
+	if len(data) < 2 {
+		return el, data, decodeDataErr("truncated element")
+	}
+	var tag AsnTag
+	pos, err := tag.parse(data)
+	if err != nil {
+		return el, data, err
+	}
+	el.Class, el.Number, el.Constructed = tag.tagClass, tag.tagNumber, tag.tagConstructed
+
+	n := int(data[pos])
+	pos++
+	if n == 0x80 {
+		var asn AsnData
+		rest, ok, err := asn.Parse(data)
+		if err != nil {
+			return el, data, err
+		}
+		if !ok {
+			return el, data, decodeDataErr("'%s' truncated element", el)
+		}
+		el.Content, el.Raw = asn.data, asn.fdata
+		return el, rest, nil
+	}
+	if n > 0x80 {
+		num := n & 0x7f
+		if num > 4 || len(data)-pos < num {
+			return el, data, decodeDataErr("'%s' invalid length", el)
+		}
+		n = 0
+		for i := 0; i < num; i++ {
+			n = n<<8 | int(data[pos])
+			pos++
+		}
+	}
+	if n < 0 || len(data)-pos < n {
+		return el, data, decodeDataErr("'%s' truncated element", el)
+	}
+	el.Raw = data[:pos+n]
+	el.Content = data[pos : pos+n]
+	return el, data[pos+n:], nil
+}
+
+// ParseElements reads the elements of data, the contents of a constructed
+// element.
+func ParseElements(data []byte) ([]Element, error) {
+	var out []Element
+	for len(data) > 0 {
+		el, rest, err := ParseElement(data)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, el)
+		data = rest
+	}
+	return out, nil
+}
+
+// AppendElement appends the identifier and length octets of an element and
+// its content to dst.
+func AppendElement(dst []byte, class, number int, constructed bool, content []byte) []byte {
+	var th AsnData
+	th.tag.tagClass = class
+	th.tag.tagNumber = number
+	th.tag.tagConstructed = constructed
+	th.len = len(content)
+	dst = appendTagAndLength(&th, dst)
+	return append(dst, content...)
+}
+
+func AppendBoolean(dst []byte, val bool) []byte {
+	if val {
+		return append(dst, 0xff)
+	}
+	return append(dst, 0x00)
+}
+
+func ParseBoolean(data []byte) (bool, error) {
+	if len(data) != 1 {
+		return false, decodeDataErr("BOOLEAN wrong length %d", len(data))
+	}
+	switch data[0] {
+	case 0:
+		return false, nil
+	case 0xff:
+		return true, nil
+	}
+	return false, decodeDataErr("BOOLEAN wrong value %x", data[0])
+}
+
+// AppendInteger appends the minimal two's complement form of val.
+func AppendInteger(dst []byte, val int64) []byte {
+	num := 1
+	for i := val; i > 127 || i < -128; i >>= 8 {
+		num++
+	}
+	for ; num > 0; num-- {
+		dst = append(dst, byte(val>>uint((num-1)*8)))
+	}
+	return dst
+}
+
+func AppendBigInteger(dst []byte, val *big.Int) []byte {
+	return append(dst, encodeBigInt(val)...)
+}
+
+func checkIntegerData(data []byte) error {
+	if len(data) == 0 {
+		return decodeDataErr("INTEGER zero length")
+	}
+	if len(data) > 1 && (data[0] == 0 && data[1]&0x80 == 0 || data[0] == 0xff && data[1]&0x80 == 0x80) {
+		return decodeDataErr("INTEGER not minimally-encoded")
+	}
+	return nil
+}
+
+func ParseInteger(data []byte) (int64, error) {
+	if err := checkIntegerData(data); err != nil {
+		return 0, err
+	}
+	if len(data) > 8 {
+		return 0, decodeDataErr("INTEGER too large len: %d", len(data))
+	}
+	var ret int64
+	for _, b := range data {
+		ret = ret<<8 | int64(b)
+	}
+	// sign extend the result
+	ret <<= 64 - uint8(len(data))*8
+	ret >>= 64 - uint8(len(data))*8
+	return ret, nil
+}
+
+func ParseBigInteger(data []byte) (*big.Int, error) {
+	if err := checkIntegerData(data); err != nil {
+		return nil, err
+	}
+	return twosComplement(data), nil
+}
+
+func ParseReal(data []byte) (float64, error) {
+	switch {
+	case len(data) == 0:
+		return 0.0, nil
+	case data[0]&0x80 != 0:
+		return decode_real_binary(data)
+	case data[0]&0x40 != 0:
+		return decode_real_special(data[0])
+	}
+	return decode_real_decimal(data)
+}
+
+// AppendBitString appends the unused bits octet and the bits of val, the
+// unused bits set to zero.
+func AppendBitString(dst []byte, val BitStr) ([]byte, error) {
+	num := (val.BitLength + 7) / 8
+	if val.BitLength < 0 || len(val.Bytes) < num {
+		return dst, encodeDataErr("BIT STRING invalid bit length: %d", val.BitLength)
+	}
+	pad := byte((8 - val.BitLength%8) % 8)
+	dst = append(dst, pad)
+	dst = append(dst, val.Bytes[:num]...)
+	// unused bits are zero
+	if num > 0 {
+		dst[len(dst)-1] &^= 1<<pad - 1
+	}
+	return dst, nil
+}
+
+func ParseBitString(data []byte) (BitStr, error) {
+	var ret BitStr
+	if len(data) == 0 {
+		return ret, decodeDataErr("BIT STRING zero length")
+	}
+	paddingBits := int(data[0])
+	if paddingBits > 7 ||
+		len(data) == 1 && paddingBits > 0 ||
+		data[len(data)-1]&((1<<data[0])-1) != 0 {
+		return ret, decodeDataErr("BIT STRING invalid padding bits")
+	}
+	ret.BitLength = (len(data)-1)*8 - paddingBits
+	ret.Bytes = data[1:]
+	return ret, nil
+}
+
+func AppendObjectIdentifier(dst []byte, val OID) ([]byte, error) {
+	if len(val) < 2 || val[0] < 0 || val[0] > 2 || val[0] < 2 && val[1] >= 40 {
+		return dst, encodeDataErr("OBJECT IDENTIFIER invalid value: %s", val)
+	}
+	dst = appendBase128Int(dst, int64(val[0]*40+val[1]))
+	for i := 2; i < len(val); i++ {
+		if val[i] < 0 {
+			return dst, encodeDataErr("OBJECT IDENTIFIER invalid value: %s", val)
+		}
+		dst = appendBase128Int(dst, int64(val[i]))
+	}
+	return dst, nil
+}
+
+func ParseObjectIdentifier(data []byte) (OID, error) {
+	if len(data) == 0 {
+		return nil, decodeDataErr("OBJECT IDENTIFIER zero length")
+	}
+	// In the worst case, we get two elements from the first byte (which is
+	// encoded differently) and then every varint is a single byte long.
+	res := make(OID, len(data)+1)
+
+	// The first varint is 40*value1 + value2: value1 can take the values 0,
+	// 1 and 2 only. When value1 = 0 or value1 = 1, then value2 is <= 39.
+	// When value1 = 2, then there are no restrictions on value2.
+	v, offset, err := parseBase128Int(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if v < 80 {
+		res[0] = v / 40
+		res[1] = v % 40
+	} else {
+		res[0] = 2
+		res[1] = v - 80
+	}
+
+	i := 2
+	for ; offset < len(data); i++ {
+		if v, offset, err = parseBase128Int(data, offset); err != nil {
+			return nil, err
+		}
+		res[i] = v
+	}
+	return res[:i], nil
+}
+
+// AppendString appends the characters of val as the string of the universal
+// type number, checking them against its character set.
+func AppendString(dst []byte, number int, val string) ([]byte, error) {
+	if number == tagBMPString || number == tagUniversalString {
+		if !utf8.ValidString(val) {
+			return dst, encodeDataErr("%s invalid UTF-8 string", typeName(number))
+		}
+		for _, r := range val {
+			if number == tagUniversalString {
+				dst = append(dst, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+				continue
+			}
+			if r > 0xffff || utf16.IsSurrogate(r) {
+				return dst, encodeDataErr("%s contains invalid character: %c", typeName(number), r)
+			}
+			dst = append(dst, byte(r>>8), byte(r))
+		}
+		return dst, nil
+	}
+	if err := checkString(number, val); err != nil {
+		return dst, err
+	}
+	return append(dst, val...), nil
+}
+
+// ParseString reads the contents of a string of the universal type number
+// into a UTF-8 string.
+func ParseString(number int, data []byte) (string, error) {
+	switch number {
+	case tagBMPString:
+		if len(data)%2 != 0 {
+			return "", decodeDataErr("%s odd length: %d", typeName(number), len(data))
+		}
+		buf := make([]byte, 0, len(data))
+		for i := 0; i < len(data); i += 2 {
+			r := rune(data[i])<<8 | rune(data[i+1])
+			if utf16.IsSurrogate(r) {
+				return "", decodeDataErr("%s contains invalid character: %x", typeName(number), r)
+			}
+			buf = append(buf, string(r)...)
+		}
+		return string(buf), nil
+	case tagUniversalString:
+		if len(data)%4 != 0 {
+			return "", decodeDataErr("%s length is not a multiple of 4: %d", typeName(number), len(data))
+		}
+		buf := make([]byte, 0, len(data))
+		for i := 0; i < len(data); i += 4 {
+			r := rune(binary.BigEndian.Uint32(data[i:]))
+			if !utf8.ValidRune(r) {
+				return "", decodeDataErr("%s contains invalid character: %x", typeName(number), uint32(r))
+			}
+			buf = append(buf, string(r)...)
+		}
+		return string(buf), nil
+	}
+	str := string(data)
+	if err := checkString(number, str); err != nil {
+		return "", err
+	}
+	return str, nil
+}
+
+// checkString checks the characters of a string of one octet per character.
+func checkString(number int, val string) error {
+	valid := func(b byte) bool { return true }
+	switch number {
+	case tagNumericString:
+		valid = isNumeric
+	case tagPrintableString:
+		valid = func(b byte) bool { return isPrintable(b, true, true) }
+	case tagIA5String:
+		valid = func(b byte) bool { return b < utf8.RuneSelf }
+	case tagVisibleString:
+		valid = isVisible
+	case tagGraphicString:
+		valid = isGraphic
+	case tagUTF8String:
+		if !utf8.ValidString(val) {
+			return Errorf("%s invalid UTF-8 string", typeName(number))
+		}
+	}
+	for i := 0; i < len(val); i++ {
+		if !valid(val[i]) {
+			return Errorf("%s contains invalid character: %x", typeName(number), val[i])
+		}
+	}
+	return nil
+}
+
+// AppendUTCTime appends val in format, by default without seconds as the
+// UTCTime encoder of a sheme.
+func AppendUTCTime(dst []byte, val time.Time, format string) []byte {
+	if format == "" {
+		format = "0601021504Z0700"
+	}
+	return val.AppendFormat(dst, format)
+}
+
+func AppendGeneralizedTime(dst []byte, val time.Time) []byte {
+	return val.AppendFormat(dst, "20060102150405Z0700")
+}
+
+// ParseUTCTime reads a UTCTime in format or, by default, with or without
+// seconds.
+func ParseUTCTime(data []byte, format string) (time.Time, error) {
+	s := string(data)
+	formatStr := format
+	if formatStr == "" {
+		formatStr = "0601021504Z0700"
+	}
+	ret, err := time.Parse(formatStr, s)
+	if err != nil && format == "" {
+		formatStr = "060102150405Z0700"
+		ret, err = time.Parse(formatStr, s)
+	}
+	if err != nil {
+		return ret, decodeDataErr("UTCTime %s", err)
+	}
+	if serialized := ret.Format(formatStr); serialized != s {
+		return ret, decodeDataErr("UTCTime did not serialize back to the original value: given %q, but serialized as %q", s, serialized)
+	}
+	if ret.Year() >= 2050 {
+		// UTCTime only encodes times prior to 2050
+		ret = ret.AddDate(-100, 0, 0)
+	}
+	return ret, nil
+}
+
+func ParseGeneralizedTime(data []byte) (time.Time, error) {
+	s := string(data)
+	formatStr := "20060102150405Z0700"
+	ret, err := time.Parse(formatStr, s)
+	if err != nil {
+		return ret, decodeDataErr("GeneralizedTime %s", err)
+	}
+	if ret.Nanosecond() != 0 {
+		formatStr = "20060102150405.999999999Z0700"
+	}
+	if serialized := ret.Format(formatStr); serialized != s {
+		return ret, decodeDataErr("GeneralizedTime did not serialize back to the original value: given %q, but serialized as %q", s, serialized)
+	}
+	return ret, nil
+}
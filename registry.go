@@ -0,0 +1,247 @@
+package asn1dynamic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+// Registry holds the modules of a protocol stack. Classes of one module
+// refer to classes of another either by a qualified "Module.Class" name or
+// by listing them in '$imports'.
+type Registry struct {
+	modules map[string]*Sheme
+	pending map[string]*asnModule
+}
+
+func NewRegistry() *Registry {
+	return &Registry{modules: make(map[string]*Sheme), pending: make(map[string]*asnModule)}
+}
+
+func (r *Registry) add(name string, obj *simplejson.Json) error {
+	if name == "" {
+		return fmt.Errorf("registry: miss '$module' name")
+	}
+	if _, f := r.modules[name]; f {
+		return fmt.Errorf("registry: duplicate module '%s'", name)
+	}
+	sh := &Sheme{obj: obj, name: name, reg: r}
+	if err := sh.init(); err != nil {
+		return fmt.Errorf("registry: module '%s': %s", name, err.Error())
+	}
+	if sh.name != name {
+		return fmt.Errorf("registry: module '%s' declared as '%s'", name, sh.name)
+	}
+	r.modules[name] = sh
+	return nil
+}
+
+// Load adds a JSON sheme module. The module is named by its '$module'
+// attribute.
+func (r *Registry) Load(data []byte) error {
+	obj, err := simplejson.NewJson(data)
+	if err != nil {
+		return err
+	}
+	return r.add(obj.Get("$module").MustString(), obj)
+}
+
+// LoadReader adds a JSON sheme module read from rd.
+func (r *Registry) LoadReader(rd io.Reader) error {
+	obj, err := simplejson.NewFromReader(rd)
+	if err != nil {
+		return err
+	}
+	return r.add(obj.Get("$module").MustString(), obj)
+}
+
+// LoadASN1 adds the modules of an ASN.1 text. They are compiled by Link,
+// once the modules they import from are loaded as well.
+func (r *Registry) LoadASN1(data []byte) error {
+	mods, err := parseASN1(data)
+	if err != nil {
+		return err
+	}
+	for _, m := range mods {
+		if _, f := r.modules[m.name]; f {
+			return fmt.Errorf("registry: duplicate module '%s'", m.name)
+		}
+		if _, f := r.pending[m.name]; f {
+			return fmt.Errorf("registry: duplicate module '%s'", m.name)
+		}
+	}
+	for _, m := range mods {
+		m.link = r
+		r.pending[m.name] = m
+	}
+	return nil
+}
+
+// LoadFile adds the modules of a file: ASN.1 text for the .asn and .asn1
+// extensions and a JSON sheme otherwise. A JSON sheme without '$module'
+// is named after the file.
+func (r *Registry) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".asn" || ext == ".asn1" {
+		return r.LoadASN1(data)
+	}
+	obj, err := simplejson.NewJson(data)
+	if err != nil {
+		return fmt.Errorf("registry: %s: %s", path, err.Error())
+	}
+	name := obj.Get("$module").MustString()
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		obj.Set("$module", name)
+	}
+	return r.add(name, obj)
+}
+
+// target follows the chain of type references from a class of another
+// module, as asnModule.target does.
+func (r *Registry) target(mod, name string, stack []string) (map[string]interface{}, error) {
+	if m, f := r.pending[mod]; f {
+		return m.target(name, stack)
+	}
+	if m, f := r.modules[mod]; f {
+		if cls := m.Class(name); cls != nil {
+			tp, _ := cls.obj.Map()
+			return tp, nil
+		}
+	}
+	return nil, Errorf("registry: unknown class '%s.%s'", mod, name)
+}
+
+// resolve expands a class of another module, as asnModule.resolve does.
+func (r *Registry) resolve(mod, name string, stack []string) (map[string]interface{}, error) {
+	if m, f := r.pending[mod]; f {
+		return m.resolve(name, stack)
+	}
+	if m, f := r.modules[mod]; f {
+		if tp := m.classMap(name); tp != nil {
+			return tp, nil
+		}
+	}
+	return nil, Errorf("registry: unknown class '%s.%s'", mod, name)
+}
+
+func (r *Registry) value(mod, name string) (int, error) {
+	if m, f := r.pending[mod]; f {
+		return m.value(name)
+	}
+	return 0, Errorf("registry: unknown value '%s.%s'", mod, name)
+}
+
+// Link compiles the loaded ASN.1 modules and checks that every reference
+// between modules resolves, and that no chain of references is cyclic.
+// The classes of every module are compiled then for fast encoding and
+// decoding; until then they are read from the JSON objects.
+func (r *Registry) Link() error {
+	names := make([]string, 0, len(r.pending))
+	for name := range r.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := r.pending[name].expand(); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		data, err := json.Marshal(r.pending[name].sheme())
+		if err != nil {
+			return err
+		}
+		if err := r.Load(data); err != nil {
+			return err
+		}
+		delete(r.pending, name)
+	}
+
+	for _, name := range r.Modules() {
+		m := r.modules[name]
+		for sym, mod := range m.imports {
+			if r.modules[mod] == nil {
+				return fmt.Errorf("registry: '%s' imports from unknown module '%s'", name, mod)
+			}
+			if r.modules[mod].classMap(sym) == nil {
+				return fmt.Errorf("registry: '%s' imports unknown class '%s' from '%s'", name, sym, mod)
+			}
+		}
+		for _, cls := range m.Classes() {
+			if err := m.checkChain(cls, true); err != nil {
+				return fmt.Errorf("registry: module '%s': %s", name, err.Error())
+			}
+			if err := linkRefs(m.raw(m.classMap(cls), cls), cls); err != nil {
+				return fmt.Errorf("registry: module '%s': %s", name, err.Error())
+			}
+		}
+	}
+	cp := newCompiler()
+	for _, name := range r.Modules() {
+		r.modules[name].compile(cp)
+	}
+	return nil
+}
+
+// linkRefs checks that every reference inside a class resolves. Referenced
+// classes are not entered, so recursive classes terminate.
+func linkRefs(sh *Sheme, class string) error {
+	if ref := sh.Ref(); ref != "" {
+		if cls, _, _ := sh.lookup(ref); cls == nil {
+			return fmt.Errorf("unknown class '%s' referenced in '%s'", ref, class)
+		}
+		return nil
+	}
+	if of := sh.OfAttr(); of != nil {
+		return linkRefs(sh.raw(of, sh.Name()), class)
+	}
+	if sh.TypeEn() == tagENUMERATED {
+		return nil
+	}
+	for k, v := range sh.FieldAttr() {
+		if itm, ok := v.(map[string]interface{}); ok {
+			if err := linkRefs(sh.raw(itm, k), class); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Modules returns the names of the compiled modules.
+func (r *Registry) Modules() []string {
+	out := make([]string, 0, len(r.modules))
+	for name := range r.modules {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Module returns the root sheme of a module, or nil.
+func (r *Registry) Module(name string) *Sheme {
+	return r.modules[name]
+}
+
+// Class returns a class by its qualified "Module.Class" name, or nil.
+func (r *Registry) Class(name string) *Sheme {
+	i := strings.LastIndex(name, ".")
+	if i <= 0 {
+		return nil
+	}
+	if m := r.modules[name[:i]]; m != nil {
+		return m.Class(name[i+1:])
+	}
+	return nil
+}
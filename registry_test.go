@@ -0,0 +1,68 @@
+package asn1dynamic
+
+import (
+	"testing"
+)
+
+func TestRegistryCyclicReference(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadASN1([]byte(`
+A DEFINITIONS ::= BEGIN
+IMPORTS Y FROM B;
+X ::= Y
+END
+B DEFINITIONS ::= BEGIN
+IMPORTS X FROM A;
+Y ::= X
+END
+`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Link(); err == nil {
+		t.Fatal("cyclic reference between modules linked")
+	}
+}
+
+func TestRegistryMutualRecursion(t *testing.T) {
+	r := NewRegistry()
+	if err := r.LoadASN1([]byte(`
+A DEFINITIONS AUTOMATIC TAGS ::= BEGIN
+IMPORTS Y FROM B;
+X ::= SEQUENCE { n INTEGER, y Y }
+END
+B DEFINITIONS AUTOMATIC TAGS ::= BEGIN
+IMPORTS X FROM A;
+Y ::= SEQUENCE { x X OPTIONAL }
+END
+`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Link(); err != nil {
+		t.Fatal(err)
+	}
+	x := r.Class("A.X")
+	if x == nil {
+		t.Fatal("no A.X")
+	}
+	val := map[string]interface{}{
+		"n": 1,
+		"y": map[string]interface{}{
+			"x": map[string]interface{}{"n": 2, "y": map[string]interface{}{}},
+		},
+	}
+	data, err := Encode(x, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder()
+	if _, _, err := dec.Parse(data); err != nil {
+		t.Fatal(err)
+	}
+	js, err := dec.Decode(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := js.Get("y").Get("x").Get("n").MustInt(); n != 2 {
+		t.Errorf("decoded %v", js.Interface())
+	}
+}
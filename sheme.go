@@ -1,26 +1,67 @@
 package asn1dynamic
 
 import (
-	"container/list"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 
 	"github.com/anton-zolotarev/go-simplejson"
 )
 
+// maxRefDepth limits chains of class references resolved for a single sheme.
+const maxRefDepth = 64
+
+const (
+	// extensionMarker is the key of the extension marker in a '$field' list.
+	extensionMarker = "..."
+	// unknownKey holds the raw encodings of the extensions a decoded value
+	// has beyond its sheme.
+	unknownKey = "$unknown"
+)
+
 type Sheme struct {
 	name string
 	obj  *simplejson.Json
+	root *Sheme
+
+	reg     *Registry
+	imports map[string]string
+	tagging string
+
+	c       *compiled
+	classes map[string]*Sheme
+}
+
+func isBuiltin(tp string) bool {
+	return typeTag(tp) != tagEOC || tp == "EOC"
 }
 
 func check(sh *Sheme, name string) error {
+	if cls, f := sh.obj.CheckGet("$class"); f {
+		switch cls.MustString() {
+		case "APPLICATION", "PRIVATE", "CONTEXT":
+		default:
+			return fmt.Errorf("invalid '$class' in '%s'", name)
+		}
+		if !sh.Tagged() {
+			return fmt.Errorf("'$class' without '$tag' in '%s'", name)
+		}
+	}
+	if ref := sh.Ref(); ref != "" {
+		if cls, _, _ := sh.lookup(ref); cls == nil && !sh.top().external(ref) {
+			return fmt.Errorf("unknown class '%s' referenced in '%s'", ref, name)
+		}
+		return nil
+	}
+
 	tp := sh.Type()
 	of := sh.OfAttr()
 	fl := sh.FieldAttr()
 	switch tp {
 	case "":
 		return fmt.Errorf("miss '$type' in '%s'", name)
-	case "CHOICE", "SEQUENCE", "ANY":
+	case "CHOICE", "SEQUENCE", "SET", "ANY":
 		if of == nil && fl == nil {
 			return fmt.Errorf("miss '$field' or '$of' in '%s' (%s)", name, tp)
 		}
@@ -30,13 +71,14 @@ func check(sh *Sheme, name string) error {
 	}
 
 	if of != nil {
-		return check(Wrap(of), name)
+		return check(sh.raw(of, name), name)
 	}
 
 	if fl != nil {
 		var ids map[int]bool
-		var tgs map[int]bool
-		fld, err := NewFieldList(fl)
+		var tgs map[[2]int]bool
+		var stg map[[2]int]bool
+		fld, err := newFieldList(fl, sh.raw)
 		if err != nil {
 			return err
 		}
@@ -61,14 +103,36 @@ func check(sh *Sheme, name string) error {
 				}
 				if tp == "CHOICE" {
 					if len(tgs) == 0 {
-						tgs = make(map[int]bool)
+						tgs = make(map[[2]int]bool)
 					}
-					id := sh.Index()
-					if _, f := tgs[id]; f {
-						return fmt.Errorf("duplicate $tag '%d' in '%s' field of '%s' (%s)", id, sh.Name(), name, tp)
+					rsh := sh.resolve()
+					key, ok := [2]int{rsh.TagClass(), rsh.Index()}, true
+					if sh.obj.Get("$untagged").MustBool() {
+						key, ok = rsh.outerTag()
+					}
+					if _, f := tgs[key]; f && ok {
+						return fmt.Errorf("duplicate $tag '%d' in '%s' field of '%s' (%s)", key[1], sh.Name(), name, tp)
+					}
+					if ok {
+						tgs[key] = true
+					}
+				}
+				if tp == "SET" {
+					// components of a SET are told apart by their tags
+					if len(stg) == 0 {
+						stg = make(map[[2]int]bool)
+					}
+					rsh := sh.resolve()
+					key := [2]int{rsh.TagClass(), rsh.Index()}
+					if !rsh.Tagged() {
+						key = [2]int{classUniversal, rsh.TypeEn()}
+					}
+					if rsh.Tagged() || (rsh.Ref() == "" && key[1] > tagEOC) {
+						if stg[key] {
+							return fmt.Errorf("duplicate tag of '%s' field in '%s' (%s)", sh.Name(), name, tp)
+						}
+						stg[key] = true
 					}
-					sh.obj.Set("$tag", id)
-					tgs[id] = true
 				}
 				if err := check(sh, sh.Name()); err != nil {
 					return err
@@ -85,10 +149,21 @@ func (s *Sheme) init() error {
 		return err
 	}
 
+	s.obj = obj
+	if err = s.initExtensions(); err != nil {
+		return err
+	}
+	if err = s.initModule(); err != nil {
+		return err
+	}
+
 	mp, _ := obj.Map()
 	for k, v := range mp {
+		if strings.HasPrefix(k, "$") {
+			continue
+		}
 		if j, ok := v.(map[string]interface{}); ok {
-			if err = check(Wrap(j), k); err != nil {
+			if err = check(s.raw(j, k), k); err != nil {
 				return err
 			}
 		} else {
@@ -96,51 +171,500 @@ func (s *Sheme) init() error {
 		}
 	}
 
-	s.obj = obj
+	for _, k := range s.Classes() {
+		if err = s.checkChain(k, false); err != nil {
+			return err
+		}
+	}
+	if s.reg == nil {
+		// modules of a Registry are compiled by Link
+		s.compile(newCompiler())
+	}
+	return nil
+}
+
+// initModule reads the module attributes of a sheme: '$module' names the
+// module, '$tagging' sets its tagging environment (see initTagging) and
+// '$imports' lists the classes taken from other modules of a Registry, as
+// {"Module": ["Class", ...]}.
+func (s *Sheme) initModule() error {
+	if mod, err := s.obj.Get("$module").String(); err == nil {
+		s.name = mod
+	}
+	if err := s.initTagging(); err != nil {
+		return err
+	}
+	imp, ok := s.obj.CheckGet("$imports")
+	if !ok {
+		return nil
+	}
+	mp, err := imp.Map()
+	if err != nil {
+		return fmt.Errorf("invalid '$imports' in '%s'", s.name)
+	}
+	s.imports = make(map[string]string)
+	for mod, v := range mp {
+		lst, err := simplejson.Wrap(v).StringArray()
+		if err != nil {
+			return fmt.Errorf("invalid '$imports' of '%s' in '%s'", mod, s.name)
+		}
+		for _, cls := range lst {
+			if prev, f := s.imports[cls]; f {
+				return fmt.Errorf("'%s' imported from both '%s' and '%s' in '%s'", cls, prev, mod, s.name)
+			}
+			if s.classMap(cls) != nil {
+				return fmt.Errorf("imported '%s' is also defined in '%s'", cls, s.name)
+			}
+			s.imports[cls] = mod
+		}
+	}
 	return nil
 }
 
+// initTagging applies the tagging environment of the module, the
+// EXPLICIT, IMPLICIT or AUTOMATIC '$tagging', to its classes: a tag not
+// marked '$implicit' or '$explicit' is marked by it, and with AUTOMATIC the
+// components of a SEQUENCE, SET or CHOICE none of which is tagged are
+// tagged by their position, X.680 25.3. A tagged CHOICE or ANY stays
+// explicit in any case.
+func (s *Sheme) initTagging() error {
+	tg, ok := s.obj.CheckGet("$tagging")
+	if !ok {
+		return nil
+	}
+	s.tagging, _ = tg.String()
+	switch s.tagging {
+	case "EXPLICIT", "IMPLICIT", "AUTOMATIC":
+	default:
+		return fmt.Errorf("invalid '$tagging' in '%s'", s.name)
+	}
+	mp, _ := s.obj.Map()
+	for k, v := range mp {
+		if itm, ok := v.(map[string]interface{}); ok && !strings.HasPrefix(k, "$") {
+			if err := applyTagging(itm, s.tagging); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func markTagging(itm map[string]interface{}, tagging string) {
+	if itm["$implicit"] == true || itm["$explicit"] == true {
+		return
+	}
+	if tagging == "EXPLICIT" {
+		itm["$explicit"] = true
+	} else {
+		itm["$implicit"] = true
+	}
+}
+
+func applyTagging(itm map[string]interface{}, tagging string) error {
+	if _, f := itm["$tag"]; f {
+		markTagging(itm, tagging)
+	}
+	if in, ok := itm["$inner"].(map[string]interface{}); ok {
+		if err := applyTagging(in, tagging); err != nil {
+			return err
+		}
+	}
+	if of, ok := itm["$of"].(map[string]interface{}); ok {
+		if err := applyTagging(of, tagging); err != nil {
+			return err
+		}
+	}
+	fld, ok := itm["$field"].(map[string]interface{})
+	tp := itm["$type"]
+	if !ok || tp == "ENUMERATED" {
+		return nil
+	}
+	lst, err := NewFieldList(fld)
+	if err != nil {
+		return err
+	}
+	auto := tagging == "AUTOMATIC" && (tp == "SEQUENCE" || tp == "SET" || tp == "CHOICE")
+	for sh := lst.Begin(); sh != nil && auto; sh = lst.Next() {
+		auto = !sh.Tagged()
+	}
+	n := 0
+	for sh := lst.Begin(); sh != nil; sh = lst.Next() {
+		c, _ := sh.obj.Map()
+		if auto {
+			c["$tag"] = n
+			n++
+		}
+		if err = applyTagging(c, tagging); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initExtensions replaces the extension markers of the classes, "..."
+// entries of a '$field' list with the '$id' they follow, by the attributes
+// the ASN.1 parser sets: the type is '$extensible' and every component with
+// a greater '$id' is an '$extension' addition, optional in a SEQUENCE or SET
+// unless it belongs to a '$group'. Root components following the additions
+// cannot be told by a marker; such a type is written with the attributes
+// instead, as the parser writes it.
+func (s *Sheme) initExtensions() error {
+	mp, _ := s.obj.Map()
+	for k, v := range mp {
+		if itm, ok := v.(map[string]interface{}); ok && !strings.HasPrefix(k, "$") {
+			if err := applyExtensions(itm, k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func applyExtensions(itm map[string]interface{}, name string) error {
+	if of, ok := itm["$of"].(map[string]interface{}); ok {
+		return applyExtensions(of, name)
+	}
+	fld, ok := itm["$field"].(map[string]interface{})
+	if !ok || itm["$type"] == "ENUMERATED" {
+		return nil
+	}
+	if mk, f := fld[extensionMarker]; f {
+		id, err := simplejson.Wrap(mk).Get("$id").Int()
+		if err != nil {
+			return fmt.Errorf("miss '$id' of the extension marker in '%s'", name)
+		}
+		delete(fld, extensionMarker)
+		itm["$extensible"] = true
+		for _, v := range fld {
+			c, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if n, err := simplejson.Wrap(c).Get("$id").Int(); err == nil && n > id {
+				c["$extension"] = true
+				if _, g := c["$group"]; itm["$type"] != "CHOICE" && !g {
+					c["$optional"] = true
+				}
+			}
+		}
+	}
+	for k, v := range fld {
+		if c, ok := v.(map[string]interface{}); ok {
+			if err := applyExtensions(c, k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// external reports whether ref names a class of another module, which can
+// only be checked once all modules of the registry are loaded.
+func (s *Sheme) external(ref string) bool {
+	if s.reg == nil {
+		return false
+	}
+	_, f := s.imports[ref]
+	return f || strings.Contains(ref, ".")
+}
+
+// checkChain verifies that a chain of plain references starting at class
+// ends in a type. With strict set every link must be resolvable.
+func (s *Sheme) checkChain(class string, strict bool) error {
+	type link struct {
+		owner *Sheme
+		name  string
+	}
+	sh := s.raw(s.classMap(class), class)
+	seen := map[link]bool{{s.top(), class}: true}
+	for ref := sh.Ref(); ref != ""; ref = sh.Ref() {
+		cls, owner, name := sh.lookup(ref)
+		if cls == nil {
+			if strict {
+				return fmt.Errorf("unknown class '%s' referenced in '%s'", ref, class)
+			}
+			return nil
+		}
+		if seen[link{owner, name}] {
+			return fmt.Errorf("cyclic reference in class '%s'", class)
+		}
+		seen[link{owner, name}] = true
+		sh = owner.raw(cls, name)
+	}
+	return nil
+}
+
+func (s *Sheme) top() *Sheme {
+	if s.root != nil {
+		return s.root
+	}
+	return s
+}
+
+func (s *Sheme) classMap(class string) map[string]interface{} {
+	if strings.HasPrefix(class, "$") {
+		return nil
+	}
+	mp, _ := s.top().obj.Get(class).Map()
+	return mp
+}
+
+// lookup finds the class named by a reference in the own module, among the
+// imported classes or by a qualified "Module.Class" name, and returns it
+// with the root of the module it belongs to and its name there.
+func (s *Sheme) lookup(ref string) (map[string]interface{}, *Sheme, string) {
+	top := s.top()
+	if cls := top.classMap(ref); cls != nil {
+		return cls, top, ref
+	}
+	if top.reg == nil {
+		return nil, nil, ""
+	}
+	mod, name := top.imports[ref], ref
+	if i := strings.LastIndex(ref, "."); mod == "" && i > 0 {
+		mod, name = ref[:i], ref[i+1:]
+	}
+	if m := top.reg.Module(mod); m != nil {
+		if cls := m.classMap(name); cls != nil {
+			return cls, m, name
+		}
+	}
+	return nil, nil, ""
+}
+
+// raw wraps a sheme object of the same root without resolving references.
+func (s *Sheme) raw(itm map[string]interface{}, name string) *Sheme {
+	return &Sheme{obj: simplejson.Wrap(itm), name: name, root: s.top()}
+}
+
+func (s *Sheme) wrap(itm map[string]interface{}, name string) *Sheme {
+	return s.raw(itm, name).resolve()
+}
+
+// field wraps a component of s without resolving it. An alternative of a
+// CHOICE that is not tagged is tagged by its '$id', unless it is marked
+// '$untagged'; the sheme itself is left as it is.
+func (s *Sheme) field(itm map[string]interface{}, name string) *Sheme {
+	sh := s.raw(itm, name)
+	if s.TypeEn() != tagCHOICE || itm["$untagged"] == true {
+		return sh
+	}
+	rsh := sh.resolve()
+	if rsh.Tagged() {
+		return sh
+	}
+	cp := make(map[string]interface{}, len(itm)+1)
+	for k, v := range itm {
+		cp[k] = v
+	}
+	cp["$tag"] = rsh.Index()
+	if tg := s.top().tagging; tg != "" {
+		markTagging(cp, tg)
+	}
+	return s.raw(cp, name)
+}
+
+// component wraps a component of s, resolved.
+func (s *Sheme) component(itm map[string]interface{}, name string) *Sheme {
+	return s.field(itm, name).resolve()
+}
+
+// Ref returns the name of the class the sheme refers to by '$ref' or by a
+// '$type' naming a top level class, or an empty string.
+func (s *Sheme) Ref() string {
+	if s.c != nil {
+		return ""
+	}
+	if ref, err := s.obj.Get("$ref").String(); err == nil {
+		return ref
+	}
+	if tp := s.Type(); tp != "" && !isBuiltin(tp) {
+		return tp
+	}
+	return ""
+}
+
+// resolve merges a reference with the class it refers to. Attributes set
+// on the reference itself ($id, $tag, $optional, ...) take precedence.
+func (s *Sheme) resolve() *Sheme {
+	for i := 0; i < maxRefDepth; i++ {
+		ref := s.Ref()
+		if ref == "" {
+			return s
+		}
+		cls, owner, _ := s.lookup(ref)
+		if cls == nil {
+			return s
+		}
+		itm := make(map[string]interface{}, len(cls)+4)
+		for k, v := range cls {
+			itm[k] = v
+		}
+		mp, _ := s.obj.Map()
+		_, tagged := mp["$tag"]
+		if tagged {
+			for _, k := range tagKeys {
+				delete(itm, k)
+			}
+		}
+		for k, v := range mp {
+			if k != "$type" && k != "$ref" {
+				itm[k] = v
+			}
+		}
+		if tagged {
+			nestTag(itm, cls)
+		}
+		s = &Sheme{obj: simplejson.Wrap(itm), name: s.name, root: owner}
+	}
+	return s
+}
+
+// tagKeys are the attributes of the tag of a sheme.
+var tagKeys = []string{"$tag", "$class", "$implicit", "$explicit", "$inner"}
+
+// copyTag returns the tag attributes of itm.
+func copyTag(itm map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(tagKeys))
+	for _, k := range tagKeys {
+		if v, f := itm[k]; f {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// nestTag merges the tag of the class cls into the tag of tag, a reference
+// to it. An explicit tag keeps the tag of the class within it under
+// '$inner'; an implicit one replaces it, and so takes its mode and the tags
+// it wraps (X.680 31.2).
+func nestTag(tag, cls map[string]interface{}) {
+	if _, f := cls["$tag"]; !f {
+		return
+	}
+	if in, ok := tag["$inner"].(map[string]interface{}); ok {
+		in = copyTag(in)
+		nestTag(in, cls)
+		tag["$inner"] = in
+		return
+	}
+	if tag["$implicit"] != true {
+		tag["$inner"] = copyTag(cls)
+		return
+	}
+	if cls["$explicit"] == true {
+		delete(tag, "$implicit")
+		tag["$explicit"] = true
+	}
+	if in, f := cls["$inner"]; f {
+		tag["$inner"] = in
+	}
+}
+
+// Inner returns the sheme within the outer tag of s when that tag wraps
+// the own tag of the type it refers to, or nil.
+func (s *Sheme) Inner() *Sheme {
+	if s.c != nil {
+		return s.c.inner
+	}
+	in, err := s.obj.Get("$inner").Map()
+	if err != nil {
+		return nil
+	}
+	mp, _ := s.obj.Map()
+	itm := make(map[string]interface{}, len(mp))
+	for k, v := range mp {
+		itm[k] = v
+	}
+	for _, k := range tagKeys {
+		delete(itm, k)
+	}
+	for k, v := range in {
+		itm[k] = v
+	}
+	return &Sheme{obj: simplejson.Wrap(itm), name: s.name, root: s.root}
+}
+
 func (s *Sheme) String() string {
 	b, _ := s.obj.MarshalJSON()
 	return string(b)
 }
 
 func (s *Sheme) Name() string {
+	if s == nil {
+		return ""
+	}
 	return s.name
 }
 
 func (s *Sheme) Class(class string) *Sheme {
-	obj := s.obj.GetPath(class)
+	if cls := s.top().classes; cls != nil {
+		return cls[class]
+	}
+	obj := s.top().obj.GetPath(class)
 	if obj.Empty() {
 		return nil
 	}
-	return &Sheme{obj: obj, name: class}
+	return (&Sheme{obj: obj, name: class, root: s.top()}).resolve()
 }
 
 func (s *Sheme) Type() string {
+	if s.c != nil {
+		return s.c.typ
+	}
 	return s.obj.Get("$type").MustString()
 }
 
 func (s *Sheme) TypeEn() int {
+	if s.c != nil {
+		return s.c.typeEn
+	}
 	return typeTag(s.Type())
 }
 
 func (s *Sheme) ID() int {
+	if s.c != nil {
+		return s.c.id
+	}
 	return s.obj.Get("$id").MustInt()
 }
 
 func (s *Sheme) Index() int {
+	if s.c != nil {
+		return s.c.index
+	}
 	if tp, err := s.obj.Get("$tag").Int(); err == nil {
 		return tp
 	}
 	return s.ID()
 }
 
+// TagClass returns the class of the tag of a tagged sheme: APPLICATION or
+// PRIVATE by '$class', context-specific otherwise.
+func (s *Sheme) TagClass() int {
+	if s.c != nil {
+		return s.c.tagClass
+	}
+	switch s.obj.Get("$class").MustString() {
+	case "APPLICATION":
+		return classApplication
+	case "PRIVATE":
+		return classPrivate
+	}
+	return classContextSpecific
+}
+
 func (s *Sheme) Optional() bool {
+	if s.c != nil {
+		return s.c.optional
+	}
 	return s.obj.Get("$optional").MustBool()
 }
 
 func (s *Sheme) Implicit() bool {
+	if s.c != nil {
+		return s.c.implicit
+	}
 	if s.obj.Get("$explicit").MustBool() {
 		return false
 	}
@@ -148,6 +672,9 @@ func (s *Sheme) Implicit() bool {
 }
 
 func (s *Sheme) Explicit() bool {
+	if s.c != nil {
+		return s.c.explicit
+	}
 	if s.obj.Get("$implicit").MustBool() {
 		return false
 	}
@@ -155,23 +682,113 @@ func (s *Sheme) Explicit() bool {
 }
 
 func (s *Sheme) Tagged() bool {
+	if s.c != nil {
+		return s.c.tagged
+	}
 	_, tp := s.obj.CheckGet("$tag")
 	return tp
 }
 
+// outerTag returns the tag an encoding of s starts with: its own tag when
+// tagged, the universal tag of its type otherwise. An untagged CHOICE or ANY
+// has none.
+func (s *Sheme) outerTag() ([2]int, bool) {
+	if s.Tagged() {
+		return [2]int{s.TagClass(), s.Index()}, true
+	}
+	if tp := s.TypeEn(); tp > tagEOC {
+		return [2]int{classUniversal, tp}, true
+	}
+	return [2]int{}, false
+}
+
 func (s *Sheme) DefAttr() interface{} {
+	if s.c != nil {
+		return s.c.def
+	}
 	return s.obj.Get("$default").Interface()
 }
 
 func (s *Sheme) MinAttr() int {
+	if s.c != nil {
+		return s.c.min
+	}
 	return s.obj.Get("$min").MustInt()
 }
 
 func (s *Sheme) MaxAttr() int {
+	if s.c != nil {
+		return s.c.max
+	}
 	return s.obj.Get("$max").MustInt()
 }
 
+// BigAttr reports whether an INTEGER is decoded as *big.Int.
+func (s *Sheme) BigAttr() bool {
+	if s.c != nil {
+		return s.c.big
+	}
+	return s.obj.Get("$big").MustBool()
+}
+
+// Extensible reports whether the type, or the constraint of its value or
+// size, has an extension marker.
+func (s *Sheme) Extensible() bool {
+	if s.c != nil {
+		return s.c.ext
+	}
+	return s.obj.Get("$extensible").MustBool()
+}
+
+// Extension reports whether a component is an extension addition.
+func (s *Sheme) Extension() bool {
+	if s.c != nil {
+		return s.c.extension
+	}
+	return s.obj.Get("$extension").MustBool()
+}
+
+// Group returns the number of the extension addition group '[[ ]]' a
+// component belongs to, 0 for none. A group is present or absent as a
+// whole.
+func (s *Sheme) Group() int {
+	if s.c != nil {
+		return s.c.group
+	}
+	return s.obj.Get("$group").MustInt()
+}
+
+// omissible reports whether the component f of s may be absent: it is
+// OPTIONAL or has a DEFAULT, or it belongs to a group of which present
+// reports no component.
+func (s *Sheme) omissible(f *Sheme, present func(*Sheme) bool) bool {
+	if f.Optional() || f.DefAttr() != nil {
+		return true
+	}
+	g := f.Group()
+	if g == 0 {
+		return false
+	}
+	fld := s.FieldList()
+	for m := fld.Begin(); m != nil; m = fld.Next() {
+		if m.Group() == g && present(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExtensionItems returns the names of the ENUMERATED items that are
+// extension additions, listed in '$extensionItems'.
+func (s *Sheme) ExtensionItems() []string {
+	out, _ := s.obj.Get("$extensionItems").StringArray()
+	return out
+}
+
 func (s *Sheme) FormatAttr() string {
+	if s.c != nil {
+		return s.c.format
+	}
 	return s.obj.Get("$format").MustString()
 }
 
@@ -183,10 +800,39 @@ func (s *Sheme) OfAttr() map[string]interface{} {
 	return s.obj.Get("$of").MustMap()
 }
 
+// isOf reports whether s is a SEQUENCE OF or a SET OF.
+func (s *Sheme) isOf() bool {
+	if s.c != nil {
+		return s.c.of != nil
+	}
+	return s.OfAttr() != nil
+}
+
+// hasFields reports whether s has '$field'.
+func (s *Sheme) hasFields() bool {
+	if s.c != nil && (s.c.fields != nil || s.c.enum != nil) {
+		return true
+	}
+	return s.FieldAttr() != nil
+}
+
+// bounds reports whether s sets '$min' and '$max'.
+func (s *Sheme) bounds() (hasMin, hasMax bool) {
+	if s.c != nil {
+		return s.c.hasMin, s.c.hasMax
+	}
+	_, hasMin = s.obj.CheckGet("$min")
+	_, hasMax = s.obj.CheckGet("$max")
+	return
+}
+
 func (s *Sheme) Field(name string) *Sheme {
+	if s.c != nil && s.c.fields != nil {
+		return s.c.fields.names[name]
+	}
 	if fld := s.FieldAttr(); fld != nil {
 		if itm, ok := fld[name].(map[string]interface{}); ok {
-			return Wrap(itm, name)
+			return s.component(itm, name)
 		}
 	}
 	return nil
@@ -204,38 +850,57 @@ func (s *Sheme) FieldKeys() []string {
 }
 
 func (s *Sheme) Of() *Sheme {
+	if s.c != nil && s.c.of != nil {
+		return s.c.of
+	}
 	if fld := s.OfAttr(); fld != nil {
-		return Wrap(fld, s.name)
+		return s.wrap(fld, s.name)
 	}
 	return nil
 }
 
 type fieldList struct {
-	cur *list.Element
-	lst *list.List
+	cur   int
+	items []*Sheme
+	index map[int]*Sheme
+	tags  map[[2]int]*Sheme
+	ids   map[int]*Sheme
 }
 
 func (fl *fieldList) Len() int {
-	return fl.lst.Len()
+	return len(fl.items)
 }
 
-func (fl *fieldList) Begin() *Sheme {
-	if fl.cur = fl.lst.Front(); fl.cur != nil {
-		return fl.cur.Value.(*Sheme)
+func (fl *fieldList) at() *Sheme {
+	if fl.cur < len(fl.items) {
+		return fl.items[fl.cur]
 	}
 	return nil
 }
 
-func (fl *fieldList) Next() *Sheme {
-	if fl.cur = fl.cur.Next(); fl.cur != nil {
-		return fl.cur.Value.(*Sheme)
+// rest returns the fields from the current one on.
+func (fl *fieldList) rest() []*Sheme {
+	if fl.cur < len(fl.items) {
+		return fl.items[fl.cur:]
 	}
 	return nil
 }
 
+func (fl *fieldList) Begin() *Sheme {
+	fl.cur = 0
+	return fl.at()
+}
+
+func (fl *fieldList) Next() *Sheme {
+	fl.cur++
+	return fl.at()
+}
+
 func (fl *fieldList) FindIndex(idx int) *Sheme {
+	if fl.index != nil {
+		return fl.index[idx]
+	}
 	for el := fl.Begin(); el != nil; el = fl.Next() {
-		debugPrint("FindIndex %d == %d", idx, el.Index())
 		if idx == el.Index() {
 			return el
 		}
@@ -243,7 +908,25 @@ func (fl *fieldList) FindIndex(idx int) *Sheme {
 	return nil
 }
 
+// FindTag returns the field whose encoding starts with the tag of class
+// and number; an untagged field is found by the universal tag of its type.
+func (fl *fieldList) FindTag(class, number int) *Sheme {
+	key := [2]int{class, number}
+	if fl.tags != nil {
+		return fl.tags[key]
+	}
+	for el := fl.Begin(); el != nil; el = fl.Next() {
+		if tg, ok := el.outerTag(); ok && tg == key {
+			return el
+		}
+	}
+	return nil
+}
+
 func (fl *fieldList) FindID(idx int) *Sheme {
+	if fl.ids != nil {
+		return fl.ids[idx]
+	}
 	for el := fl.Begin(); el != nil; el = fl.Next() {
 		if idx == el.ID() {
 			return el
@@ -252,28 +935,30 @@ func (fl *fieldList) FindID(idx int) *Sheme {
 	return nil
 }
 
+// Add inserts sh in the order of '$id', ahead of the fields of the same id.
 func (fl *fieldList) Add(sh *Sheme) {
-	var fnd *list.Element
 	id := sh.ID()
-	for el := fl.lst.Front(); el != nil; el = el.Next() {
-		elsh := el.Value.(*Sheme)
-		if id > elsh.ID() {
-			fnd = el
-		}
-	}
-	if fnd != nil {
-		fl.lst.InsertAfter(sh, fnd)
-	} else {
-		fl.lst.PushFront(sh)
-	}
+	i := sort.Search(len(fl.items), func(i int) bool {
+		return fl.items[i].ID() >= id
+	})
+	fl.items = append(fl.items, nil)
+	copy(fl.items[i+1:], fl.items[i:])
+	fl.items[i] = sh
+	fl.index, fl.tags, fl.ids = nil, nil, nil
 }
 
 func NewFieldList(fld map[string]interface{}) (*fieldList, error) {
-	ret := &fieldList{lst: list.New()}
+	return newFieldList(fld, func(itm map[string]interface{}, name string) *Sheme {
+		return Wrap(itm, name)
+	})
+}
+
+func newFieldList(fld map[string]interface{}, wrap func(map[string]interface{}, string) *Sheme) (*fieldList, error) {
+	ret := &fieldList{items: make([]*Sheme, 0, len(fld))}
 
 	for k, v := range fld {
 		if obj, ok := v.(map[string]interface{}); ok {
-			ret.Add(Wrap(obj, k))
+			ret.Add(wrap(obj, k))
 		} else {
 			return nil, fmt.Errorf("no sheme object: '%s'", k)
 		}
@@ -283,11 +968,19 @@ func NewFieldList(fld map[string]interface{}) (*fieldList, error) {
 }
 
 func (s *Sheme) FieldList() *fieldList {
-	res, _ := NewFieldList(s.FieldAttr())
+	if s.c != nil && s.c.fields != nil {
+		return s.c.fields.fieldList()
+	}
+	res, _ := newFieldList(s.FieldAttr(), s.component)
 	return res
 }
 
+// EnumItems returns the names of the ENUMERATED items by their numbers. The
+// map of a compiled sheme is shared and must not be modified.
 func (s *Sheme) EnumItems() map[int]string {
+	if s.c != nil && s.c.enum != nil {
+		return s.c.enum
+	}
 	fld := s.FieldAttr()
 	ret := make(map[int]string)
 	for k, v := range fld {
@@ -298,6 +991,31 @@ func (s *Sheme) EnumItems() map[int]string {
 	return ret
 }
 
+// enumItem returns the name and the number of val, an ENUMERATED value of
+// s given by either. A number an extensible s does not know has no name.
+func (s *Sheme) enumItem(val interface{}) (string, int, bool) {
+	if v, ok := val.(string); ok {
+		if s.c != nil && s.c.enumIDs != nil {
+			id, ok := s.c.enumIDs[v]
+			return v, id, ok
+		}
+		itm, ok := s.FieldAttr()[v]
+		if !ok {
+			return "", 0, false
+		}
+		id, err := simplejson.Wrap(itm).Int()
+		return v, id, err == nil
+	}
+	id, ok := valueInt(val)
+	if !ok || !id.IsInt64() {
+		return "", 0, false
+	}
+	if name, ok := s.EnumItems()[int(id.Int64())]; ok {
+		return name, int(id.Int64()), true
+	}
+	return "", int(id.Int64()), s.Extensible()
+}
+
 func Wrap(itm map[string]interface{}, name ...string) *Sheme {
 	out := &Sheme{obj: simplejson.Wrap(itm)}
 	if len(name) > 0 {
@@ -0,0 +1,159 @@
+package asn1dynamic
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestResolveNestedTag(t *testing.T) {
+	const classes = `
+		"Ticket":{"$type":"SEQUENCE","$tag":1,"$class":"APPLICATION","$explicit":true,"$field":{"a":{"$id":0,"$type":"INTEGER"}}},
+		"Plain":{"$type":"SEQUENCE","$tag":2,"$class":"APPLICATION","$implicit":true,"$field":{"a":{"$id":0,"$type":"INTEGER"}}},
+		"Pick":{"$type":"CHOICE","$tag":3,"$class":"APPLICATION","$field":{"a":{"$id":0,"$type":"INTEGER","$tag":0,"$implicit":true}}}`
+	for _, tc := range []struct {
+		name string
+		ref  string
+		want string
+	}{
+		// an explicit tag wraps the own tag of the class
+		{"explicit", `{"$type":"Ticket","$tag":5,"$explicit":true}`, "a507" + "6105" + "3003020101"},
+		{"explicit implicit", `{"$type":"Plain","$tag":5,"$explicit":true}`, "a505" + "6203020101"},
+		{"explicit choice", `{"$type":"Pick","$tag":5,"$explicit":true}`, "a505" + "6303800101"},
+		// an implicit tag replaces it and so is explicit in place of an
+		// explicit one
+		{"implicit", `{"$type":"Ticket","$tag":5,"$implicit":true}`, "a505" + "3003020101"},
+		{"implicit implicit", `{"$type":"Plain","$tag":5,"$implicit":true}`, "a503020101"},
+	} {
+		sh, err := NewSheme([]byte(`{` + classes + `,
+			"T":{"$type":"SEQUENCE","$field":{"t":` + tc.ref[:len(tc.ref)-1] + `,"$id":0}}}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cls := sh.Class("T")
+		val := map[string]interface{}{"t": map[string]interface{}{"a": 1}}
+		want := hex.EncodeToString([]byte{0x30, byte(len(tc.want) / 2)}) + tc.want
+		for _, c := range []*Codec{NewCodec(Options{}), NewCodec(Options{Strict: true})} {
+			out, err := c.Encode(cls, val)
+			if err != nil || hex.EncodeToString(out) != want {
+				t.Errorf("%s: encoded %x %v, want %s", tc.name, out, err, want)
+				continue
+			}
+			js, err := c.Decode(cls, out)
+			if err != nil || js.Get("t").Get("a").MustInt() != 1 {
+				t.Errorf("%s: decoded %v %v", tc.name, js, err)
+			}
+		}
+	}
+}
+
+func TestModuleTagging(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		js   string
+		asn  string
+		val  map[string]interface{}
+		want string
+	}{
+		{"explicit",
+			`{"$tagging":"EXPLICIT","T":{"$type":"SEQUENCE","$field":{
+				"a":{"$id":0,"$type":"INTEGER","$tag":0},
+				"b":{"$id":1,"$type":"BOOLEAN","$tag":1,"$implicit":true}}}}`,
+			`M DEFINITIONS EXPLICIT TAGS ::= BEGIN T ::= SEQUENCE { a [0] INTEGER, b [1] IMPLICIT BOOLEAN } END`,
+			map[string]interface{}{"a": 5, "b": true}, "3008" + "a003020105" + "8101ff"},
+		{"implicit",
+			`{"$tagging":"IMPLICIT","T":{"$type":"SEQUENCE","$field":{
+				"a":{"$id":0,"$type":"INTEGER","$tag":0},
+				"b":{"$id":1,"$type":"BOOLEAN","$tag":1,"$explicit":true}}}}`,
+			`M DEFINITIONS IMPLICIT TAGS ::= BEGIN T ::= SEQUENCE { a [0] INTEGER, b [1] EXPLICIT BOOLEAN } END`,
+			map[string]interface{}{"a": 5, "b": true}, "3008" + "800105" + "a1030101ff"},
+		// the components are tagged by their position, a CHOICE explicitly
+		{"automatic",
+			`{"$tagging":"AUTOMATIC","T":{"$type":"SEQUENCE","$field":{
+				"a":{"$id":0,"$type":"INTEGER"},
+				"b":{"$id":1,"$type":"BOOLEAN"},
+				"c":{"$id":2,"$type":"CHOICE","$field":{"x":{"$id":0,"$type":"INTEGER"},"y":{"$id":1,"$type":"BOOLEAN"}}}}}}`,
+			`M DEFINITIONS AUTOMATIC TAGS ::= BEGIN T ::= SEQUENCE { a INTEGER, b BOOLEAN, c CHOICE { x INTEGER, y BOOLEAN } } END`,
+			map[string]interface{}{"a": 5, "b": true, "c": map[string]interface{}{"y": true}}, "300b" + "800105" + "8101ff" + "a2038101ff"},
+		// unless one of them is tagged already
+		{"automatic tagged",
+			`{"$tagging":"AUTOMATIC","T":{"$type":"SEQUENCE","$field":{
+				"a":{"$id":0,"$type":"INTEGER"},
+				"b":{"$id":1,"$type":"BOOLEAN","$tag":5}}}}`,
+			`M DEFINITIONS AUTOMATIC TAGS ::= BEGIN T ::= SEQUENCE { a INTEGER, b [5] BOOLEAN } END`,
+			map[string]interface{}{"a": 5, "b": true}, "3006" + "020105" + "8501ff"},
+	} {
+		fromJSON, err := NewSheme([]byte(tc.js))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		fromASN, err := NewShemeASN1([]byte(tc.asn))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		for _, sh := range []*Sheme{fromJSON, fromASN} {
+			cls := sh.Class("T")
+			// the environment of the module wins over the Tagging of a Codec
+			for _, c := range []*Codec{NewCodec(Options{}), NewCodec(Options{Tagging: ImplicitTags})} {
+				out, err := c.Encode(cls, tc.val)
+				if err != nil || hex.EncodeToString(out) != tc.want {
+					t.Errorf("%s: encoded %x %v, want %s", tc.name, out, err, tc.want)
+					continue
+				}
+				js, err := c.Decode(cls, out)
+				if err != nil || js.Get("b").MustBool() != true {
+					t.Errorf("%s: decoded %v %v", tc.name, js, err)
+				}
+			}
+		}
+	}
+	if _, err := NewSheme([]byte(`{"$tagging":"AUTO","T":{"$type":"INTEGER"}}`)); err == nil {
+		t.Error("took an unknown '$tagging'")
+	}
+}
+
+func TestTagClass(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		fld   string
+		want  string // the encoding of the field of value 5
+		other string // the same with a tag of another class
+	}{
+		{"application implicit", `{"$type":"INTEGER","$tag":2,"$class":"APPLICATION","$implicit":true}`, "420105", "820105"},
+		{"application explicit", `{"$type":"INTEGER","$tag":2,"$class":"APPLICATION","$explicit":true}`, "6203020105", "e203020105"},
+		{"private implicit", `{"$type":"INTEGER","$tag":2,"$class":"PRIVATE","$implicit":true}`, "c20105", "420105"},
+		{"private explicit", `{"$type":"INTEGER","$tag":2,"$class":"PRIVATE","$explicit":true}`, "e203020105", "a203020105"},
+		{"context", `{"$type":"INTEGER","$tag":2,"$class":"CONTEXT","$implicit":true}`, "820105", "c20105"},
+		// a number past 30 takes more octets
+		{"application high", `{"$type":"INTEGER","$tag":200,"$class":"APPLICATION","$implicit":true}`, "5f81480105", "9f81480105"},
+		{"application sequence", `{"$type":"SEQUENCE","$tag":3,"$class":"APPLICATION","$implicit":true,"$field":{"x":{"$id":0,"$type":"INTEGER"}}}`,
+			"6303020105", "a303020105"},
+	} {
+		sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{"f":` + tc.fld[:len(tc.fld)-1] + `,"$id":0}}}}`))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		cls := sh.Class("T")
+		var val interface{} = 5
+		if cls.Field("f").Type() == "SEQUENCE" {
+			val = map[string]interface{}{"x": 5}
+		}
+		want := hex.EncodeToString([]byte{0x30, byte(len(tc.want) / 2)}) + tc.want
+		for _, c := range []*Codec{NewCodec(Options{}), NewCodec(Options{Strict: true})} {
+			out, err := c.Encode(cls, map[string]interface{}{"f": val})
+			if err != nil || hex.EncodeToString(out) != want {
+				t.Errorf("%s: encoded %x %v, want %s", tc.name, out, err, want)
+				continue
+			}
+			if _, err := c.Decode(cls, out); err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+			other, _ := hex.DecodeString(want[:4] + tc.other)
+			if js, err := c.Decode(cls, other); err == nil {
+				t.Errorf("%s: %s decoded to %v", tc.name, tc.other, js.Interface())
+			}
+		}
+	}
+	if _, err := NewSheme([]byte(`{"T":{"$type":"INTEGER","$tag":1,"$class":"GLOBAL"}}`)); err == nil {
+		t.Error("took an unknown '$class'")
+	}
+}
@@ -0,0 +1,177 @@
+package asn1dynamic
+
+import (
+	"reflect"
+)
+
+// compiled holds the attributes of a resolved sheme, read once, and its
+// resolved components, so that walking a compiled sheme does no lookups in
+// the JSON object. A compiled sheme is never modified.
+type compiled struct {
+	typ       string
+	typeEn    int
+	id        int
+	index     int
+	tagClass  int
+	tagged    bool
+	optional  bool
+	implicit  bool
+	explicit  bool
+	def       interface{}
+	min       int
+	max       int
+	hasMin    bool
+	hasMax    bool
+	big       bool
+	ext       bool
+	extension bool
+	group     int
+	format    string
+
+	fields  *components
+	of      *Sheme
+	inner   *Sheme
+	enum    map[int]string
+	enumIDs map[string]int
+}
+
+// components are the fields of a SEQUENCE or a SET or the alternatives of a
+// CHOICE or an ANY, ordered by '$id'.
+type components struct {
+	list  []*Sheme
+	names map[string]*Sheme
+	index map[int]*Sheme
+	tags  map[[2]int]*Sheme
+	ids   map[int]*Sheme
+}
+
+func (cs *components) fieldList() *fieldList {
+	n := len(cs.list)
+	return &fieldList{items: cs.list[:n:n], index: cs.index, tags: cs.tags, ids: cs.ids}
+}
+
+type ofKey struct {
+	obj  uintptr
+	name string
+}
+
+// compiler compiles the classes of one or more modules. Components are
+// shared by the objects they come from, which ends the recursion of
+// recursive classes.
+type compiler struct {
+	fields map[uintptr]*components
+	of     map[ofKey]*Sheme
+}
+
+func newCompiler() *compiler {
+	return &compiler{fields: make(map[uintptr]*components), of: make(map[ofKey]*Sheme)}
+}
+
+func objID(obj map[string]interface{}) uintptr {
+	return reflect.ValueOf(obj).Pointer()
+}
+
+// compile compiles every class of the module s. Class then returns the
+// compiled classes.
+func (s *Sheme) compile(cp *compiler) {
+	classes := make(map[string]*Sheme)
+	for _, k := range s.Classes() {
+		cls := (&Sheme{obj: s.obj.Get(k), name: k, root: s}).resolve()
+		classes[k] = cp.compile(cls)
+	}
+	s.classes = classes
+}
+
+// compile sets the compiled form of sh, a resolved sheme. A reference that
+// does not resolve is left as it is.
+func (cp *compiler) compile(sh *Sheme) *Sheme {
+	if sh.c != nil || sh.Ref() != "" {
+		return sh
+	}
+	c := &compiled{
+		typ:       sh.Type(),
+		typeEn:    sh.TypeEn(),
+		id:        sh.ID(),
+		index:     sh.Index(),
+		tagClass:  sh.TagClass(),
+		tagged:    sh.Tagged(),
+		optional:  sh.Optional(),
+		implicit:  sh.Implicit(),
+		explicit:  sh.Explicit(),
+		def:       sh.DefAttr(),
+		min:       sh.MinAttr(),
+		max:       sh.MaxAttr(),
+		big:       sh.BigAttr(),
+		ext:       sh.Extensible(),
+		extension: sh.Extension(),
+		group:     sh.Group(),
+		format:    sh.FormatAttr(),
+	}
+	c.hasMin, c.hasMax = sh.bounds()
+	switch c.typeEn {
+	case tagENUMERATED:
+		c.enum = sh.EnumItems()
+		c.enumIDs = make(map[string]int, len(c.enum))
+		for id, name := range c.enum {
+			c.enumIDs[name] = id
+		}
+	case tagSEQUENCE, tagSET, tagCHOICE, tagANY:
+		if of := sh.OfAttr(); of != nil {
+			c.of = cp.compileOf(sh, of)
+		} else if fl := sh.FieldAttr(); fl != nil {
+			c.fields = cp.compileFields(sh, fl)
+		}
+	}
+	if in := sh.Inner(); in != nil {
+		c.inner = cp.compile(in)
+	}
+	sh.c = c
+	return sh
+}
+
+func (cp *compiler) compileOf(sh *Sheme, of map[string]interface{}) *Sheme {
+	key := ofKey{objID(of), sh.name}
+	if out, f := cp.of[key]; f {
+		return out
+	}
+	out := sh.wrap(of, sh.name)
+	cp.of[key] = out
+	return cp.compile(out)
+}
+
+func (cp *compiler) compileFields(sh *Sheme, fl map[string]interface{}) *components {
+	key := objID(fl)
+	if cs, f := cp.fields[key]; f {
+		return cs
+	}
+	lst, err := newFieldList(fl, sh.component)
+	if err != nil {
+		return nil
+	}
+	cs := &components{
+		list:  make([]*Sheme, 0, lst.Len()),
+		names: make(map[string]*Sheme, lst.Len()),
+		index: make(map[int]*Sheme, lst.Len()),
+		tags:  make(map[[2]int]*Sheme, lst.Len()),
+		ids:   make(map[int]*Sheme, lst.Len()),
+	}
+	cp.fields[key] = cs
+	for f := lst.Begin(); f != nil; f = lst.Next() {
+		f = cp.compile(f)
+		cs.list = append(cs.list, f)
+		cs.names[f.name] = f
+		// the first one in order wins, as with a search of the list
+		if _, dup := cs.index[f.Index()]; !dup {
+			cs.index[f.Index()] = f
+		}
+		if key, ok := f.outerTag(); ok {
+			if _, dup := cs.tags[key]; !dup {
+				cs.tags[key] = f
+			}
+		}
+		if _, dup := cs.ids[f.ID()]; !dup {
+			cs.ids[f.ID()] = f
+		}
+	}
+	return cs
+}
@@ -0,0 +1,140 @@
+package asn1dynamic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+const benchModule = `
+Bench DEFINITIONS AUTOMATIC TAGS ::= BEGIN
+Rec ::= SEQUENCE {
+  id    INTEGER (0..1000),
+  flag  BOOLEAN,
+  color ENUMERATED { red, green, blue },
+  name  IA5String OPTIONAL,
+  data  OCTET STRING,
+  oid   OBJECT IDENTIFIER,
+  when  UTCTime,
+  list  SEQUENCE OF Item,
+  ch    CHOICE { a INTEGER, b UTF8String }
+}
+Item ::= SEQUENCE { x INTEGER, y VisibleString OPTIONAL }
+END
+`
+
+// benchSheme returns the class Rec, compiled or read from the JSON objects
+// on every walk, and a value of it.
+func benchSheme(b *testing.B, compiled bool) (*Sheme, interface{}) {
+	sh, err := NewShemeASN1([]byte(benchModule))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if !compiled {
+		sh.classes = nil
+	}
+	list := make([]interface{}, 16)
+	for i := range list {
+		list[i] = map[string]interface{}{"x": i, "y": "item"}
+	}
+	return sh.Class("Rec"), map[string]interface{}{
+		"id":    300,
+		"flag":  true,
+		"color": "green",
+		"name":  "bench",
+		"data":  []byte{0xde, 0xad, 0xbe, 0xef},
+		"oid":   "1.2.840.113549",
+		"when":  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"list":  list,
+		"ch":    map[string]interface{}{"b": "choice"},
+	}
+}
+
+func benchmarkEncode(b *testing.B, compiled bool) {
+	cls, val := benchSheme(b, compiled)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Encode(cls, val); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, compiled bool) {
+	cls, val := benchSheme(b, compiled)
+	data, err := Encode(cls, val)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoder()
+		if _, _, err := dec.Parse(data); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := dec.Decode(cls); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeCompiled(b *testing.B)   { benchmarkEncode(b, true) }
+func BenchmarkEncodeUncompiled(b *testing.B) { benchmarkEncode(b, false) }
+func BenchmarkDecodeCompiled(b *testing.B)   { benchmarkDecode(b, true) }
+func BenchmarkDecodeUncompiled(b *testing.B) { benchmarkDecode(b, false) }
+
+// TestCompiledAttributes decodes by a compiled sheme whose JSON objects are
+// emptied: what decoding needs is read once by compile.
+func TestCompiledAttributes(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(benchModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("Rec")
+	val := map[string]interface{}{
+		"id": 300, "flag": true, "color": "green", "data": []byte{1}, "oid": "1.2.3",
+		"when": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"list": []interface{}{map[string]interface{}{"x": 1}}, "ch": map[string]interface{}{"a": 2},
+	}
+	ber, err := Encode(cls, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	el, err := build(cls, val, &AsnContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	per, err := el.EncodeUPER()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[*Sheme]bool)
+	var empty func(sh *Sheme)
+	empty = func(sh *Sheme) {
+		if sh == nil || seen[sh] {
+			return
+		}
+		seen[sh] = true
+		sh.obj = simplejson.New()
+		empty(sh.c.of)
+		if sh.c.fields != nil {
+			for _, f := range sh.c.fields.list {
+				empty(f)
+			}
+		}
+	}
+	empty(cls)
+
+	js, err := NewCodec(Options{}).Decode(cls, ber)
+	if err != nil || js.Get("color").MustString() != "green" || js.Get("id").MustInt() != 300 {
+		t.Errorf("BER: %v %v", js, err)
+	}
+	js, err = cls.DecodeUPER(per)
+	if err != nil || js.Get("color").MustString() != "green" || js.Get("id").MustInt() != 300 {
+		t.Errorf("PER: %v %v", js, err)
+	}
+}
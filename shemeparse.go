@@ -0,0 +1,1450 @@
+package asn1dynamic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	tokEOF = iota
+	tokWord
+	tokNumber
+	tokCString
+	tokBString
+	tokHString
+	tokSymbol
+)
+
+type asnToken struct {
+	kind int
+	text string
+	line int
+}
+
+type asnRange struct {
+	lo, hi       int
+	hasLo, hasHi bool
+	loRef, hiRef string
+}
+
+// asnLinker gives a module access to the types and values it imports.
+type asnLinker interface {
+	target(mod, name string, stack []string) (map[string]interface{}, error)
+	resolve(mod, name string, stack []string) (map[string]interface{}, error)
+	value(mod, name string) (int, error)
+}
+
+// asnModules links the modules of one ASN.1 text to each other.
+type asnModules map[string]*asnModule
+
+func (ms asnModules) target(mod, name string, stack []string) (map[string]interface{}, error) {
+	if m, f := ms[mod]; f {
+		return m.target(name, stack)
+	}
+	return nil, Errorf("asn1 parse: type '%s' imported from unknown module '%s'", name, mod)
+}
+
+func (ms asnModules) resolve(mod, name string, stack []string) (map[string]interface{}, error) {
+	if m, f := ms[mod]; f {
+		return m.resolve(name, stack)
+	}
+	return nil, Errorf("asn1 parse: type '%s' imported from unknown module '%s'", name, mod)
+}
+
+func (ms asnModules) value(mod, name string) (int, error) {
+	if m, f := ms[mod]; f {
+		return m.value(name)
+	}
+	return 0, Errorf("asn1 parse: value '%s' imported from unknown module '%s'", name, mod)
+}
+
+// unqualify drops the module from the references of tp to the types of
+// ms, which are merged into one sheme.
+func (ms asnModules) unqualify(tp map[string]interface{}) {
+	tn, _ := tp["$type"].(string)
+	if i := strings.LastIndex(tn, "."); i > 0 && ms[tn[:i]] != nil {
+		tp["$type"] = tn[i+1:]
+	}
+	if of, ok := tp["$of"].(map[string]interface{}); ok {
+		ms.unqualify(of)
+	}
+	fld, _ := tp["$field"].(map[string]interface{})
+	for _, v := range fld {
+		if f, ok := v.(map[string]interface{}); ok {
+			ms.unqualify(f)
+		}
+	}
+}
+
+type asnModule struct {
+	name     string
+	tagging  string
+	imports  map[string]string
+	types    map[string]map[string]interface{}
+	order    []string
+	values   map[string]int
+	expanded map[string]bool
+	link     asnLinker
+}
+
+type asnParser struct {
+	tok []asnToken
+	pos int
+	mod *asnModule
+	// ext is set when a constraint has an extension marker
+	ext bool
+}
+
+func parseErr(tk asnToken, frm string, arg ...interface{}) error {
+	return Errorf("asn1 parse: line %d: %s", tk.line, fmt.Sprintf(frm, arg...))
+}
+
+func isWordChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isUpper(s string) bool {
+	return len(s) > 0 && 'A' <= s[0] && s[0] <= 'Z'
+}
+
+func lexASN1(src []byte) ([]asnToken, error) {
+	var out []asnToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case c == '-' && i+1 < len(src) && src[i+1] == '-':
+			// comment runs to the end of line or to the next "--"
+			for i += 2; i < len(src) && src[i] != '\n'; i++ {
+				if src[i] == '-' && i+1 < len(src) && src[i+1] == '-' {
+					i += 2
+					break
+				}
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			depth := 0
+			for i < len(src) {
+				if src[i] == '/' && i+1 < len(src) && src[i+1] == '*' {
+					depth++
+					i += 2
+				} else if src[i] == '*' && i+1 < len(src) && src[i+1] == '/' {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					if src[i] == '\n' {
+						line++
+					}
+					i++
+				}
+			}
+		case isDigit(c):
+			j := i
+			for j < len(src) && isDigit(src[j]) {
+				j++
+			}
+			out = append(out, asnToken{kind: tokNumber, text: string(src[i:j]), line: line})
+			i = j
+		case isWordChar(c) || c == '&':
+			j := i + 1
+			for j < len(src) {
+				if isWordChar(src[j]) {
+					j++
+				} else if src[j] == '-' && j+1 < len(src) && isWordChar(src[j+1]) {
+					j += 2
+				} else {
+					break
+				}
+			}
+			out = append(out, asnToken{kind: tokWord, text: string(src[i:j]), line: line})
+			i = j
+		case c == '"':
+			var buf strings.Builder
+			j := i + 1
+			for ; j < len(src); j++ {
+				if src[j] == '"' {
+					if j+1 < len(src) && src[j+1] == '"' {
+						buf.WriteByte('"')
+						j++
+						continue
+					}
+					break
+				}
+				if src[j] == '\n' {
+					line++
+				}
+				buf.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, Errorf("asn1 parse: line %d: unterminated string", line)
+			}
+			out = append(out, asnToken{kind: tokCString, text: buf.String(), line: line})
+			i = j + 1
+		case c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != '\'' {
+				j++
+			}
+			if j+1 >= len(src) || (src[j+1] != 'B' && src[j+1] != 'H') {
+				return nil, Errorf("asn1 parse: line %d: invalid bstring or hstring", line)
+			}
+			kind := tokBString
+			if src[j+1] == 'H' {
+				kind = tokHString
+			}
+			txt := strings.Map(func(r rune) rune {
+				if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+					return -1
+				}
+				return r
+			}, string(src[i+1:j]))
+			out = append(out, asnToken{kind: kind, text: txt, line: line})
+			i = j + 2
+		default:
+			sym := string(c)
+			for _, s := range []string{"::=", "...", "..", "[[", "]]"} {
+				if strings.HasPrefix(string(src[i:]), s) {
+					sym = s
+					break
+				}
+			}
+			if !strings.Contains("{}()[],;.|^<>@!:-=*", string(c)) {
+				return nil, Errorf("asn1 parse: line %d: unexpected character %q", line, c)
+			}
+			out = append(out, asnToken{kind: tokSymbol, text: sym, line: line})
+			i += len(sym)
+		}
+	}
+	out = append(out, asnToken{kind: tokEOF, line: line})
+	return out, nil
+}
+
+func (p *asnParser) peek() asnToken {
+	return p.tok[p.pos]
+}
+
+func (p *asnParser) peekAt(n int) asnToken {
+	if p.pos+n < len(p.tok) {
+		return p.tok[p.pos+n]
+	}
+	return p.tok[len(p.tok)-1]
+}
+
+func (p *asnParser) next() asnToken {
+	tk := p.tok[p.pos]
+	if tk.kind != tokEOF {
+		p.pos++
+	}
+	return tk
+}
+
+func (p *asnParser) is(text ...string) bool {
+	tk := p.peek()
+	if tk.kind != tokWord && tk.kind != tokSymbol {
+		return false
+	}
+	for i, t := range text {
+		tk = p.peekAt(i)
+		if (tk.kind != tokWord && tk.kind != tokSymbol) || tk.text != t {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *asnParser) accept(text ...string) bool {
+	if p.is(text...) {
+		p.pos += len(text)
+		return true
+	}
+	return false
+}
+
+func (p *asnParser) expect(text string) error {
+	if !p.accept(text) {
+		return parseErr(p.peek(), "expected '%s' but got '%s'", text, p.peek().text)
+	}
+	return nil
+}
+
+func (p *asnParser) word() (string, error) {
+	tk := p.next()
+	if tk.kind != tokWord {
+		return "", parseErr(tk, "expected identifier but got '%s'", tk.text)
+	}
+	return tk.text, nil
+}
+
+// skipBlock skips a balanced block starting at the current opening bracket.
+func (p *asnParser) skipBlock() error {
+	open := p.next()
+	var close string
+	switch open.text {
+	case "{":
+		close = "}"
+	case "(":
+		close = ")"
+	case "[":
+		close = "]"
+	default:
+		return parseErr(open, "expected block but got '%s'", open.text)
+	}
+	for depth := 1; depth > 0; {
+		tk := p.next()
+		switch {
+		case tk.kind == tokEOF:
+			return parseErr(open, "unterminated '%s'", open.text)
+		case tk.kind != tokSymbol:
+		case tk.text == open.text:
+			depth++
+		case tk.text == close:
+			depth--
+		}
+	}
+	return nil
+}
+
+func parseASN1(src []byte) ([]*asnModule, error) {
+	tok, err := lexASN1(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &asnParser{tok: tok}
+	var out []*asnModule
+	for p.peek().kind != tokEOF {
+		if err := p.parseModule(); err != nil {
+			return nil, err
+		}
+		out = append(out, p.mod)
+	}
+	if len(out) == 0 {
+		return nil, Errorf("asn1 parse: no module definition found")
+	}
+	return out, nil
+}
+
+func (p *asnParser) parseModule() error {
+	name, err := p.word()
+	if err != nil {
+		return err
+	}
+	p.mod = &asnModule{
+		name:     name,
+		tagging:  "EXPLICIT",
+		imports:  make(map[string]string),
+		types:    make(map[string]map[string]interface{}),
+		values:   make(map[string]int),
+		expanded: make(map[string]bool),
+	}
+	if p.is("{") {
+		if err := p.skipBlock(); err != nil {
+			return err
+		}
+	}
+	if err := p.expect("DEFINITIONS"); err != nil {
+		return err
+	}
+	for _, tg := range []string{"EXPLICIT", "IMPLICIT", "AUTOMATIC"} {
+		if p.accept(tg, "TAGS") {
+			p.mod.tagging = tg
+		}
+	}
+	p.accept("EXTENSIBILITY", "IMPLIED")
+	if err := p.expect("::="); err != nil {
+		return err
+	}
+	if err := p.expect("BEGIN"); err != nil {
+		return err
+	}
+	p.scanValues()
+
+	if p.accept("EXPORTS") {
+		for !p.accept(";") {
+			if p.peek().kind == tokEOF {
+				return parseErr(p.peek(), "unterminated EXPORTS")
+			}
+			p.next()
+		}
+	}
+	if p.accept("IMPORTS") {
+		if err := p.parseImports(); err != nil {
+			return err
+		}
+	}
+
+	for !p.accept("END") {
+		if err := p.parseAssignment(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanValues collects integer value assignments ahead of time, because
+// constraints may refer to values that are defined further down the module.
+func (p *asnParser) scanValues() {
+	for i := p.pos; i+4 < len(p.tok); i++ {
+		t := p.tok[i:]
+		if t[0].kind == tokWord && t[0].text == "END" {
+			return
+		}
+		if t[0].kind != tokWord || isUpper(t[0].text) || t[1].text != "INTEGER" || t[2].text != "::=" {
+			continue
+		}
+		neg := 1
+		v := t[3]
+		if v.text == "-" {
+			neg = -1
+			v = t[4]
+		}
+		if v.kind == tokNumber {
+			if n, err := strconv.Atoi(v.text); err == nil {
+				p.mod.values[t[0].text] = n * neg
+			}
+		}
+	}
+}
+
+func (p *asnParser) parseImports() error {
+	var syms []string
+	for !p.accept(";") {
+		tk := p.next()
+		switch {
+		case tk.kind == tokEOF:
+			return parseErr(tk, "unterminated IMPORTS")
+		case tk.kind == tokWord && tk.text == "FROM":
+			mod, err := p.word()
+			if err != nil {
+				return err
+			}
+			for _, s := range syms {
+				p.mod.imports[s] = mod
+			}
+			syms = syms[:0]
+			if p.is("{") {
+				if err := p.skipBlock(); err != nil {
+					return err
+				}
+			} else if nx := p.peek(); nx.kind == tokWord && !isUpper(nx.text) &&
+				p.peekAt(1).text != "," && p.peekAt(1).text != "FROM" {
+				p.next()
+			}
+		case tk.kind == tokWord:
+			syms = append(syms, tk.text)
+			if p.is("{", "}") {
+				p.pos += 2
+			}
+		case tk.text == ",":
+		default:
+			return parseErr(tk, "unexpected '%s' in IMPORTS", tk.text)
+		}
+	}
+	return nil
+}
+
+func (p *asnParser) parseAssignment() error {
+	tk := p.peek()
+	name, err := p.word()
+	if err != nil {
+		return err
+	}
+	if p.is("{") {
+		return parseErr(tk, "parameterized assignment '%s' is not supported", name)
+	}
+
+	if isUpper(name) {
+		if !p.accept("::=") {
+			return parseErr(tk, "unsupported assignment '%s'", name)
+		}
+		if _, f := p.mod.types[name]; f {
+			return parseErr(tk, "duplicate type '%s'", name)
+		}
+		if p.is("CLASS") {
+			return parseErr(tk, "information object class '%s' is not supported", name)
+		}
+		tp, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		p.mod.types[name] = tp
+		p.mod.order = append(p.mod.order, name)
+		return nil
+	}
+
+	// value assignment: only INTEGER values are kept, for constraints
+	if _, err := p.parseType(); err != nil {
+		return err
+	}
+	if err := p.expect("::="); err != nil {
+		return err
+	}
+	_, err = p.parseValue()
+	return err
+}
+
+func (p *asnParser) parseNumber() (int, error) {
+	tk := p.next()
+	neg := 1
+	if tk.text == "-" {
+		neg = -1
+		tk = p.next()
+	}
+	switch tk.kind {
+	case tokNumber:
+		n, err := strconv.Atoi(tk.text)
+		if err != nil {
+			return 0, parseErr(tk, "invalid number '%s'", tk.text)
+		}
+		return n * neg, nil
+	case tokWord:
+		if n, f := p.mod.values[tk.text]; f {
+			return n * neg, nil
+		}
+	}
+	return 0, parseErr(tk, "expected number but got '%s'", tk.text)
+}
+
+func (p *asnParser) parseValue() (interface{}, error) {
+	tk := p.peek()
+	switch {
+	case tk.kind == tokNumber || tk.text == "-":
+		return p.parseNumber()
+	case tk.kind == tokCString:
+		p.next()
+		return tk.text, nil
+	case tk.kind == tokHString:
+		p.next()
+		return tk.text, nil
+	case tk.kind == tokBString:
+		p.next()
+		return tk.text, nil
+	case tk.text == "{":
+		return nil, p.skipBlock()
+	case tk.kind == tokWord:
+		p.next()
+		switch tk.text {
+		case "TRUE":
+			return true, nil
+		case "FALSE":
+			return false, nil
+		case "NULL":
+			return nil, nil
+		}
+		if n, f := p.mod.values[tk.text]; f {
+			return n, nil
+		}
+		return tk.text, nil
+	}
+	return nil, parseErr(tk, "unexpected '%s' in value", tk.text)
+}
+
+var asnStringTypes = []string{
+	"UTF8String", "NumericString", "PrintableString", "TeletexString", "T61String",
+	"VideotexString", "IA5String", "GraphicString", "VisibleString", "ISO646String",
+	"GeneralString", "UniversalString", "BMPString", "UTCTime", "GeneralizedTime",
+	"ObjectDescriptor",
+}
+
+func (p *asnParser) parseType() (map[string]interface{}, error) {
+	tk := p.peek()
+	if p.is("[") {
+		return p.parseTagged()
+	}
+
+	var tp map[string]interface{}
+	var err error
+	word, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	switch word {
+	case "BOOLEAN", "NULL", "REAL", "EXTERNAL":
+		tp = map[string]interface{}{"$type": word}
+	case "INTEGER":
+		tp = map[string]interface{}{"$type": word}
+		if p.is("{") {
+			err = p.skipBlock()
+		}
+	case "BIT":
+		err = p.expect("STRING")
+		tp = map[string]interface{}{"$type": "BIT_STRING"}
+		if err == nil && p.is("{") {
+			err = p.skipBlock()
+		}
+	case "OCTET":
+		err = p.expect("STRING")
+		tp = map[string]interface{}{"$type": "OCTET_STRING"}
+	case "OBJECT":
+		err = p.expect("IDENTIFIER")
+		tp = map[string]interface{}{"$type": "ObjectIdentifier"}
+	case "EMBEDDED":
+		err = p.expect("PDV")
+		tp = map[string]interface{}{"$type": "EMBEDDED_PDV"}
+	case "ENUMERATED":
+		tp, err = p.parseEnumerated()
+	case "SEQUENCE", "SET":
+		tp, err = p.parseStructured(word)
+	case "CHOICE":
+		tp, err = p.parseChoice()
+	case "ANY":
+		tp = map[string]interface{}{"$type": "ANY", "$field": map[string]interface{}{}}
+		if p.accept("DEFINED", "BY") {
+			_, err = p.word()
+		}
+	default:
+		for _, s := range asnStringTypes {
+			if s == word {
+				tp = map[string]interface{}{"$type": word}
+			}
+		}
+		switch word {
+		case "T61String":
+			tp["$type"] = "TeletexString"
+		case "ISO646String":
+			tp["$type"] = "VisibleString"
+		}
+		if tp != nil {
+			break
+		}
+		if !isUpper(word) {
+			return nil, parseErr(tk, "unexpected '%s' in type", word)
+		}
+		if p.accept(".") {
+			name, err := p.word()
+			if err != nil {
+				return nil, err
+			}
+			word += "." + name
+		}
+		tp = map[string]interface{}{"$type": word}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is("(") {
+		if err := p.parseConstraint(tp); err != nil {
+			return nil, err
+		}
+	}
+	return tp, nil
+}
+
+func (p *asnParser) parseTagged() (map[string]interface{}, error) {
+	tk := p.next()
+	if p.is("UNIVERSAL") {
+		return nil, parseErr(tk, "tag class '%s' is not supported", p.peek().text)
+	}
+	class := ""
+	if p.is("APPLICATION") || p.is("PRIVATE") {
+		class = p.next().text
+	}
+	n, err := p.parseNumber()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	outer := map[string]interface{}{"$tag": n}
+	if class != "" {
+		outer["$class"] = class
+	}
+	if p.accept("IMPLICIT") {
+		outer["$implicit"] = true
+	} else if p.accept("EXPLICIT") {
+		outer["$explicit"] = true
+	}
+	tp, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	inner := copyTag(tp)
+	for _, k := range tagKeys {
+		delete(tp, k)
+	}
+	if _, f := inner["$tag"]; f {
+		if !p.mod.implicitTag(outer, tp) {
+			// an explicit tag wraps the tag of the type, X.690 8.14.2
+			tp["$inner"] = inner
+		} else if !p.mod.implicitTag(inner, tp) {
+			// an implicit tag replacing an explicit one is explicit
+			delete(outer, "$implicit")
+			outer["$explicit"] = true
+			if in, f := inner["$inner"]; f {
+				tp["$inner"] = in
+			}
+		}
+	}
+	for k, v := range outer {
+		tp[k] = v
+	}
+	return tp, nil
+}
+
+func (p *asnParser) parseEnumerated() (map[string]interface{}, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	type item struct {
+		name string
+		val  int
+		set  bool
+		ext  bool
+	}
+	var items []item
+	used := make(map[int]bool)
+	ext := false
+	for !p.accept("}") {
+		if p.accept(",") {
+			continue
+		}
+		if p.accept("...") {
+			ext = true
+			if p.accept("!") {
+				if _, err := p.parseValue(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		name, err := p.word()
+		if err != nil {
+			return nil, err
+		}
+		it := item{name: name, ext: ext}
+		if p.accept("(") {
+			if it.val, err = p.parseNumber(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			it.set = true
+			used[it.val] = true
+		}
+		items = append(items, it)
+	}
+
+	// numbering of items without an explicit value, X.680 20.3
+	fld := make(map[string]interface{})
+	var add []interface{}
+	next, max := 0, -1
+	for _, it := range items {
+		if !it.set {
+			if it.ext {
+				it.val = max + 1
+			} else {
+				for used[next] {
+					next++
+				}
+				it.val = next
+			}
+			used[it.val] = true
+		}
+		if it.val > max {
+			max = it.val
+		}
+		fld[it.name] = it.val
+		if it.ext {
+			add = append(add, it.name)
+		}
+	}
+	tp := map[string]interface{}{"$type": "ENUMERATED", "$field": fld}
+	if ext {
+		tp["$extensible"] = true
+	}
+	if len(add) > 0 {
+		tp["$extensionItems"] = add
+	}
+	return tp, nil
+}
+
+func (p *asnParser) parseStructured(word string) (map[string]interface{}, error) {
+	tp := map[string]interface{}{"$type": word}
+	if p.is("(") {
+		if err := p.parseConstraint(tp); err != nil {
+			return nil, err
+		}
+	} else if p.accept("SIZE") {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		p.ext = false
+		size, _, err := p.parseElementSet(")")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		applyRange(tp, size)
+		if p.ext {
+			tp["$extensible"] = true
+		}
+	}
+	if p.accept("OF") {
+		if nx := p.peek(); nx.kind == tokWord && !isUpper(nx.text) {
+			p.next()
+		}
+		of, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		tp["$of"] = of
+		return tp, nil
+	}
+	fld, ext, err := p.parseComponents(false)
+	if err != nil {
+		return nil, err
+	}
+	tp["$field"] = fld
+	if ext {
+		tp["$extensible"] = true
+	}
+	return tp, nil
+}
+
+func (p *asnParser) parseChoice() (map[string]interface{}, error) {
+	fld, ext, err := p.parseComponents(true)
+	if err != nil {
+		return nil, err
+	}
+	tp := map[string]interface{}{"$type": "CHOICE", "$field": fld}
+	if ext {
+		tp["$extensible"] = true
+	}
+	return tp, nil
+}
+
+// parseComponents parses the component list of a SEQUENCE, SET or CHOICE
+// and reports whether it has an extension marker. Extension additions are
+// marked with $extension, the components of an addition group [[ ]] with
+// the number of the group in $group as well.
+func (p *asnParser) parseComponents(choice bool) (map[string]interface{}, bool, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, false, err
+	}
+	fld := make(map[string]interface{})
+	id := 0
+	ext, marked := false, false
+	add := func(tk asnToken, name string, tp map[string]interface{}) error {
+		if _, f := fld[name]; f {
+			return parseErr(tk, "duplicate component '%s'", name)
+		}
+		tp["$id"] = id
+		id++
+		fld[name] = tp
+		return nil
+	}
+
+	group, groups := 0, 0
+	for !p.accept("}") {
+		tk := p.peek()
+		switch {
+		case tk.kind == tokEOF:
+			return nil, false, parseErr(tk, "unterminated component list")
+		case p.accept(","):
+		case p.accept("..."):
+			ext = !ext
+			marked = true
+			if p.accept("!") {
+				if _, err := p.parseValue(); err != nil {
+					return nil, false, err
+				}
+			}
+		case p.accept("[["):
+			groups++
+			group = groups
+			if p.peek().kind == tokNumber && p.peekAt(1).text == ":" {
+				p.pos += 2
+			}
+		case p.accept("]]"):
+			group = 0
+		case !choice && p.accept("COMPONENTS", "OF"):
+			tp, err := p.parseType()
+			if err != nil {
+				return nil, false, err
+			}
+			if err := add(tk, fmt.Sprint("$components", id), map[string]interface{}{"$components": tp}); err != nil {
+				return nil, false, err
+			}
+		default:
+			name, err := p.word()
+			if err != nil {
+				return nil, false, err
+			}
+			tp, err := p.parseType()
+			if err != nil {
+				return nil, false, err
+			}
+			if !choice {
+				if p.accept("OPTIONAL") {
+					tp["$optional"] = true
+				} else if p.accept("DEFAULT") {
+					if tp["$default"], err = p.parseValue(); err != nil {
+						return nil, false, err
+					}
+					tp["$optional"] = true
+				}
+			}
+			if group > 0 {
+				// the group is present or absent as a whole, its
+				// mandatory components stay mandatory
+				tp["$extension"] = true
+				tp["$group"] = group
+			} else if ext {
+				tp["$extension"] = true
+				if !choice {
+					// extension additions may be absent when the peer is older
+					tp["$optional"] = true
+				}
+			}
+			if err := add(tk, name, tp); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	return fld, marked, nil
+}
+
+func (p *asnParser) parseConstraint(tp map[string]interface{}) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	p.ext = false
+	val, size, err := p.parseElementSet(")")
+	if err != nil {
+		return err
+	}
+	if err := p.expect(")"); err != nil {
+		return err
+	}
+	if tp["$type"] == "INTEGER" {
+		applyRange(tp, val)
+	} else {
+		applyRange(tp, size)
+	}
+	if p.ext {
+		tp["$extensible"] = true
+	}
+	return nil
+}
+
+// applyRange sets $min and $max of tp. Bounds given by a value reference
+// are kept by name until the module is expanded.
+func applyRange(tp map[string]interface{}, rng *asnRange) {
+	if rng == nil {
+		return
+	}
+	if rng.loRef != "" {
+		tp["$min"] = rng.loRef
+	} else if rng.hasLo {
+		tp["$min"] = rng.lo
+	}
+	if rng.hiRef != "" {
+		tp["$max"] = rng.hiRef
+	} else if rng.hasHi {
+		tp["$max"] = rng.hi
+	}
+}
+
+// parseBound parses one end of a value range, which may be a reference to
+// a value that is not known yet.
+func (p *asnParser) parseBound() (n int, ref string, err error) {
+	tk := p.peek()
+	if _, f := p.mod.values[tk.text]; tk.kind == tokWord && !isUpper(tk.text) && !f {
+		p.next()
+		return 0, tk.text, nil
+	}
+	n, err = p.parseNumber()
+	return
+}
+
+func unionRange(a, b *asnRange) *asnRange {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	out := *a
+	out.hasLo = a.hasLo && b.hasLo
+	if b.lo < out.lo {
+		out.lo = b.lo
+	}
+	out.hasHi = a.hasHi && b.hasHi
+	if b.hi > out.hi {
+		out.hi = b.hi
+	}
+	return &out
+}
+
+func intersectRange(a, b *asnRange) *asnRange {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	out := *a
+	if b.hasLo && (!out.hasLo || b.lo > out.lo) {
+		out.lo, out.hasLo = b.lo, true
+	}
+	if b.hasHi && (!out.hasHi || b.hi < out.hi) {
+		out.hi, out.hasHi = b.hi, true
+	}
+	return &out
+}
+
+// parseElementSet evaluates a constraint specification into the value and
+// size ranges it permits. Constraints that cannot be expressed as a range
+// (permitted alphabets, table and inner type constraints) are skipped.
+func (p *asnParser) parseElementSet(end string) (val, size *asnRange, err error) {
+	op := "|"
+	for !p.is(end) {
+		tk := p.peek()
+		var v, s *asnRange
+		switch {
+		case tk.kind == tokEOF:
+			return nil, nil, parseErr(tk, "unterminated constraint")
+		case p.accept(","):
+			continue
+		case p.accept("..."):
+			p.ext = true
+			continue
+		case p.accept("|"), p.accept("UNION"):
+			op = "|"
+			continue
+		case p.accept("^"), p.accept("INTERSECTION"):
+			op = "^"
+			continue
+		case p.accept("EXCEPT"):
+			op = "EXCEPT"
+			continue
+		case p.accept("ALL"):
+			continue
+		case p.accept("SIZE"):
+			if err = p.expect("("); err != nil {
+				return
+			}
+			if s, _, err = p.parseElementSet(")"); err != nil {
+				return
+			}
+			if err = p.expect(")"); err != nil {
+				return
+			}
+		case p.accept("("):
+			if v, s, err = p.parseElementSet(")"); err != nil {
+				return
+			}
+			if err = p.expect(")"); err != nil {
+				return
+			}
+		case p.accept("FROM"), p.accept("PATTERN"), p.accept("WITH", "COMPONENT"), p.accept("WITH", "COMPONENTS"):
+			if p.is("(") || p.is("{") {
+				err = p.skipBlock()
+			} else {
+				_, err = p.parseValue()
+			}
+			if err != nil {
+				return
+			}
+			continue
+		case p.accept("CONTAINING"):
+			if _, err = p.parseType(); err != nil {
+				return
+			}
+			if p.accept("ENCODED", "BY") {
+				_, err = p.parseValue()
+			}
+			if err != nil {
+				return
+			}
+			continue
+		case p.is("{"), p.is("@"):
+			if p.accept("@") {
+				p.next()
+			} else if err = p.skipBlock(); err != nil {
+				return
+			}
+			continue
+		default:
+			v = &asnRange{}
+			if p.accept("MIN") {
+			} else if v.lo, v.loRef, err = p.parseBound(); err == nil {
+				v.hasLo = true
+			} else {
+				return nil, nil, err
+			}
+			v.hi, v.hasHi, v.hiRef = v.lo, v.hasLo, v.loRef
+			if p.accept("<") {
+				v.lo++
+			}
+			if p.accept("..") {
+				v.hasHi, v.hiRef = false, ""
+				less := p.accept("<")
+				if !p.accept("MAX") {
+					if v.hi, v.hiRef, err = p.parseBound(); err != nil {
+						return
+					}
+					if less {
+						v.hi--
+					}
+					v.hasHi = true
+				}
+			}
+		}
+		switch op {
+		case "|":
+			if v != nil {
+				val = unionRange(val, v)
+			}
+			if s != nil {
+				size = unionRange(size, s)
+			}
+		case "^":
+			val = intersectRange(val, v)
+			size = intersectRange(size, s)
+		}
+	}
+	return
+}
+
+// expand resolves COMPONENTS OF, checks type references and applies the
+// tagging environment of the module.
+func (m *asnModule) expand() error {
+	for _, name := range m.order {
+		if _, err := m.resolve(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve expands the type name, of this module or imported. The stack
+// holds the qualified names of the types being expanded, so that a
+// recursive COMPONENTS OF is reported rather than followed.
+func (m *asnModule) resolve(name string, stack []string) (map[string]interface{}, error) {
+	if m.expanded[name] {
+		return m.types[name], nil
+	}
+	if _, f := m.types[name]; !f {
+		if mod, cls := m.origin(name); mod != "" && m.link != nil {
+			return m.link.resolve(mod, cls, stack)
+		}
+	}
+	qn := m.name + "." + name
+	for _, s := range stack {
+		if s == qn {
+			return nil, Errorf("asn1 parse: recursive COMPONENTS OF in '%s'", name)
+		}
+	}
+	if _, err := m.target(name, nil); err != nil {
+		return nil, err
+	}
+	tp, err := m.expandType(m.types[name], append(stack, qn))
+	if err != nil {
+		return nil, err
+	}
+	m.types[name] = tp
+	m.expanded[name] = true
+	return tp, nil
+}
+
+// origin returns the module a type not defined in this one comes from, by
+// its imports or a qualified name, and its name there.
+func (m *asnModule) origin(name string) (string, string) {
+	mod, cls := m.imports[name], name
+	if i := strings.LastIndex(name, "."); mod == "" && i > 0 {
+		mod, cls = name[:i], name[i+1:]
+	}
+	return mod, cls
+}
+
+// target follows a chain of type references to the type it denotes. The
+// types it leads to are not expanded; the stack holds the qualified names of
+// the types the chain entered in other modules, so that a cycle between
+// modules ends.
+func (m *asnModule) target(name string, stack []string) (map[string]interface{}, error) {
+	for i := 0; ; i++ {
+		tp, f := m.types[name]
+		if !f {
+			mod, cls := m.origin(name)
+			if mod != "" && m.link != nil {
+				qn := mod + "." + cls
+				for _, s := range stack {
+					if s == qn {
+						return nil, Errorf("asn1 parse: cyclic reference in '%s'", qn)
+					}
+				}
+				return m.link.target(mod, cls, append(stack, qn))
+			}
+			if mod != "" {
+				return nil, Errorf("asn1 parse: type '%s' imported from '%s' is not defined", name, mod)
+			}
+			return nil, Errorf("asn1 parse: unknown type '%s'", name)
+		}
+		tn, _ := tp["$type"].(string)
+		if isBuiltin(tn) {
+			return tp, nil
+		}
+		if i > maxRefDepth {
+			return nil, Errorf("asn1 parse: cyclic reference in '%s'", name)
+		}
+		name = tn
+	}
+}
+
+func (m *asnModule) value(name string) (int, error) {
+	if n, f := m.values[name]; f {
+		return n, nil
+	}
+	if mod, f := m.imports[name]; f && m.link != nil {
+		return m.link.value(mod, name)
+	}
+	return 0, Errorf("asn1 parse: unknown value '%s' in '%s'", name, m.name)
+}
+
+// sheme returns the module as a JSON sheme module of a Registry.
+func (m *asnModule) sheme() map[string]interface{} {
+	out := map[string]interface{}{"$module": m.name}
+	imp := make(map[string]interface{})
+	for sym, mod := range m.imports {
+		if isUpper(sym) {
+			lst, _ := imp[mod].([]string)
+			imp[mod] = append(lst, sym)
+		}
+	}
+	for _, lst := range imp {
+		sort.Strings(lst.([]string))
+	}
+	if len(imp) > 0 {
+		out["$imports"] = imp
+	}
+	for _, name := range m.order {
+		out[name] = m.types[name]
+	}
+	return out
+}
+
+func copyMap(src map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		if mp, ok := v.(map[string]interface{}); ok {
+			v = copyMap(mp)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// kind returns the builtin type of tp, looking through references.
+func (m *asnModule) kind(tp map[string]interface{}) string {
+	tn, _ := tp["$type"].(string)
+	if !isBuiltin(tn) {
+		if tgt, err := m.target(tn, nil); err == nil {
+			tn, _ = tgt["$type"].(string)
+		}
+	}
+	return tn
+}
+
+// implicitTag reports whether the tag written on outer replaces the tag of
+// the type it is applied to, X.680 31.2.7.
+func (m *asnModule) implicitTag(outer, inner map[string]interface{}) bool {
+	if outer["$implicit"] == true {
+		return true
+	}
+	if outer["$explicit"] == true || m.tagging == "EXPLICIT" {
+		return false
+	}
+	tn := m.kind(inner)
+	return tn != "CHOICE" && tn != "ANY"
+}
+
+func (m *asnModule) expandType(tp map[string]interface{}, stack []string) (map[string]interface{}, error) {
+	tn, _ := tp["$type"].(string)
+	if !isBuiltin(tn) {
+		if _, err := m.target(tn, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if of, ok := tp["$of"].(map[string]interface{}); ok {
+		of, err := m.expandType(of, stack)
+		if err != nil {
+			return nil, err
+		}
+		tp["$of"] = of
+	}
+
+	if fld, ok := tp["$field"].(map[string]interface{}); ok && tp["$type"] != "ENUMERATED" {
+		list, err := NewFieldList(fld)
+		if err != nil {
+			return nil, err
+		}
+		// automatic tagging only applies when no component is tagged in the
+		// text of the definition, X.680 25.3
+		auto := m.tagging == "AUTOMATIC" && tp["$type"] != "ANY"
+		out := make(map[string]interface{})
+		id := 0
+		for sh := list.Begin(); sh != nil; sh = list.Next() {
+			itm, _ := sh.obj.Map()
+			if cmp, ok := itm["$components"].(map[string]interface{}); ok {
+				cn, _ := cmp["$type"].(string)
+				if isBuiltin(cn) {
+					cmp, err = m.expandType(cmp, stack)
+				} else {
+					cmp, err = m.resolve(cn, stack)
+				}
+				if err != nil {
+					return nil, err
+				}
+				inner, _ := cmp["$field"].(map[string]interface{})
+				if cmp["$type"] != "SEQUENCE" && cmp["$type"] != "SET" || inner == nil {
+					return nil, Errorf("asn1 parse: COMPONENTS OF requires a SEQUENCE or SET type")
+				}
+				sub, _ := NewFieldList(inner)
+				for c := sub.Begin(); c != nil; c = sub.Next() {
+					if c.Extension() {
+						// only the root components are taken, X.680 25.5
+						continue
+					}
+					cm := copyMap(c.obj.MustMap())
+					cm["$id"] = id
+					id++
+					out[c.Name()] = cm
+				}
+				continue
+			}
+			if _, f := itm["$tag"]; f {
+				auto = false
+			}
+			itm, err := m.expandType(itm, stack)
+			if err != nil {
+				return nil, err
+			}
+			itm["$id"] = id
+			id++
+			out[sh.Name()] = itm
+		}
+		if auto {
+			for _, v := range out {
+				itm := v.(map[string]interface{})
+				delete(itm, "$implicit")
+				delete(itm, "$explicit")
+				itm["$tag"] = itm["$id"]
+				if tn := m.kind(itm); tn == "CHOICE" || tn == "ANY" {
+					itm["$explicit"] = true
+				} else {
+					itm["$implicit"] = true
+				}
+			}
+		}
+		if tp["$type"] == "CHOICE" {
+			// an alternative left untagged keeps the tag of its type
+			for _, v := range out {
+				itm := v.(map[string]interface{})
+				if _, f := itm["$tag"]; !f {
+					itm["$untagged"] = true
+				}
+			}
+		}
+		tp["$field"] = out
+	}
+
+	for _, k := range []string{"$min", "$max"} {
+		if ref, ok := tp[k].(string); ok {
+			n, err := m.value(ref)
+			if err != nil {
+				return nil, err
+			}
+			tp[k] = n
+		}
+	}
+
+	// spell out the tagging mode, as JSON schemas otherwise fall back
+	// to the global mode
+	if _, f := tp["$tag"]; f && tp["$implicit"] != true && tp["$explicit"] != true {
+		if m.implicitTag(tp, tp) {
+			tp["$implicit"] = true
+		} else {
+			tp["$explicit"] = true
+		}
+	}
+	return tp, nil
+}
+
+func newShemeASN1(src []byte) (*Sheme, error) {
+	mods, err := parseASN1(src)
+	if err != nil {
+		return nil, err
+	}
+	// the modules of the text import from each other
+	link := make(asnModules, len(mods))
+	for _, m := range mods {
+		if _, f := link[m.name]; f {
+			return nil, Errorf("asn1 parse: duplicate module '%s'", m.name)
+		}
+		link[m.name] = m
+		m.link = link
+	}
+	classes := make(map[string]interface{})
+	for _, m := range mods {
+		if err := m.expand(); err != nil {
+			return nil, err
+		}
+		for _, name := range m.order {
+			if _, f := classes[name]; f {
+				return nil, Errorf("asn1 parse: duplicate type '%s' in module '%s'", name, m.name)
+			}
+			link.unqualify(m.types[name])
+			classes[name] = m.types[name]
+		}
+	}
+	data, err := json.Marshal(classes)
+	if err != nil {
+		return nil, err
+	}
+	sh, err := NewSheme(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(mods) == 1 {
+		sh.name = mods[0].name
+	}
+	return sh, nil
+}
+
+// NewShemeASN1 compiles ASN.1 module text (X.680 notation) into a Sheme.
+// The classes of several modules in the text, which may import from each
+// other, make up one Sheme, so their names must differ.
+func NewShemeASN1(data []byte) (*Sheme, error) {
+	return newShemeASN1(data)
+}
+
+// NewShemeASN1Reader reads ASN.1 module text from rd and compiles it into a Sheme.
+func NewShemeASN1Reader(rd io.Reader) (*Sheme, error) {
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	return newShemeASN1(data)
+}
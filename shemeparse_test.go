@@ -0,0 +1,279 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+const validityModule = `
+Validity-Module DEFINITIONS EXPLICIT TAGS ::= BEGIN
+Validity ::= SEQUENCE {
+  notBefore Time,
+  notAfter  Time
+}
+Time ::= CHOICE {
+  utcTime     UTCTime,
+  generalTime GeneralizedTime
+}
+END
+`
+
+func TestParseUntaggedChoice(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(validityModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	validity := sh.Class("Validity")
+	if validity == nil {
+		t.Fatal("no Validity")
+	}
+
+	// notBefore 2020-01-02 03:04:05Z as UTCTime, notAfter 2050-01-02
+	// 03:04:05Z as GeneralizedTime
+	der, _ := hex.DecodeString("3020" +
+		"170d3230303130323033303430355a" +
+		"180f32303530303130323033303430355a")
+	c := NewCodec(Options{Strict: true})
+	js, err := c.Decode(validity, der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := js.Get("notBefore").CheckGet("utcTime"); !ok {
+		t.Errorf("notBefore: %v", js.Get("notBefore").Interface())
+	}
+	if _, ok := js.Get("notAfter").CheckGet("generalTime"); !ok {
+		t.Errorf("notAfter: %v", js.Get("notAfter").Interface())
+	}
+
+	out, err := c.Encode(validity, js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, der) {
+		t.Errorf("encoded %x, want %x", out, der)
+	}
+}
+
+func TestParseExtensionGroup(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(`G DEFINITIONS IMPLICIT TAGS ::= BEGIN
+T ::= SEQUENCE { a INTEGER, ..., [[ b [1] BOOLEAN, c [2] INTEGER OPTIONAL ]], [[ d [3] INTEGER ]], e [4] BOOLEAN }
+END`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	for _, want := range []struct {
+		name     string
+		group    int
+		optional bool
+	}{{"a", 0, false}, {"b", 1, false}, {"c", 1, true}, {"d", 2, false}, {"e", 0, true}} {
+		f := cls.Field(want.name)
+		if f.Group() != want.group || f.Optional() != want.optional {
+			t.Errorf("%s: group %d optional %t, want %d %t", want.name, f.Group(), f.Optional(), want.group, want.optional)
+		}
+	}
+
+	// a group is left out as a whole or sent with its mandatory components
+	for _, tc := range []struct {
+		val map[string]interface{}
+		ok  bool
+	}{
+		{map[string]interface{}{"a": 1}, true},
+		{map[string]interface{}{"a": 1, "b": true}, true},
+		{map[string]interface{}{"a": 1, "d": 4}, true},
+		{map[string]interface{}{"a": 1, "c": 2}, false},
+	} {
+		if _, err := Encode(cls, tc.val); (err == nil) != tc.ok {
+			t.Errorf("%v: %v", tc.val, err)
+		}
+	}
+	ber, _ := hex.DecodeString("3006020101820102")
+	if _, err := NewCodec(Options{}).Decode(cls, ber); err == nil {
+		t.Error("decoded a group without its mandatory component")
+	}
+}
+
+// kerberosModule is an excerpt of RFC 4120, where the tags of the
+// components of AP-REQ wrap the tags of the types they refer to.
+const kerberosModule = `
+KerberosV5Spec2 DEFINITIONS EXPLICIT TAGS ::= BEGIN
+Int32 ::= INTEGER (-2147483648..2147483647)
+KerberosString ::= GeneralString
+Realm ::= KerberosString
+PrincipalName ::= SEQUENCE {
+  name-type   [0] Int32,
+  name-string [1] SEQUENCE OF KerberosString
+}
+EncryptedData ::= SEQUENCE {
+  etype  [0] Int32,
+  kvno   [1] INTEGER OPTIONAL,
+  cipher [2] OCTET STRING
+}
+Ticket ::= [APPLICATION 1] SEQUENCE {
+  tkt-vno  [0] INTEGER (5),
+  realm    [1] Realm,
+  sname    [2] PrincipalName,
+  enc-part [3] EncryptedData
+}
+AP-REQ ::= [APPLICATION 14] SEQUENCE {
+  pvno          [0] INTEGER (5),
+  msg-type      [1] INTEGER (14),
+  ticket        [3] Ticket,
+  authenticator [4] EncryptedData
+}
+END
+`
+
+func TestParseNestedTags(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(kerberosModule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := "300a" + "a003020100" + "a203040101"
+	tkt := "612a" + "3028" + "a003020105" + "a1031b0152" +
+		"a20e300ca003020101a10530031b016b" + "a30c" + enc
+	der, _ := hex.DecodeString("6e48" + "3046" + "a003020105" + "a10302010e" +
+		"a32c" + tkt + "a40c" + enc)
+	c := NewCodec(Options{Strict: true})
+	js, err := c.Decode(sh.Class("AP-REQ"), der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := js.Get("ticket").Get("realm").MustString(); got != "R" {
+		t.Errorf("realm %q, want R", got)
+	}
+	out, err := c.Encode(sh.Class("AP-REQ"), js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, der) {
+		t.Errorf("encoded %x, want %x", out, der)
+	}
+
+	// within the text of a type, and an implicit tag in place of an
+	// explicit one
+	sh, err = NewShemeASN1([]byte(`N DEFINITIONS EXPLICIT TAGS ::= BEGIN
+T ::= SEQUENCE { a [1] [APPLICATION 2] INTEGER, b [3] IMPLICIT [4] INTEGER, c [5] [6] IMPLICIT INTEGER }
+END`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	val := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+	const want = "3011" + "a1056203020101" + "a303020102" + "a503860103"
+	if out, err := Encode(sh.Class("T"), val); err != nil || hex.EncodeToString(out) != want {
+		t.Errorf("encoded %x %v, want %s", out, err, want)
+	}
+}
+
+func TestParseSeveralModules(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(`
+A DEFINITIONS IMPLICIT TAGS ::= BEGIN
+IMPORTS Item, max FROM B;
+List ::= SEQUENCE (SIZE (1..max)) OF Item
+Pair ::= SEQUENCE { a [0] Item, b [1] B.Item OPTIONAL }
+END
+B DEFINITIONS EXPLICIT TAGS ::= BEGIN
+Item ::= [APPLICATION 1] INTEGER
+max INTEGER ::= 2
+END
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sh.Class("List").MaxAttr(); got != 2 {
+		t.Errorf("List size at most %d, want 2", got)
+	}
+	// each module keeps its tagging environment
+	out, err := Encode(sh.Class("Pair"), map[string]interface{}{"a": 1, "b": 2})
+	if want := "300a" + "a003020101" + "a103020102"; err != nil || hex.EncodeToString(out) != want {
+		t.Errorf("encoded %x %v, want %s", out, err, want)
+	}
+}
+
+func TestParseEnumeratedExtension(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(`E DEFINITIONS ::= BEGIN
+Color ::= ENUMERATED { red, green, ..., blue }
+END`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("Color")
+	if add := cls.ExtensionItems(); len(add) != 1 || add[0] != "blue" {
+		t.Errorf("additions %v, want [blue]", add)
+	}
+	// '$extension' marks a component addition only
+	if _, f := cls.obj.CheckGet("$extension"); f {
+		t.Errorf("'$extension' set on %s", cls)
+	}
+	var buf bytes.Buffer
+	if err := sh.WriteASN1(&buf, "E"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ENUMERATED { red(0), green(1), ..., blue(2) }"; !bytes.Contains(buf.Bytes(), []byte(want)) {
+		t.Errorf("written\n%s\nwant %s", buf.String(), want)
+	}
+}
+
+func TestWriteASN1(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		cls  string
+		val  map[string]interface{}
+	}{
+		{"validity", validityModule, "Validity", map[string]interface{}{
+			"notBefore": map[string]interface{}{"utcTime": "2020-01-02T03:04:05Z"},
+			"notAfter":  map[string]interface{}{"generalTime": "2050-01-02T03:04:05Z"}}},
+		{"kerberos", kerberosModule, "Ticket", map[string]interface{}{
+			"tkt-vno": 5, "realm": "R",
+			"sname":    map[string]interface{}{"name-type": 1, "name-string": []interface{}{"a", "b"}},
+			"enc-part": map[string]interface{}{"etype": 2, "cipher": []byte{1, 2}}}},
+		{"attributes", `W DEFINITIONS IMPLICIT TAGS ::= BEGIN
+T ::= SEQUENCE {
+  id   INTEGER (0..1000),
+  name [0] EXPLICIT UTF8String (SIZE (1..8)) OPTIONAL,
+  kind ENUMERATED { a, b(5), ..., c } DEFAULT b,
+  n    INTEGER DEFAULT 7,
+  s    [1] SET { x [0] INTEGER, y [1] BOOLEAN OPTIONAL },
+  l    SEQUENCE (SIZE (0..4)) OF Item,
+  p    Pick,
+  tk   [2] Tkt,
+  ...
+}
+Item ::= [APPLICATION 3] SEQUENCE { v INTEGER }
+Tkt ::= [APPLICATION 1] EXPLICIT SEQUENCE { x INTEGER }
+Pick ::= CHOICE { i [5] INTEGER, o [PRIVATE 6] OCTET STRING }
+END`, "T", map[string]interface{}{
+			"id": 5, "name": "nm", "kind": "c", "s": map[string]interface{}{"x": 1},
+			"l": []interface{}{map[string]interface{}{"v": 2}},
+			"p": map[string]interface{}{"o": []byte{1}}, "tk": map[string]interface{}{"x": 3}}},
+	} {
+		sh, err := NewShemeASN1([]byte(tc.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text bytes.Buffer
+		if err := sh.WriteASN1(&text, "W"); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		// the module written parses to a sheme of the same encodings, and
+		// is written again as it is
+		back, err := NewShemeASN1(text.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", tc.name, err, text.String())
+		}
+		var again bytes.Buffer
+		if err := back.WriteASN1(&again, "W"); err != nil || again.String() != text.String() {
+			t.Errorf("%s: written again %v\n%s\nwant\n%s", tc.name, err, again.String(), text.String())
+		}
+		want, err := NewCodec(Options{Strict: true}).Encode(sh.Class(tc.cls), tc.val)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		got, err := NewCodec(Options{Strict: true}).Encode(back.Class(tc.cls), tc.val)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s: encoded %x %v, want %x", tc.name, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package asn1dynamic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type asnWriter struct {
+	buf *bufio.Writer
+}
+
+// asnIdentifier converts a sheme name into a valid ASN.1 identifier
+// (upper=false) or type reference (upper=true).
+func asnIdentifier(name string, upper bool) string {
+	var out []byte
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !isWordChar(c) || c == '_' {
+			c = '-'
+		}
+		if c == '-' && (len(out) == 0 || out[len(out)-1] == '-') {
+			continue
+		}
+		out = append(out, c)
+	}
+	for len(out) > 0 && out[len(out)-1] == '-' {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 || isDigit(out[0]) {
+		out = append([]byte("x-"), out...)
+	}
+	if upper {
+		out[0] = strings.ToUpper(string(out[0]))[0]
+	} else {
+		out[0] = strings.ToLower(string(out[0]))[0]
+	}
+	return string(out)
+}
+
+func asnTypeName(tp string) string {
+	switch tp {
+	case "BIT_STRING":
+		return "BIT STRING"
+	case "OCTET_STRING":
+		return "OCTET STRING"
+	case "ObjectIdentifier":
+		return "OBJECT IDENTIFIER"
+	case "EMBEDDED_PDV":
+		return "EMBEDDED PDV"
+	}
+	return tp
+}
+
+func (wr *asnWriter) indent(lvl int) {
+	wr.buf.WriteString(strings.Repeat("    ", lvl))
+}
+
+func (wr *asnWriter) writeRange(sh *Sheme, size bool) {
+	hasMin, hasMax := sh.bounds()
+	if !hasMin && !hasMax {
+		return
+	}
+	lo, hi := "MIN", "MAX"
+	if size {
+		lo = "0"
+	}
+	if hasMin {
+		lo = strconv.Itoa(sh.MinAttr())
+	}
+	if hasMax {
+		hi = strconv.Itoa(sh.MaxAttr())
+	}
+	if sh.Extensible() {
+		hi += ", ..."
+	}
+	if size {
+		fmt.Fprintf(wr.buf, " (SIZE (%s..%s))", lo, hi)
+	} else {
+		fmt.Fprintf(wr.buf, " (%s..%s)", lo, hi)
+	}
+}
+
+func (wr *asnWriter) writeTag(sh *Sheme) {
+	if !sh.Tagged() {
+		return
+	}
+	switch sh.TagClass() {
+	case classApplication:
+		fmt.Fprintf(wr.buf, "[APPLICATION %d] ", sh.Index())
+	case classPrivate:
+		fmt.Fprintf(wr.buf, "[PRIVATE %d] ", sh.Index())
+	default:
+		fmt.Fprintf(wr.buf, "[%d] ", sh.Index())
+	}
+	tmp := &AsnData{}
+	markTag(tmp, sh.resolve(), ExplicitTags)
+	// an implicit tag of a reference takes the mode of the tag it replaces
+	if tmp.tag.implicit && !tmp.tag.explicit || sh.Implicit() && sh.Ref() != "" {
+		wr.buf.WriteString("IMPLICIT ")
+	} else {
+		wr.buf.WriteString("EXPLICIT ")
+	}
+	if in := sh.Inner(); in != nil {
+		wr.writeTag(in)
+	}
+}
+
+func (wr *asnWriter) writeDefault(sh *Sheme) {
+	def := sh.DefAttr()
+	if def == nil {
+		return
+	}
+	wr.buf.WriteString(" DEFAULT ")
+	switch v := def.(type) {
+	case bool:
+		if v {
+			wr.buf.WriteString("TRUE")
+		} else {
+			wr.buf.WriteString("FALSE")
+		}
+	case string:
+		if sh.TypeEn() == tagENUMERATED {
+			wr.buf.WriteString(asnIdentifier(v, false))
+		} else {
+			wr.buf.WriteString(strconv.Quote(v))
+		}
+	default:
+		fmt.Fprint(wr.buf, v)
+	}
+}
+
+func (wr *asnWriter) writeComponents(sh *Sheme, lvl int) error {
+	fld, _ := newFieldList(sh.FieldAttr(), sh.field)
+	wr.buf.WriteString(" {\n")
+	n := 0
+	ext, marked := false, false
+	marker := func() {
+		if n > 0 {
+			wr.buf.WriteString(",\n")
+		}
+		n++
+		wr.indent(lvl + 1)
+		wr.buf.WriteString("...")
+		marked = true
+	}
+	group := 0
+	for el := fld.Begin(); el != nil; el = fld.Next() {
+		if el.Extension() != ext {
+			ext = !ext
+			marker()
+		}
+		if n > 0 {
+			wr.buf.WriteString(",\n")
+		}
+		n++
+		wr.indent(lvl + 1)
+		if g := el.Group(); g != group && g > 0 {
+			wr.buf.WriteString("[[ ")
+		}
+		group = el.Group()
+		wr.buf.WriteString(asnIdentifier(el.Name(), false))
+		wr.buf.WriteString(" ")
+		if err := wr.writeType(el, lvl+1); err != nil {
+			return err
+		}
+		if sh.TypeEn() != tagCHOICE {
+			if el.DefAttr() != nil {
+				wr.writeDefault(el.resolve())
+			} else if el.Optional() {
+				wr.buf.WriteString(" OPTIONAL")
+			}
+		}
+		// the group ends with its last component
+		if next := fld.rest(); group > 0 && (len(next) < 2 || next[1].Group() != group) {
+			wr.buf.WriteString(" ]]")
+		}
+	}
+	if sh.Extensible() && !marked {
+		marker()
+	}
+	wr.buf.WriteString("\n")
+	wr.indent(lvl)
+	wr.buf.WriteString("}")
+	return nil
+}
+
+// writeType renders sh, which is not resolved, so that references to other
+// classes are written as type references.
+func (wr *asnWriter) writeType(sh *Sheme, lvl int) error {
+	wr.writeTag(sh)
+	if ref := sh.Ref(); ref != "" {
+		if i := strings.LastIndex(ref, "."); i > 0 {
+			// external type reference
+			wr.buf.WriteString(asnIdentifier(ref[:i], true) + ".")
+			ref = ref[i+1:]
+		}
+		wr.buf.WriteString(asnIdentifier(ref, true))
+		switch sh.resolve().TypeEn() {
+		case tagINTEGER:
+			wr.writeRange(sh, false)
+		case tagSEQUENCE, tagSET, tagCHOICE, tagANY, tagENUMERATED:
+		default:
+			wr.writeRange(sh, true)
+		}
+		return nil
+	}
+
+	tp := sh.Type()
+	switch sh.TypeEn() {
+	case tagENUMERATED:
+		enm := sh.EnumItems()
+		ids := make([]int, 0, len(enm))
+		for id := range enm {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		add := make(map[string]bool)
+		for _, name := range sh.ExtensionItems() {
+			add[name] = true
+		}
+		wr.buf.WriteString("ENUMERATED {")
+		marked := false
+		for i, id := range ids {
+			if i > 0 {
+				wr.buf.WriteString(",")
+			}
+			if add[enm[id]] && !marked {
+				wr.buf.WriteString(" ...,")
+				marked = true
+			}
+			fmt.Fprintf(wr.buf, " %s(%d)", asnIdentifier(enm[id], false), id)
+		}
+		if sh.Extensible() && !marked {
+			wr.buf.WriteString(", ...")
+		}
+		wr.buf.WriteString(" }")
+	case tagSEQUENCE, tagSET:
+		wr.buf.WriteString(tp)
+		if of := sh.OfAttr(); of != nil {
+			wr.writeRange(sh, true)
+			wr.buf.WriteString(" OF ")
+			return wr.writeType(sh.raw(of, sh.Name()), lvl)
+		}
+		return wr.writeComponents(sh, lvl)
+	case tagCHOICE:
+		wr.buf.WriteString(tp)
+		return wr.writeComponents(sh, lvl)
+	case tagANY:
+		wr.buf.WriteString("ANY")
+		// alternatives selected by ObjectDescriptor have no ASN.1 notation
+		var keys []string
+		for k := range sh.FieldAttr() {
+			keys = append(keys, k)
+		}
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			fmt.Fprintf(wr.buf, " -- %s --", strings.Join(keys, ", "))
+		}
+	case tagINTEGER:
+		wr.buf.WriteString(tp)
+		wr.writeRange(sh, false)
+	case tagBIT_STR, tagOCTET_STR, tagUTF8String, tagNumericString, tagPrintableString,
+		tagTeletexString, tagVideotexString, tagIA5String, tagGraphicString,
+		tagVisibleString, tagGeneralString, tagUniversalString, tagBMPString:
+		wr.buf.WriteString(asnTypeName(tp))
+		wr.writeRange(sh, true)
+	default:
+		if tp == "" || (sh.TypeEn() == tagEOC && tp != "EOC") {
+			return Errorf("asn1 write: '%s' of unknown type '%s'", sh.Name(), tp)
+		}
+		wr.buf.WriteString(asnTypeName(tp))
+	}
+	return nil
+}
+
+func (wr *asnWriter) writeImports(imports map[string]string) {
+	if len(imports) == 0 {
+		return
+	}
+	from := make(map[string][]string)
+	var mods []string
+	for sym, mod := range imports {
+		if from[mod] == nil {
+			mods = append(mods, mod)
+		}
+		from[mod] = append(from[mod], asnIdentifier(sym, true))
+	}
+	sort.Strings(mods)
+	wr.buf.WriteString("\nIMPORTS")
+	for _, mod := range mods {
+		sort.Strings(from[mod])
+		fmt.Fprintf(wr.buf, "\n    %s FROM %s", strings.Join(from[mod], ", "), asnIdentifier(mod, true))
+	}
+	wr.buf.WriteString(";\n")
+}
+
+// Classes returns the names of the top level classes of the sheme.
+func (s *Sheme) Classes() []string {
+	mp, _ := s.top().obj.Map()
+	out := make([]string, 0, len(mp))
+	for k := range mp {
+		if !strings.HasPrefix(k, "$") {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// WriteASN1 renders every class of the sheme as an ASN.1 module (X.680
+// notation) named module, or after the sheme when module is empty.
+func (s *Sheme) WriteASN1(w io.Writer, module string) error {
+	if module == "" {
+		module = s.Name()
+	}
+	if module == "" {
+		module = "Module"
+	}
+	wr := &asnWriter{buf: bufio.NewWriter(w)}
+	fmt.Fprintf(wr.buf, "%s DEFINITIONS EXPLICIT TAGS ::= BEGIN\n", asnIdentifier(module, true))
+	wr.writeImports(s.top().imports)
+	for _, name := range s.Classes() {
+		wr.buf.WriteString("\n")
+		wr.buf.WriteString(asnIdentifier(name, true))
+		wr.buf.WriteString(" ::= ")
+		if err := wr.writeType(s.raw(s.classMap(name), name), 0); err != nil {
+			return err
+		}
+		wr.buf.WriteString("\n")
+	}
+	wr.buf.WriteString("\nEND\n")
+	return wr.buf.Flush()
+}
@@ -0,0 +1,263 @@
+package asn1dynamic
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var bigIntType = reflect.TypeOf(big.Int{})
+
+func structErr(sh *Sheme, t reflect.Type) error {
+	return Errorf("struct: '%s' %s does not match %s", sh.Name(), sh.Type(), t)
+}
+
+// structField is an exported field of a Go struct along with the field of
+// the sheme it stands for.
+type structField struct {
+	index int
+	sheme *Sheme
+}
+
+// structFields maps the fields of the struct type t to the fields of sh, in
+// the order of the sheme. The `asn:"name"` tag names the field of the sheme,
+// an untagged field matches the field of the same name, else the one field
+// of that name in another case, and `asn:"-"` leaves the field out.
+func structFields(sh *Sheme, t reflect.Type) ([]structField, error) {
+	var out []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("asn"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		var fsh *Sheme
+		if name != "" {
+			fsh = sh.Field(name)
+		} else if fsh = sh.Field(f.Name); fsh == nil {
+			var match []string
+			for k := range sh.FieldAttr() {
+				if strings.EqualFold(k, f.Name) {
+					match = append(match, k)
+				}
+			}
+			if len(match) > 1 {
+				sort.Strings(match)
+				return nil, Errorf("struct: field '%s' of %s matches the fields '%s' of '%s'", f.Name, t, strings.Join(match, "', '"), sh.Name())
+			}
+			if len(match) == 1 {
+				fsh = sh.Field(match[0])
+			}
+		}
+		if fsh == nil {
+			if name == "" {
+				name = f.Name
+			}
+			return nil, Errorf("struct: '%s' does not contain the field '%s' of %s", sh.Name(), name, t)
+		}
+		out = append(out, structField{index: i, sheme: fsh})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].sheme.ID() < out[j].sheme.ID()
+	})
+	return out, nil
+}
+
+// DecodeInto decodes th by sheme into the Go value v points to.
+//
+// A SEQUENCE or SET goes to a struct (see structFields) or a map, a SEQUENCE
+// OF to a slice and a CHOICE to a struct with a field for each alternative,
+// of which only the chosen one is set, or to a map. A pointer is allocated
+// when the value is present, which leaves absent OPTIONAL fields nil. An
+// interface{} takes the value as Decode returns it. ENUMERATED goes to a
+// string or to an integer taking its number. An INTEGER goes to a big.Int
+// whatever its size, and to the other integer types if it fits.
+func (th *AsnData) DecodeInto(sheme *Sheme, v interface{}) error {
+	return th.decodeInto(sheme, v, &AsnContext{})
+}
+
+func (th *AsnData) decodeInto(sheme *Sheme, v interface{}, ctx *AsnContext) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return Errorf("struct: DecodeInto expects a non-nil pointer, got %T", v)
+	}
+	ctx.wide = true
+	val, err := th.decode(sheme, ctx)
+	if err != nil {
+		return err
+	}
+	return assign(sheme, val, rv.Elem(), &AsnContext{})
+}
+
+// assign stores val, the result of decoding by sh, in rv.
+func assign(sh *Sheme, val interface{}, rv reflect.Value, ctx *AsnContext) error {
+	ctx.debugPrint("DecodeInto: '%s' (%s) to %s", sh.Name(), sh.Type(), rv.Type())
+	tp := sh.TypeEn()
+	switch tp {
+	case tagANY:
+		if ctx.od == "" {
+			return decodeDataErr("'%s' miss ObjectDescriptor", sh.Name())
+		}
+		alt := sh.Field(ctx.od)
+		if alt == nil {
+			return decodeDataErr("'%s' unknown ObjectDescriptor %s", sh.Name(), ctx.od)
+		}
+		return assign(alt, val, rv, &AsnContext{parent: ctx})
+	case tagObjDescriptor:
+		ctx.od, _ = val.(string)
+	}
+
+	if val != nil && reflect.TypeOf(val).AssignableTo(rv.Type()) {
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr {
+		nv := reflect.New(rv.Type().Elem())
+		if err := assign(sh, val, nv.Elem(), ctx); err != nil {
+			return err
+		}
+		rv.Set(nv)
+		return nil
+	}
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		// NULL
+		return nil
+	}
+
+	switch tp {
+	case tagNULL:
+		return nil
+	case tagINTEGER:
+		v, _ := valueInt(val)
+		switch {
+		case rv.Type() == bigIntType:
+			rv.Set(reflect.ValueOf(*v))
+			return nil
+		case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+			if !v.IsInt64() || rv.OverflowInt(v.Int64()) {
+				return Errorf("struct: '%s' value %s overflows %s", sh.Name(), v, rv.Type())
+			}
+			rv.SetInt(v.Int64())
+			return nil
+		case rv.Kind() >= reflect.Uint && rv.Kind() <= reflect.Uintptr:
+			if v.Sign() < 0 || !v.IsUint64() || rv.OverflowUint(v.Uint64()) {
+				return Errorf("struct: '%s' value %s overflows %s", sh.Name(), v, rv.Type())
+			}
+			rv.SetUint(v.Uint64())
+			return nil
+		}
+	case tagENUMERATED:
+		// an unknown value of an extensible ENUMERATED comes as its number
+		name, named := val.(string)
+		switch {
+		case rv.Kind() == reflect.String && named:
+			rv.SetString(name)
+			return nil
+		case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64 && named:
+			rv.SetInt(sh.obj.Get("$field").Get(name).MustInt64())
+			return nil
+		case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+			rv.SetInt(val.(int64))
+			return nil
+		}
+	case tagREAL:
+		if rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64 {
+			rv.SetFloat(val.(float64))
+			return nil
+		}
+	case tagOCTET_STR:
+		if rv.Kind() == reflect.String {
+			rv.SetString(string(val.([]byte)))
+			return nil
+		}
+	case tagOID:
+		if rv.Kind() == reflect.String {
+			rv.SetString(val.(OID).String())
+			return nil
+		}
+		if reflect.TypeOf(val).ConvertibleTo(rv.Type()) {
+			rv.Set(reflect.ValueOf(val).Convert(rv.Type()))
+			return nil
+		}
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return assignSequenceOf(sh, val, rv, ctx)
+		}
+		return assignSequence(sh, val, rv, ctx)
+	case tagCHOICE:
+		return assignSequence(sh, val, rv, ctx)
+	default:
+		// the character strings and the types named after them
+		if s, ok := val.(string); ok && rv.Kind() == reflect.String {
+			rv.SetString(s)
+			return nil
+		}
+	}
+	return structErr(sh, rv.Type())
+}
+
+// assignSequence stores the fields of a SEQUENCE or a SET, or the chosen
+// alternative of a CHOICE, in a struct or a map.
+func assignSequence(sh *Sheme, val interface{}, rv reflect.Value, ctx *AsnContext) error {
+	obj := val.(map[string]interface{})
+	ctxn := &AsnContext{parent: ctx}
+	switch rv.Kind() {
+	case reflect.Struct:
+		fld, err := structFields(sh, rv.Type())
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.Zero(rv.Type()))
+		for _, f := range fld {
+			itm, ok := obj[f.sheme.Name()]
+			if !ok {
+				continue
+			}
+			if err = assign(f.sheme, itm, rv.Field(f.index), ctxn); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		fld := sh.FieldList()
+		for f := fld.Begin(); f != nil; f = fld.Next() {
+			itm, ok := obj[f.Name()]
+			if !ok {
+				continue
+			}
+			nv := reflect.New(rv.Type().Elem()).Elem()
+			if err := assign(f, itm, nv, ctxn); err != nil {
+				return err
+			}
+			rv.SetMapIndex(reflect.ValueOf(f.Name()).Convert(rv.Type().Key()), nv)
+		}
+		return nil
+	}
+	return structErr(sh, rv.Type())
+}
+
+func assignSequenceOf(sh *Sheme, val interface{}, rv reflect.Value, ctx *AsnContext) error {
+	arr := val.([]interface{})
+	if rv.Kind() != reflect.Slice {
+		return structErr(sh, rv.Type())
+	}
+	of := sh.Of()
+	out := reflect.MakeSlice(rv.Type(), len(arr), len(arr))
+	for i, itm := range arr {
+		if err := assign(of, itm, out.Index(i), &AsnContext{parent: ctx}); err != nil {
+			return Errorf("%s[%d]: %v", sh.Name(), i, err)
+		}
+	}
+	rv.Set(out)
+	return nil
+}
@@ -0,0 +1,78 @@
+package asn1dynamic
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeIntoBigInt(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"n":{"$id":0,"$type":"INTEGER"},
+		"p":{"$id":1,"$type":"INTEGER"},
+		"i":{"$id":2,"$type":"INTEGER"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	huge := new(big.Int).Lsh(big.NewInt(1), 70)
+	data, err := Encode(cls, map[string]interface{}{"n": huge, "p": huge, "i": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder()
+	if _, _, err := dec.Parse(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// the Go type takes a big integer without '$big'
+	var v struct {
+		N big.Int
+		P *big.Int
+		I int64
+	}
+	if err := dec.DecodeInto(cls, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.N.Cmp(huge) != 0 || v.P == nil || v.P.Cmp(huge) != 0 || v.I != 5 {
+		t.Errorf("decoded %s %s %d", &v.N, v.P, v.I)
+	}
+
+	var small struct {
+		N int64
+		P *big.Int
+		I int64
+	}
+	if err := dec.DecodeInto(cls, &small); err == nil || !strings.Contains(err.Error(), "overflows") {
+		t.Errorf("decoded %d into int64: %v", huge, err)
+	}
+}
+
+func TestStructFieldsMatch(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"value":{"$id":0,"$type":"INTEGER"},
+		"Value":{"$id":1,"$type":"INTEGER"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	type exact struct {
+		Value int
+		Other int `asn:"value"`
+	}
+	fld, err := structFields(cls, reflect.TypeOf(exact{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fld) != 2 || fld[0].sheme.Name() != "value" || fld[1].sheme.Name() != "Value" {
+		t.Errorf("matched %v", fld)
+	}
+
+	type ambiguous struct {
+		VALUE int
+	}
+	if _, err := structFields(cls, reflect.TypeOf(ambiguous{})); err == nil {
+		t.Error("an ambiguous field matched")
+	}
+}
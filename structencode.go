@@ -0,0 +1,195 @@
+package asn1dynamic
+
+import (
+	"math/big"
+	"reflect"
+)
+
+// EncodeStruct encodes the Go value v by sheme in BER, the mirror of
+// DecodeInto. Every exported field of a struct must stand for a field of the
+// sheme; a nil pointer or interface leaves the field out, while a nil slice
+// or map is empty.
+func EncodeStruct(sheme *Sheme, v interface{}) ([]byte, error) {
+	val, err := extractValue(sheme, v)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(sheme, val)
+}
+
+// extractValue returns v shaped as the result of Decode by sheme.
+func extractValue(sheme *Sheme, v interface{}) (interface{}, error) {
+	val, ok, err := extract(sheme, reflect.ValueOf(v), &AsnContext{})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, encodeDataErr("'%s' %s nil value", sheme.Name(), sheme.Type())
+	}
+	return val, nil
+}
+
+// isNil reports whether rv stands for an absent value.
+func isNil(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// extract returns the Go value rv shaped as the result of Decode by sh, and
+// false for an absent value.
+func extract(sh *Sheme, rv reflect.Value, ctx *AsnContext) (interface{}, bool, error) {
+	ctx.debugPrint("EncodeStruct: '%s' (%s)", sh.Name(), sh.Type())
+	for (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if isNil(rv) {
+		return nil, false, nil
+	}
+
+	switch tp := sh.TypeEn(); tp {
+	case tagANY:
+		if ctx.od == "" {
+			return nil, false, encodeDataErr("'%s' miss ObjectDescriptor", sh.Name())
+		}
+		alt := sh.Field(ctx.od)
+		if alt == nil {
+			return nil, false, encodeDataErr("'%s' unknown ObjectDescriptor %s", sh.Name(), ctx.od)
+		}
+		return extract(alt, rv, &AsnContext{parent: ctx})
+	case tagNULL:
+		return nil, true, nil
+	case tagBOOLEAN:
+		if rv.Kind() == reflect.Bool {
+			return rv.Bool(), true, nil
+		}
+	case tagINTEGER, tagENUMERATED:
+		switch {
+		case rv.Type() == bigIntType:
+			v := rv.Interface().(big.Int)
+			return &v, true, nil
+		case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+			return rv.Int(), true, nil
+		case rv.Kind() >= reflect.Uint && rv.Kind() <= reflect.Uintptr:
+			return new(big.Int).SetUint64(rv.Uint()), true, nil
+		case rv.Kind() == reflect.String && tp == tagENUMERATED:
+			return rv.String(), true, nil
+		}
+	case tagREAL:
+		if rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64 {
+			return rv.Float(), true, nil
+		}
+	case tagOCTET_STR:
+		switch {
+		case rv.Kind() == reflect.String:
+			return []byte(rv.String()), true, nil
+		case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+			return rv.Bytes(), true, nil
+		}
+	case tagBIT_STR:
+		if v, ok := rv.Interface().(BitStr); ok {
+			return v, true, nil
+		}
+	case tagOID:
+		switch {
+		case rv.Kind() == reflect.String:
+			return rv.String(), true, nil
+		case rv.Type().ConvertibleTo(reflect.TypeOf(OID{})):
+			return rv.Convert(reflect.TypeOf(OID{})).Interface(), true, nil
+		}
+	case tagUTCTime, tagGeneralizedTime:
+		return rv.Interface(), true, nil
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return extractSequenceOf(sh, rv, ctx)
+		}
+		return extractSequence(sh, rv, ctx)
+	case tagCHOICE:
+		v, ok, err := extractSequence(sh, rv, ctx)
+		if err == nil && len(v.(map[string]interface{})) != 1 {
+			return nil, false, encodeDataErr("'%s' CHOICE takes one alternative of %s", sh.Name(), rv.Type())
+		}
+		return v, ok, err
+	default:
+		if rv.Kind() == reflect.String {
+			v := rv.String()
+			if tp == tagObjDescriptor {
+				ctx.od = v
+			}
+			return v, true, nil
+		}
+	}
+	return nil, false, structErr(sh, rv.Type())
+}
+
+// extractSequence returns the fields of a SEQUENCE or a SET, or the chosen
+// alternative of a CHOICE, held by a struct or a map.
+func extractSequence(sh *Sheme, rv reflect.Value, ctx *AsnContext) (interface{}, bool, error) {
+	ret := make(map[string]interface{})
+	ctxn := &AsnContext{parent: ctx}
+	switch rv.Kind() {
+	case reflect.Struct:
+		fld, err := structFields(sh, rv.Type())
+		if err != nil {
+			return nil, false, err
+		}
+		for _, f := range fld {
+			v, ok, err := extract(f.sheme, rv.Field(f.index), ctxn)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				ret[f.sheme.Name()] = v
+			}
+		}
+		return ret, true, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		for _, k := range rv.MapKeys() {
+			if _, err := findField(sh, k.String()); err != nil {
+				return nil, false, err
+			}
+		}
+		fld := sh.FieldList()
+		for f := fld.Begin(); f != nil; f = fld.Next() {
+			itm := rv.MapIndex(reflect.ValueOf(f.Name()).Convert(rv.Type().Key()))
+			if isNil(itm) {
+				continue
+			}
+			v, ok, err := extract(f, itm, ctxn)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				ret[f.Name()] = v
+			}
+		}
+		return ret, true, nil
+	}
+	return nil, false, structErr(sh, rv.Type())
+}
+
+func extractSequenceOf(sh *Sheme, rv reflect.Value, ctx *AsnContext) (interface{}, bool, error) {
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false, structErr(sh, rv.Type())
+	}
+	of := sh.Of()
+	ret := make([]interface{}, rv.Len())
+	for i := range ret {
+		v, ok, err := extract(of, rv.Index(i), &AsnContext{parent: ctx})
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			return nil, false, encodeDataErr("%s[%d] nil value", sh.Name(), i)
+		}
+		ret[i] = v
+	}
+	return ret, true, nil
+}
@@ -0,0 +1,29 @@
+package asn1dynamic
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestEncodeStructNil(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"l":{"$id":0,"$type":"SEQUENCE","$of":{"$type":"INTEGER"}},
+		"o":{"$id":1,"$type":"OCTET_STRING"},
+		"p":{"$id":2,"$type":"INTEGER","$optional":true}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nil slices are empty, a nil pointer is absent
+	var v struct {
+		L []int
+		O []byte
+		P *int
+	}
+	out, err := EncodeStruct(sh.Class("T"), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(out); got != "300430000400" {
+		t.Errorf("encoded %s", got)
+	}
+}
@@ -1,11 +1,15 @@
 package asn1dynamic
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math"
+	"math/big"
 	"strconv"
 	"time"
+	"unicode/utf16"
 	"unicode/utf8"
 )
 
@@ -130,7 +134,24 @@ func isPrintable(b byte, asterisk bool, ampersand bool) bool {
 		(bool(ampersand) && b == '&')
 }
 
+// isVisible reports whether the given b is in the ASN.1 VisibleString set.
+func isVisible(b byte) bool {
+	return ' ' <= b && b <= '~'
+}
+
+// isGraphic reports whether the given b may appear in a GraphicString: the
+// graphic characters of the G0 and G1 sets and the ESC of their designation
+// sequences. DEL and the C1 controls 0x80-0x9F are not graphic.
+func isGraphic(b byte) bool {
+	return ' ' <= b && b < 0x7f || b >= 0xa0 || b == 0x1b
+}
+
+// strRestrict checks the length of str against the size constraint of
+// sheme. An extensible constraint admits any value.
 func strRestrict(str string, sheme *Sheme) bool {
+	if sheme.Extensible() {
+		return true
+	}
 	if min := sheme.MinAttr(); min > 0 && len(str) < min {
 		return false
 	}
@@ -140,7 +161,39 @@ func strRestrict(str string, sheme *Sheme) bool {
 	return true
 }
 
+func bigRestrict(i *big.Int, sheme *Sheme) bool {
+	if sheme.Extensible() {
+		return true
+	}
+	if min := sheme.MinAttr(); min > 0 && i.Cmp(big.NewInt(int64(min))) < 0 {
+		return false
+	}
+	if max := sheme.MaxAttr(); max > 0 && i.Cmp(big.NewInt(int64(max))) > 0 {
+		return false
+	}
+	return true
+}
+
+// runeRestrict checks the length of str in characters, as for strings
+// encoded with more than one octet per character.
+func runeRestrict(str string, sheme *Sheme) bool {
+	if sheme.Extensible() {
+		return true
+	}
+	n := utf8.RuneCountInString(str)
+	if min := sheme.MinAttr(); min > 0 && n < min {
+		return false
+	}
+	if max := sheme.MaxAttr(); max > 0 && n > max {
+		return false
+	}
+	return true
+}
+
 func intRestrict(i int, sheme *Sheme) bool {
+	if sheme.Extensible() {
+		return true
+	}
 	if min := sheme.MinAttr(); min > 0 && i < min {
 		return false
 	}
@@ -151,7 +204,7 @@ func intRestrict(i int, sheme *Sheme) bool {
 }
 
 func (th *AsnData) castTag(sheme *Sheme, ctx *AsnContext) *AsnData {
-	if th.tag.tagClass != classContextSpecific || th.tag.tagNumber != th.tag.taggedN || !th.tag.tagged {
+	if th.tag.tagClass != th.tag.taggedC || th.tag.tagNumber != th.tag.taggedN || !th.tag.tagged {
 		return th
 	}
 
@@ -163,31 +216,98 @@ func (th *AsnData) castTag(sheme *Sheme, ctx *AsnContext) *AsnData {
 			return th
 		}
 		curr = th.sub[0]
-		debugPrint("castTag: '%s' fall through '%s'", th.tag.typeName(), curr.tag.typeName())
+		ctx.debugPrint("castTag: '%s' fall through '%s'", th.tag.typeName(), curr.tag.typeName())
 	}
 
 	tag := *curr
 	if stn > tagEOC {
 		tag.tag.tagClass = classUniversal
 		tag.tag.tagNumber = stn
-		debugPrint("castTag: '%s' cast to '%s'", th.tag.typeName(), tag.tag.typeName())
+		ctx.debugPrint("castTag: '%s' cast to '%s'", th.tag.typeName(), tag.tag.typeName())
 	}
 	return &tag
 }
 
+// flatten joins the segments of a string sent in the constructed form of BER
+// into a single primitive value. Segments may be constructed themselves;
+// those of a BIT STRING carry unused bits only in the last one. DER has no
+// constructed strings, though their segments are checked first, so that a
+// segment of a wrong type is reported as such.
+func (th *AsnData) flatten(ctx *AsnContext) (*AsnData, error) {
+	if !th.tag.tagConstructed {
+		return th, nil
+	}
+	out := *th
+	out.tag.tagConstructed = false
+	out.sub = nil
+	out.data = nil
+	if th.tag.tagNumber == tagBIT_STR {
+		out.data = []byte{0}
+	}
+	if err := th.appendSegments(&out, ctx.der); err != nil {
+		return nil, ctx.locate(th, err)
+	}
+	if ctx.der {
+		return nil, ctx.locate(th, decodeDataErr("'%s' constructed string in DER", th.tag.typeName()))
+	}
+	out.len = len(out.data)
+	return &out, nil
+}
+
+// appendSegments appends the contents of the segments of th to out. The
+// segments of a BIT STRING are BIT STRING and those of an OCTET STRING or a
+// character string OCTET STRING (X.690 8.6.4, 8.7.3 and 8.23.6). Unless
+// strict, segments of a character string may carry its own universal tag as
+// well, as some encoders send them.
+func (th *AsnData) appendSegments(out *AsnData, strict bool) error {
+	bitStr := out.tag.tagNumber == tagBIT_STR
+	for _, seg := range th.sub {
+		valid := seg.tag.tagClass == classUniversal
+		if bitStr {
+			valid = valid && seg.tag.tagNumber == tagBIT_STR
+		} else {
+			valid = valid && (seg.tag.tagNumber == tagOCTET_STR || !strict && seg.tag.tagNumber == out.tag.tagNumber)
+		}
+		if !valid {
+			return decodeDataErr("'%s' invalid segment '%s'", out.tag.typeName(), seg.tag.typeName())
+		}
+		if seg.tag.tagConstructed {
+			if err := seg.appendSegments(out, strict); err != nil {
+				return err
+			}
+			continue
+		}
+		if !bitStr {
+			out.data = append(out.data, seg.data...)
+			continue
+		}
+		if seg.len == 0 {
+			return decodeDataErr("'%s' zero length segment", out.tag.typeName())
+		}
+		if out.data[0] != 0 {
+			return decodeDataErr("'%s' padding bits before the last segment", out.tag.typeName())
+		}
+		out.data[0] = seg.data[0]
+		out.data = append(out.data, seg.data[1:]...)
+	}
+	return nil
+}
+
 func (th *AsnData) parseNull(sheme *Sheme, ctx *AsnContext) (ret interface{}, err error) {
-	debugPrint("parseNull: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseNull: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagNULL {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
+	} else if ctx.der && th.len != 0 {
+		err = ctx.locate(tho, decodeDataErr("'%s' wrong length %d", th.tag.typeName(), th.len))
 	}
 	return
 }
 
 func (th *AsnData) parseBool(sheme *Sheme, ctx *AsnContext) (ret bool, err error) {
-	debugPrint("parseBool: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseBool: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagBOOLEAN {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
@@ -211,8 +331,8 @@ func (th *AsnData) parseBool(sheme *Sheme, ctx *AsnContext) (ret bool, err error
 }
 
 func (th *AsnData) parseInt64(sheme *Sheme, ctx *AsnContext) (ret int64, err error) {
-	debugPrint("parseInt64: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseInt64: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagINTEGER {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
@@ -220,6 +340,7 @@ func (th *AsnData) parseInt64(sheme *Sheme, ctx *AsnContext) (ret int64, err err
 	}
 
 	if err = checkInteger(th); err != nil {
+		err = ctx.locate(tho, err)
 		return
 	}
 
@@ -244,9 +365,45 @@ func (th *AsnData) parseInt64(sheme *Sheme, ctx *AsnContext) (ret int64, err err
 	return
 }
 
+// parseBigInt decodes an INTEGER of any length from its two's complement
+// form.
+func (th *AsnData) parseBigInt(sheme *Sheme, ctx *AsnContext) (ret *big.Int, err error) {
+	ctx.debugPrint("parseBigInt: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagINTEGER {
+		err = decodeTypeErr(tho.tag.typeName(), sheme)
+		return
+	}
+
+	if err = checkInteger(th); err != nil {
+		err = ctx.locate(tho, err)
+		return
+	}
+
+	ret = new(big.Int)
+	if th.data[0]&0x80 == 0x80 {
+		not := make([]byte, th.len)
+		for i := range not {
+			not[i] = ^th.data[i]
+		}
+		ret.SetBytes(not)
+		ret.Add(ret, big.NewInt(1))
+		ret.Neg(ret)
+	} else {
+		ret.SetBytes(th.data)
+	}
+
+	if !bigRestrict(ret, sheme) {
+		err = decodeDataErr("'%s' out of range value: %s", th.tag.typeName(), ret)
+		ret = nil
+	}
+	return
+}
+
 func (th *AsnData) parseInt32(sheme *Sheme, ctx *AsnContext) (ret int32, err error) {
-	debugPrint("parseInt32: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseInt32: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	ret64, err := th.parseInt64(sheme, ctx)
 	if err != nil {
 		return
@@ -263,9 +420,11 @@ func (th *AsnData) parseInt32(sheme *Sheme, ctx *AsnContext) (ret int32, err err
 	return
 }
 
-func (th *AsnData) parseEnumerated(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
-	debugPrint("parseEnumerated: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+// parseEnumerated returns the name of the value, or its number for a value
+// an extensible ENUMERATED does not know.
+func (th *AsnData) parseEnumerated(sheme *Sheme, ctx *AsnContext) (ret interface{}, err error) {
+	ctx.debugPrint("parseEnumerated: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagENUMERATED {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
@@ -280,99 +439,61 @@ func (th *AsnData) parseEnumerated(sheme *Sheme, ctx *AsnContext) (ret string, e
 	}
 
 	enm := sheme.EnumItems()
-	ret, ok := enm[int(val)]
-	if !ok {
+	if name, ok := enm[int(val)]; ok {
+		ret = name
+	} else if sheme.Extensible() {
+		ret = int64(val)
+	} else {
 		err = decodeDataErr("'%s' wrong value: %d", th.tag.typeName(), val)
 	}
 	return
 }
 
 func (th *AsnData) parseBitString(sheme *Sheme, ctx *AsnContext) (ret BitStr, err error) {
-	debugPrint("parseBitString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseBitString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagBIT_STR {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
-	if th.len == 0 {
-		err = decodeDataErr("'%s' zero length", th.tag.typeName())
-		return
-	}
-
-	paddingBits := int(th.data[0])
-	if paddingBits > 7 ||
-		th.len == 1 && paddingBits > 0 ||
-		th.data[th.len-1]&((1<<th.data[0])-1) != 0 {
-		err = decodeDataErr("'%s' invalid padding bits", th.tag.typeName())
+	if th, err = th.flatten(ctx); err != nil {
 		return
 	}
-	ret.BitLength = (th.len-1)*8 - paddingBits
-	ret.Bytes = th.data[1:]
+	ret, err = ParseBitString(th.data)
 	return
 }
 
 func (th *AsnData) parseObjectDescriptor(sheme *Sheme, ctx *AsnContext) (res string, err error) {
-	debugPrint("parseObjectDescriptor: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseObjectDescriptor: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagObjDescriptor {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
 	res = string(th.data)
 	ctx.od = res
 	return
 }
 
 func (th *AsnData) parseObjectIdentifier(sheme *Sheme, ctx *AsnContext) (res OID, err error) {
-	debugPrint("parseObjectIdentifier: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseObjectIdentifier: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagOID {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
-	if th.len == 0 {
-		err = decodeDataErr("'%s' zero length", th.tag.typeName())
-		return
-	}
-
-	// In the worst case, we get two elements from the first byte (which is
-	// encoded differently) and then every varint is a single byte long.
-	res = make([]int, th.len+1)
-
-	// The first varint is 40*value1 + value2:
-	// According to this packing, value1 can take the values 0, 1 and 2 only.
-	// When value1 = 0 or value1 = 1, then value2 is <= 39. When value1 = 2,
-	// then there are no restrictions on value2.
-	v, offset, err := parseBase128Int(th.data, 0)
-	if err != nil {
-		return
-	}
-	if v < 80 {
-		res[0] = v / 40
-		res[1] = v % 40
-	} else {
-		res[0] = 2
-		res[1] = v - 80
-	}
-
-	i := 2
-	for ; offset < th.len; i++ {
-		v, offset, err = parseBase128Int(th.data, offset)
-		if err != nil {
-			return
-		}
-		res[i] = v
-	}
-	res = res[0:i]
-	return
+	return ParseObjectIdentifier(th.data)
 }
 
 func (th *AsnData) parseUTCTime(sheme *Sheme, ctx *AsnContext) (ret time.Time, err error) {
-	debugPrint("parseUTCTime: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseUTCTime: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagUTCTime {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
@@ -385,18 +506,22 @@ func (th *AsnData) parseUTCTime(sheme *Sheme, ctx *AsnContext) (ret time.Time, e
 	if formatStr == "" {
 		formatStr = "0601021504Z0700"
 	}
+	if ctx.der {
+		formatStr = "060102150405Z"
+	}
 
 	ret, err = time.Parse(formatStr, s)
-	if err != nil {
+	if err != nil && !ctx.der {
 		formatStr = "060102150405Z0700"
 		ret, err = time.Parse(formatStr, s)
 	}
 	if err != nil {
+		err = ctx.locate(tho, err)
 		return
 	}
 
 	if serialized := ret.Format(formatStr); serialized != s {
-		err = fmt.Errorf("asn1: time did not serialize back to the original value and may be invalid: given %q, but serialized as %q", s, serialized)
+		err = ctx.locate(tho, fmt.Errorf("asn1: time did not serialize back to the original value and may be invalid: given %q, but serialized as %q", s, serialized))
 		return
 	}
 
@@ -410,34 +535,45 @@ func (th *AsnData) parseUTCTime(sheme *Sheme, ctx *AsnContext) (ret time.Time, e
 // parseGeneralizedTime parses the GeneralizedTime from the given byte slice
 // and returns the resulting time.
 func (th *AsnData) parseGeneralizedTime(sheme *Sheme, ctx *AsnContext) (ret time.Time, err error) {
-	debugPrint("parseGeneralizedTime: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseGeneralizedTime: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagGeneralizedTime {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
 
-	const formatStr = "20060102150405Z0700"
+	formatStr := "20060102150405Z0700"
 	s := string(th.data)
 	if ret, err = time.Parse(formatStr, s); err != nil {
+		err = ctx.locate(tho, err)
 		return
 	}
 
+	if ret.Nanosecond() != 0 {
+		formatStr = "20060102150405.999999999Z0700"
+	}
+	if ctx.der && (len(s) == 0 || s[len(s)-1] != 'Z') {
+		err = ctx.locate(tho, decodeDataErr("'%s' time not in UTC: %q", th.tag.typeName(), s))
+		return
+	}
 	if serialized := ret.Format(formatStr); serialized != s {
-		err = fmt.Errorf("asn1: time did not serialize back to the original value and may be invalid: given %q, but serialized as %q", s, serialized)
+		err = ctx.locate(tho, fmt.Errorf("asn1: time did not serialize back to the original value and may be invalid: given %q, but serialized as %q", s, serialized))
 	}
 	return
 }
 
 func (th *AsnData) parseNumericString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
-	debugPrint("parseNumericString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseNumericString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagNumericString {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
 
 	for _, b := range th.data {
 		if !isNumeric(b) {
@@ -456,13 +592,16 @@ func (th *AsnData) parseNumericString(sheme *Sheme, ctx *AsnContext) (ret string
 }
 
 func (th *AsnData) parsePrintableString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
-	debugPrint("parsePrintableString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parsePrintableString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagPrintableString {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
 
 	for _, b := range th.data {
 		if !isPrintable(b, true, true) {
@@ -481,13 +620,16 @@ func (th *AsnData) parsePrintableString(sheme *Sheme, ctx *AsnContext) (ret stri
 }
 
 func (th *AsnData) parseIA5String(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
-	debugPrint("parseIA5String: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseIA5String: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagIA5String {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
 	for _, b := range th.data {
 		if b >= utf8.RuneSelf {
 			err = decodeDataErr("'%s' contains invalid character: %x", th.tag.typeName(), b)
@@ -504,14 +646,207 @@ func (th *AsnData) parseIA5String(sheme *Sheme, ctx *AsnContext) (ret string, er
 	return
 }
 
+func (th *AsnData) parseVisibleString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
+	ctx.debugPrint("parseVisibleString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagVisibleString {
+		err = decodeTypeErr(tho.tag.typeName(), sheme)
+		return
+	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
+	for _, b := range th.data {
+		if !isVisible(b) {
+			err = decodeDataErr("'%s' contains invalid character: %x", th.tag.typeName(), b)
+			return
+		}
+	}
+	str := string(th.data)
+	if !strRestrict(str, sheme) {
+		err = decodeDataErr("'%s' contains invalid length: %d", th.tag.typeName(), len(str))
+		return
+	}
+
+	ret = str
+	return
+}
+
+func (th *AsnData) parseGraphicString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
+	ctx.debugPrint("parseGraphicString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagGraphicString {
+		err = decodeTypeErr(tho.tag.typeName(), sheme)
+		return
+	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
+	for _, b := range th.data {
+		if !isGraphic(b) {
+			err = decodeDataErr("'%s' contains invalid character: %x", th.tag.typeName(), b)
+			return
+		}
+	}
+	str := string(th.data)
+	if !strRestrict(str, sheme) {
+		err = decodeDataErr("'%s' contains invalid length: %d", th.tag.typeName(), len(str))
+		return
+	}
+
+	ret = str
+	return
+}
+
+func (th *AsnData) parseGeneralString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
+	ctx.debugPrint("parseGeneralString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagGeneralString {
+		err = decodeTypeErr(tho.tag.typeName(), sheme)
+		return
+	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
+	str := string(th.data)
+	if !strRestrict(str, sheme) {
+		err = decodeDataErr("'%s' contains invalid length: %d", th.tag.typeName(), len(str))
+		return
+	}
+
+	ret = str
+	return
+}
+
+func (th *AsnData) parseTeletexString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
+	ctx.debugPrint("parseTeletexString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagTeletexString {
+		err = decodeTypeErr(tho.tag.typeName(), sheme)
+		return
+	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
+	str := string(th.data)
+	if !strRestrict(str, sheme) {
+		err = decodeDataErr("'%s' contains invalid length: %d", th.tag.typeName(), len(str))
+		return
+	}
+
+	ret = str
+	return
+}
+
+func (th *AsnData) parseVideotexString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
+	ctx.debugPrint("parseVideotexString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagVideotexString {
+		err = decodeTypeErr(tho.tag.typeName(), sheme)
+		return
+	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
+	str := string(th.data)
+	if !strRestrict(str, sheme) {
+		err = decodeDataErr("'%s' contains invalid length: %d", th.tag.typeName(), len(str))
+		return
+	}
+
+	ret = str
+	return
+}
+
+// parseBMPString decodes the UTF-16BE characters of the Basic Multilingual
+// Plane into a UTF-8 string.
+func (th *AsnData) parseBMPString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
+	ctx.debugPrint("parseBMPString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagBMPString {
+		err = decodeTypeErr(tho.tag.typeName(), sheme)
+		return
+	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
+	if th.len%2 != 0 {
+		err = decodeDataErr("'%s' odd length: %d", th.tag.typeName(), th.len)
+		return
+	}
+
+	buf := make([]byte, 0, th.len)
+	for i := 0; i < th.len; i += 2 {
+		r := rune(th.data[i])<<8 | rune(th.data[i+1])
+		if utf16.IsSurrogate(r) {
+			err = decodeDataErr("'%s' contains invalid character: %x", th.tag.typeName(), r)
+			return
+		}
+		buf = append(buf, string(r)...)
+	}
+	str := string(buf)
+	if !runeRestrict(str, sheme) {
+		err = decodeDataErr("'%s' contains invalid length: %d", th.tag.typeName(), th.len/2)
+		return
+	}
+
+	ret = str
+	return
+}
+
+// parseUniversalString decodes UTF-32BE characters into a UTF-8 string.
+func (th *AsnData) parseUniversalString(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
+	ctx.debugPrint("parseUniversalString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagUniversalString {
+		err = decodeTypeErr(tho.tag.typeName(), sheme)
+		return
+	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
+	if th.len%4 != 0 {
+		err = decodeDataErr("'%s' length is not a multiple of 4: %d", th.tag.typeName(), th.len)
+		return
+	}
+
+	buf := make([]byte, 0, th.len)
+	for i := 0; i < th.len; i += 4 {
+		r := rune(binary.BigEndian.Uint32(th.data[i:]))
+		if !utf8.ValidRune(r) {
+			err = decodeDataErr("'%s' contains invalid character: %x", th.tag.typeName(), uint32(r))
+			return
+		}
+		buf = append(buf, string(r)...)
+	}
+	str := string(buf)
+	if !runeRestrict(str, sheme) {
+		err = decodeDataErr("'%s' contains invalid length: %d", th.tag.typeName(), th.len/4)
+		return
+	}
+
+	ret = str
+	return
+}
+
 func (th *AsnData) parseUTF8String(sheme *Sheme, ctx *AsnContext) (ret string, err error) {
-	debugPrint("parseUTF8String: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseUTF8String: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagUTF8String {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
 
 	if !utf8.Valid(th.data) {
 		err = decodeDataErr("'%s' invalid UTF-8 string", th.tag.typeName())
@@ -528,13 +863,16 @@ func (th *AsnData) parseUTF8String(sheme *Sheme, ctx *AsnContext) (ret string, e
 }
 
 func (th *AsnData) parseOctetString(sheme *Sheme, ctx *AsnContext) (ret []byte, err error) {
-	debugPrint("parseOctetString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseOctetString: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagOCTET_STR {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
+	if th, err = th.flatten(ctx); err != nil {
+		return
+	}
 	if !strRestrict(string(th.data), sheme) {
 		err = decodeDataErr("'%s' contains invalid length: %d", th.tag.typeName(), len(th.data))
 		return
@@ -544,8 +882,8 @@ func (th *AsnData) parseOctetString(sheme *Sheme, ctx *AsnContext) (ret []byte,
 }
 
 func (th *AsnData) parseSequence(sheme *Sheme, ctx *AsnContext) (ret map[string]interface{}, err error) {
-	debugPrint("parseSequence: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseSequence: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagSEQUENCE {
 		return nil, decodeTypeErr(tho.tag.typeName(), sheme)
@@ -559,22 +897,52 @@ func (th *AsnData) parseSequence(sheme *Sheme, ctx *AsnContext) (ret map[string]
 		return nil, decodeShemeErr("'%s' cannot find any field in sheme", th.tag.typeName())
 	}
 
+	// an element is an extension addition unknown to the sheme when no
+	// field from the current one on and no known addition matches it
+	var add []*Sheme
+	for sh := fld.Begin(); sh != nil; sh = fld.Next() {
+		if sh.Extension() {
+			add = append(add, sh)
+		}
+	}
 	idx := 0
+	var skipped error
+	var unknown []interface{}
 	ret = make(map[string]interface{})
-	ctxn := &AsnContext{parent: ctx, tag: th}
+	ctxn := &AsnContext{parent: ctx, tag: th, sheme: sheme, der: ctx.der, opt: ctx.opt, wide: ctx.wide}
 	for sh := fld.Begin(); sh != nil; sh = fld.Next() {
+		for sheme.Extensible() && idx < len(th.sub) && th.sub[idx] != nil && !matchAny(th.sub[idx], fld.rest()) && !matchAny(th.sub[idx], add) {
+			unknown = append(unknown, th.sub[idx].fdata)
+			idx++
+		}
 		var dt interface{}
 		if idx < len(th.sub) && th.sub[idx] != nil {
-			dt, err = th.sub[idx].decode(sh, ctxn)
+			if absent(th.sub[idx], sh, fld) {
+				err = fmt.Errorf("miss field '%s' (%s)", sh.Name(), sh.Type())
+			} else {
+				dt, err = th.sub[idx].decode(sh, ctxn)
+			}
 		} else {
 			err = fmt.Errorf("miss field '%s' (%s)", sh.Name(), sh.Type())
 			idx++
 		}
 
 		if err == nil {
+			if ctx.der && equalDefault(dt, sh) {
+				return nil, ctxn.locate(th.sub[idx], decodeDataErr("'%s' DEFAULT value of '%s' encoded in DER", sheme.Name(), sh.Name()))
+			}
 			ret[sh.Name()] = dt
+			skipped = nil
 			idx++
-		} else if sh.Optional() {
+		} else if sh.Extension() && idx < len(th.sub) && th.sub[idx] != nil && matchTag(th.sub[idx], sh) {
+			// an addition known to the sheme is not taken for an unknown one
+			return nil, err
+		} else if sh.Optional() || sh.DefAttr() != nil || sh.Group() > 0 {
+			// a field of a group is missed only along with the whole group,
+			// which is checked below
+			if idx < len(th.sub) && th.sub[idx] != nil && matchTag(th.sub[idx], sh) {
+				skipped = err
+			}
 			if def := sh.DefAttr(); def != nil {
 				ret[sh.Name()] = def
 			}
@@ -583,12 +951,38 @@ func (th *AsnData) parseSequence(sheme *Sheme, ctx *AsnContext) (ret map[string]
 			return nil, err
 		}
 	}
+	present := func(f *Sheme) bool {
+		_, ok := ret[f.Name()]
+		return ok
+	}
+	for sh := fld.Begin(); sh != nil; sh = fld.Next() {
+		if !present(sh) && !sheme.omissible(sh, present) {
+			return nil, decodeDataErr("'%s' miss field '%s' (%s)", sheme.Name(), sh.Name(), sh.Type())
+		}
+	}
+	for ; sheme.Extensible() && idx < len(th.sub); idx++ {
+		if el := th.sub[idx]; el != nil {
+			if matchAny(el, add) {
+				return nil, decodeDataErr("'%s' unexpected field '%s'", sheme.Name(), el.tag.typeName())
+			}
+			unknown = append(unknown, el.fdata)
+		}
+	}
+	if len(unknown) > 0 {
+		ret[unknownKey] = unknown
+	}
+	if ctx.der && idx < len(th.sub) {
+		if skipped != nil {
+			return nil, skipped
+		}
+		return nil, ctxn.locate(th.sub[idx], decodeDataErr("'%s' unexpected field '%s'", sheme.Name(), th.sub[idx].tag.typeName()))
+	}
 	return ret, nil
 }
 
 func (th *AsnData) parseSequenceOf(sheme *Sheme, ctx *AsnContext) (ret []interface{}, err error) {
-	debugPrint("parseSequenceOf: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseSequenceOf: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagSEQUENCE {
 		return nil, decodeTypeErr(tho.tag.typeName(), sheme)
@@ -601,7 +995,7 @@ func (th *AsnData) parseSequenceOf(sheme *Sheme, ctx *AsnContext) (ret []interfa
 
 	ret = make([]interface{}, len(th.sub))
 
-	ctxn := &AsnContext{parent: ctx, tag: th}
+	ctxn := &AsnContext{parent: ctx, tag: th, sheme: sheme, der: ctx.der, opt: ctx.opt, wide: ctx.wide}
 	for k, v := range th.sub {
 		ret[k], err = v.decode(sh, ctxn)
 		if err != nil {
@@ -611,23 +1005,190 @@ func (th *AsnData) parseSequenceOf(sheme *Sheme, ctx *AsnContext) (ret []interfa
 	return ret, nil
 }
 
-func (th *AsnData) parseChoice(sheme *Sheme, ctx *AsnContext) (ret interface{}, err error) {
-	debugPrint("parseChoice: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+// tagLess orders tags as the components of a SET in DER.
+func tagLess(a, b AsnTag) bool {
+	if a.tagClass != b.tagClass {
+		return a.tagClass < b.tagClass
+	}
+	return a.tagNumber < b.tagNumber
+}
+
+// matchAny reports whether el matches the tag of any of the fields.
+func matchAny(el *AsnData, fields []*Sheme) bool {
+	for _, sh := range fields {
+		if matchTag(el, sh) {
+			return true
+		}
+	}
+	return false
+}
+
+// absent reports whether the current field sh of fld, an optional
+// extensible CHOICE, is left out: an unknown alternative of sh could take
+// any tag, so el goes to a later field it matches.
+func absent(el *AsnData, sh *Sheme, fld *fieldList) bool {
+	if sh.TypeEn() != tagCHOICE || !sh.Extensible() || !sh.Optional() && sh.DefAttr() == nil || matchTag(el, sh) {
+		return false
+	}
+	rest := fld.rest()
+	return len(rest) > 1 && matchAny(el, rest[1:])
+}
+
+// matchTag reports whether the parsed element el carries the outer tag of
+// sheme. An untagged CHOICE matches the tag of any of its alternatives.
+func matchTag(el *AsnData, sheme *Sheme) bool {
+	if sheme.Tagged() {
+		return el.tag.tagClass == sheme.TagClass() && el.tag.tagNumber == sheme.Index()
+	}
+	switch stn := sheme.TypeEn(); stn {
+	case tagCHOICE:
+		fld := sheme.FieldList()
+		for sh := fld.Begin(); sh != nil; sh = fld.Next() {
+			if matchTag(el, sh) {
+				return true
+			}
+		}
+		return false
+	case tagANY:
+		return true
+	default:
+		return el.tag.tagClass == classUniversal && el.tag.tagNumber == stn
+	}
+}
+
+func (th *AsnData) parseSet(sheme *Sheme, ctx *AsnContext) (ret map[string]interface{}, err error) {
+	ctx.debugPrint("parseSet: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagSET {
+		return nil, decodeTypeErr(tho.tag.typeName(), sheme)
+	}
+	if !th.tag.tagConstructed {
+		return nil, decodeDataErr("'%s' not constructed", tho.tag.typeName())
+	}
+
 	fld := sheme.FieldList()
 	if fld.Len() == 0 {
 		return nil, decodeShemeErr("'%s' cannot find any field in sheme", th.tag.typeName())
 	}
 
-	ctxn := &AsnContext{parent: ctx, tag: th}
+	var unknown []interface{}
+	ret = make(map[string]interface{})
+	ctxn := &AsnContext{parent: ctx, tag: th, sheme: sheme, der: ctx.der, opt: ctx.opt, wide: ctx.wide}
+	for i, el := range th.sub {
+		if ctx.der && i > 0 && !tagLess(th.sub[i-1].tag, el.tag) {
+			return nil, ctxn.locate(el, decodeDataErr("'%s' field '%s' out of DER order", sheme.Name(), el.tag.typeName()))
+		}
+		// fields are matched by tag, an ANY field takes what is left over
+		var fnd, any *Sheme
+		for sh := fld.Begin(); sh != nil && fnd == nil; sh = fld.Next() {
+			if _, f := ret[sh.Name()]; f || !matchTag(el, sh) {
+				continue
+			}
+			if !sh.Tagged() && sh.TypeEn() == tagANY {
+				if any == nil {
+					any = sh
+				}
+				continue
+			}
+			fnd = sh
+		}
+		if fnd == nil {
+			fnd = any
+		}
+		if fnd == nil {
+			for sh := fld.Begin(); sh != nil; sh = fld.Next() {
+				if _, f := ret[sh.Name()]; f && matchTag(el, sh) {
+					return nil, decodeDataErr("'%s' duplicate field '%s' (%s)", sheme.Name(), sh.Name(), el.tag.typeName())
+				}
+			}
+			if sheme.Extensible() {
+				unknown = append(unknown, el.fdata)
+				continue
+			}
+			return nil, decodeDataErr("'%s' unexpected field '%s'", sheme.Name(), el.tag.typeName())
+		}
+		if ret[fnd.Name()], err = el.decode(fnd, ctxn); err != nil {
+			return nil, err
+		}
+		if ctx.der && equalDefault(ret[fnd.Name()], fnd) {
+			return nil, ctxn.locate(el, decodeDataErr("'%s' DEFAULT value of '%s' encoded in DER", sheme.Name(), fnd.Name()))
+		}
+	}
+
+	present := func(f *Sheme) bool {
+		_, ok := ret[f.Name()]
+		return ok
+	}
+	for sh := fld.Begin(); sh != nil; sh = fld.Next() {
+		if present(sh) {
+			continue
+		}
+		if !sheme.omissible(sh, present) {
+			return nil, decodeDataErr("'%s' miss field '%s' (%s)", sheme.Name(), sh.Name(), sh.Type())
+		}
+		if def := sh.DefAttr(); def != nil {
+			ret[sh.Name()] = def
+		}
+	}
+	if len(unknown) > 0 {
+		ret[unknownKey] = unknown
+	}
+	return ret, nil
+}
+
+func (th *AsnData) parseSetOf(sheme *Sheme, ctx *AsnContext) (ret []interface{}, err error) {
+	ctx.debugPrint("parseSetOf: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
+	if th.tag.tagNumber != tagSET {
+		return nil, decodeTypeErr(tho.tag.typeName(), sheme)
+	}
+	if !th.tag.tagConstructed {
+		return nil, decodeDataErr("'%s' not constructed", tho.tag.typeName())
+	}
+
+	sh := sheme.Of()
+
+	ret = make([]interface{}, len(th.sub))
+
+	ctxn := &AsnContext{parent: ctx, tag: th, sheme: sheme, der: ctx.der, opt: ctx.opt, wide: ctx.wide}
+	for k, v := range th.sub {
+		if ctx.der && k > 0 && bytes.Compare(th.sub[k-1].fdata, v.fdata) > 0 {
+			return nil, ctxn.locate(v, decodeDataErr("'%s' item %d out of DER order", sheme.Name(), k))
+		}
+		ret[k], err = v.decode(sh, ctxn)
+		if err != nil {
+			return
+		}
+	}
+	return ret, nil
+}
 
-	if th.tag.tagClass != classContextSpecific {
-		return nil, decodeDataErr("'%s' not ContextSpecific", tho.tag.typeName())
+func (th *AsnData) parseChoice(sheme *Sheme, ctx *AsnContext) (ret interface{}, err error) {
+	ctx.debugPrint("parseChoice: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
+	fld := sheme.FieldList()
+	if fld.Len() == 0 {
+		return nil, decodeShemeErr("'%s' cannot find any field in sheme", th.tag.typeName())
 	}
 
-	sh := fld.FindIndex(th.tag.tagNumber)
+	ctxn := &AsnContext{parent: ctx, tag: th, sheme: sheme, der: ctx.der, opt: ctx.opt, wide: ctx.wide}
+	tho, th := th, th.castTag(sheme, ctx)
+
+	sh := fld.FindTag(th.tag.tagClass, th.tag.tagNumber)
+	for el := fld.Begin(); sh == nil && el != nil; el = fld.Next() {
+		// an untagged CHOICE or ANY alternative carries no tag of its own
+		if !el.Tagged() && el.TypeEn() <= tagEOC && matchTag(th, el) {
+			sh = el
+		}
+	}
 	if sh == nil {
+		// within its own tag, if any, an extensible CHOICE takes an
+		// alternative unknown to the sheme
+		if sheme.Extensible() && (!sheme.Tagged() || th != tho) {
+			return map[string]interface{}{unknownKey: th.fdata}, nil
+		}
 		return nil, decodeDataErr("'%s' not FindIndex", tho.tag.typeName())
 	}
 
@@ -642,8 +1203,8 @@ func (th *AsnData) parseChoice(sheme *Sheme, ctx *AsnContext) (ret interface{},
 }
 
 func (th *AsnData) parseAny(sheme *Sheme, ctx *AsnContext) (ret interface{}, err error) {
-	debugPrint("parseAny: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseAny: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	if ctx.od == "" {
 		return nil, decodeDataErr("'%s' miss ObjectDescriptor", th.tag.typeName())
 	}
@@ -652,30 +1213,45 @@ func (th *AsnData) parseAny(sheme *Sheme, ctx *AsnContext) (ret interface{}, err
 	if sh == nil {
 		return nil, decodeDataErr("'%s' unknown ObjectDescriptor %s", th.tag.typeName(), ctx.od)
 	}
-	ctxn := &AsnContext{parent: ctx, tag: th}
+	ctxn := &AsnContext{parent: ctx, tag: th, sheme: sheme, der: ctx.der, opt: ctx.opt, wide: ctx.wide}
 	return th.decode(sh, ctxn)
 }
 
 func (th *AsnData) parseReal(sheme *Sheme, ctx *AsnContext) (ret float64, err error) {
-	debugPrint("parseReal: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
-	debugHex(th.fdata)
+	ctx.debugPrint("parseReal: '%s' (%s) tag '%s'", sheme.Name(), sheme.Type(), th.tag.typeName())
+	ctx.debugHex(th.fdata)
 	tho, th := th, th.castTag(sheme, ctx)
 	if th.tag.tagNumber != tagREAL {
 		err = decodeTypeErr(tho.tag.typeName(), sheme)
 		return
 	}
 
-	if th.len == 0 {
-		ret = 0.0
-	} else if th.data[0]&0x80 != 0 {
-		ret, err = decode_real_binary(th.data)
-	} else if th.data[0]&0x40 != 0 {
-		ret, err = decode_real_special(th.data[0])
-	} else {
-		ret, err = decode_real_decimal(th.data)
+	if ctx.der && th.len > 0 && th.data[0]&0x80 != 0 {
+		if err = checkRealDER(th.data); err != nil {
+			err = ctx.locate(tho, err)
+			return
+		}
 	}
+	return ParseReal(th.data)
+}
 
-	return
+// checkRealDER checks the binary form of X.690 11.3.1: base 2, an odd
+// mantissa and an exponent in as few octets as possible.
+func checkRealDER(data []byte) error {
+	control := data[0]
+	if control&0x3c != 0 {
+		return decodeDataErr("REAL base or scaling factor not canonical %x", control)
+	}
+	if control&0x03 == 1 && len(data) > 2 {
+		exp := int16(uint16(data[1])<<8 | uint16(data[2]))
+		if exp == int16(int8(exp)) {
+			return decodeDataErr("REAL exponent not minimally-encoded")
+		}
+	}
+	if data[len(data)-1]&1 == 0 {
+		return decodeDataErr("REAL mantissa is even")
+	}
+	return nil
 }
 
 func decode_real_decimal(data []byte) (float64, error) {
@@ -691,7 +1267,7 @@ func decode_real_special(control byte) (float64, error) {
 	case 0x42:
 		return strconv.ParseFloat("NaN", 32)
 	case 0x43:
-		return -0.0, nil
+		return math.Copysign(0, -1), nil
 	}
 	return 0.0, decodeDataErr("Unsupported special REAL control word %x.", control)
 }
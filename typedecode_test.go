@@ -0,0 +1,247 @@
+package asn1dynamic
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestIsGraphic(t *testing.T) {
+	for _, tc := range []struct {
+		b    byte
+		want bool
+	}{
+		{0x1b, true},
+		{' ', true},
+		{'~', true},
+		{0xa0, true},
+		{0xff, true},
+		{0x00, false},
+		{0x1f, false},
+		{0x7f, false},
+		{0x80, false},
+		{0x9f, false},
+	} {
+		if got := isGraphic(tc.b); got != tc.want {
+			t.Errorf("isGraphic(%#x) = %t, want %t", tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestParseSequenceUnknown(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(`U DEFINITIONS IMPLICIT TAGS ::= BEGIN
+T ::= SEQUENCE { a INTEGER, ..., b [1] BOOLEAN, c [2] BOOLEAN }
+END`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	for _, tc := range []struct {
+		in      string
+		unknown bool
+	}{
+		// an addition the sheme does not know
+		{"3006" + "020101" + "850100", true},
+		// a known addition that does not decode
+		{"3007" + "020101" + "81020101", false},
+		// a known addition out of its order
+		{"3009" + "020101" + "8201ff" + "8101ff", false},
+	} {
+		data, _ := hex.DecodeString(tc.in)
+		js, err := NewCodec(Options{}).Decode(cls, data)
+		if tc.unknown {
+			if err != nil || len(js.Get(unknownKey).MustArray()) != 1 {
+				t.Errorf("%s: %v", tc.in, err)
+			}
+		} else if err == nil {
+			t.Errorf("%s: decoded to %v", tc.in, js.Interface())
+		}
+	}
+}
+
+func TestStrictSegments(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"UTF8String"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	for _, tc := range []struct {
+		in     string
+		strict string // the error in strict mode
+	}{
+		// the segments of a character string are OCTET STRING
+		{"2c0a" + "0403616263" + "0403646566", "constructed string in DER"},
+		// and those of its own type are taken in BER only
+		{"2c0a" + "0c03616263" + "0c03646566", "invalid segment 'UTF8String'"},
+		{"2c0c" + "2405" + "0403616263" + "0c03646566", "invalid segment 'UTF8String'"},
+	} {
+		data, _ := hex.DecodeString(tc.in)
+		if js, err := NewCodec(Options{}).Decode(cls, data); err != nil || js.MustString() != "abcdef" {
+			t.Errorf("%s: decoded %v %v", tc.in, js, err)
+		}
+		if _, err := NewCodec(Options{Strict: true}).Decode(cls, data); err == nil || !strings.Contains(err.Error(), tc.strict) {
+			t.Errorf("%s: strict: %v, want %s", tc.in, err, tc.strict)
+		}
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(`S DEFINITIONS IMPLICIT TAGS ::= BEGIN
+T ::= SET { a [0] INTEGER, b [1] BOOLEAN, c [2] INTEGER OPTIONAL }
+L ::= SET OF INTEGER
+END`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	for _, tc := range []struct {
+		in   string
+		want string // the decoded value, empty for an error
+	}{
+		{"3106" + "800101" + "8101ff", `{"a":1,"b":true}`},
+		// the members come in any order
+		{"3109" + "820103" + "8101ff" + "800101", `{"a":1,"b":true,"c":3}`},
+		// a member twice
+		{"3109" + "800101" + "8101ff" + "800102", ""},
+		// a mandatory member missing
+		{"3103" + "800101", ""},
+		{"3106" + "800101" + "820103", ""},
+		// a member the set does not have
+		{"3109" + "800101" + "8101ff" + "830100", ""},
+	} {
+		data, _ := hex.DecodeString(tc.in)
+		js, err := NewCodec(Options{}).Decode(cls, data)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%s: decoded to %v", tc.in, js.Interface())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+		if got, _ := js.MarshalJSON(); string(got) != tc.want {
+			t.Errorf("%s: decoded to %s, want %s", tc.in, got, tc.want)
+		}
+	}
+
+	// DER sorts the members by tag and the items of SET OF by their
+	// encodings, as octet strings
+	for _, tc := range []struct {
+		cls  string
+		val  interface{}
+		want string
+	}{
+		{"T", map[string]interface{}{"c": 3, "b": true, "a": 1}, "3109" + "800101" + "8101ff" + "820103"},
+		{"L", []interface{}{256, 1, -1, 2}, "310d" + "020101" + "020102" + "0201ff" + "02020100"},
+	} {
+		out, err := NewCodec(Options{Strict: true}).Encode(sh.Class(tc.cls), tc.val)
+		if err != nil || hex.EncodeToString(out) != tc.want {
+			t.Errorf("%s: encoded %x %v, want %s", tc.cls, out, err, tc.want)
+		}
+	}
+}
+
+func TestBigInteger(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"INTEGER","$big":true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	for _, tc := range []struct {
+		val  string
+		want string
+	}{
+		{"0", "020100"},
+		{"127", "02017f"},
+		{"128", "02020080"},
+		{"-128", "020180"},
+		{"-129", "0202ff7f"},
+		{"18446744073709551615", "020900ffffffffffffffff"},
+		{"-18446744073709551616", "0209ff0000000000000000"},
+		{"1208925819614629174706176", "020b0100000000000000000000"},
+	} {
+		n, _ := new(big.Int).SetString(tc.val, 10)
+		// the encoding is the shortest two's complement form, whether of
+		// a *big.Int or a decimal string
+		for _, v := range []interface{}{n, tc.val} {
+			out, err := NewCodec(Options{Strict: true}).Encode(cls, v)
+			if err != nil || hex.EncodeToString(out) != tc.want {
+				t.Errorf("%s: encoded %x %v, want %s", tc.val, out, err, tc.want)
+			}
+		}
+		data, _ := hex.DecodeString(tc.want)
+		js, err := NewCodec(Options{Strict: true}).Decode(cls, data)
+		if err != nil {
+			t.Errorf("%s: %v", tc.want, err)
+		} else if got, ok := js.Interface().(*big.Int); !ok || got.Cmp(n) != 0 {
+			t.Errorf("%s: decoded to %v", tc.want, js.Interface())
+		}
+	}
+
+	// the longer forms are rejected in BER as well
+	for _, in := range []string{"0200", "02020000", "0202007f", "0202ff80", "020a0000ffffffffffffffff"} {
+		data, _ := hex.DecodeString(in)
+		if js, err := NewCodec(Options{}).Decode(cls, data); err == nil {
+			t.Errorf("%s: decoded to %v", in, js.Interface())
+		}
+	}
+}
+
+func TestParseConstructedStrings(t *testing.T) {
+	sh, err := NewSheme([]byte(`{
+		"O":{"$type":"OCTET_STRING"},
+		"I":{"$type":"OCTET_STRING","$tag":0,"$implicit":true},
+		"B":{"$type":"BIT_STRING"},
+		"S":{"$type":"IA5String"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		cls  string
+		in   string
+		want string // the contents, empty for an error
+	}{
+		{"O", "2408" + "0402aabb" + "0402ccdd", "aabbccdd"},
+		// nested segments and the indefinite length
+		{"O", "2480" + "0401aa" + "2480" + "0401bb" + "0000" + "0401cc" + "0000", "aabbcc"},
+		{"O", "2406" + "0400" + "0402aabb", "aabb"},
+		{"I", "a008" + "0402aabb" + "0402ccdd", "aabbccdd"},
+		{"S", "3608" + "0403616263" + "040164", "61626364"},
+		{"O", "2405" + "0c03616263", ""},
+		{"O", "2405" + "8003616263", ""},
+		// the padding of a BIT STRING is in the last segment only
+		{"B", "230a" + "030200aa" + "030401bbcc80", "01aabbcc80"},
+		{"B", "2309" + "030201aa" + "030300bbcc", ""},
+		// and no segment is empty, not even of its padding
+		{"B", "2308" + "0300" + "030401bbcc80", ""},
+		{"B", "2307" + "0401aa" + "030200bb", ""},
+	} {
+		data, _ := hex.DecodeString(tc.in)
+		js, err := NewCodec(Options{}).Decode(sh.Class(tc.cls), data)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%s: decoded to %v", tc.in, js.Interface())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+		var got []byte
+		switch v := js.Interface().(type) {
+		case []byte:
+			got = v
+		case string:
+			got = []byte(v)
+		case BitStr:
+			got = append([]byte{byte(len(v.Bytes)*8 - v.BitLength)}, v.Bytes...)
+		}
+		if hex.EncodeToString(got) != tc.want {
+			t.Errorf("%s: decoded to %x, want %s", tc.in, got, tc.want)
+		}
+	}
+}
@@ -4,13 +4,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math"
+	"math/big"
 	"math/bits"
-	"reflect"
 	"time"
+	"unicode/utf16"
 	"unicode/utf8"
 	"unsafe"
-
-	"github.com/anton-zolotarev/go-simplejson"
 )
 
 func unsafe_slice2str(bs []byte) string {
@@ -63,6 +62,27 @@ func encodeInt(val int) []byte {
 	return appendInt(make([]byte, 0, lengthInt(val)), val)
 }
 
+func encodeBigInt(val *big.Int) []byte {
+	if val.Sign() < 0 {
+		// two's complement of -n is the inverse of n-1
+		n := new(big.Int).Neg(val)
+		n.Sub(n, big.NewInt(1))
+		out := n.Bytes()
+		for i := range out {
+			out[i] ^= 0xff
+		}
+		if len(out) == 0 || out[0]&0x80 == 0 {
+			out = append([]byte{0xff}, out...)
+		}
+		return out
+	}
+	out := val.Bytes()
+	if len(out) == 0 || out[0]&0x80 != 0 {
+		out = append([]byte{0x00}, out...)
+	}
+	return out
+}
+
 func checkType(tag int, sheme *Sheme) error {
 	if sheme == nil {
 		return encodeShemeErr("'%s' no sheme description", typeName(tag))
@@ -77,7 +97,7 @@ func makeTag(class int, tag int, child int) *AsnData {
 	out := AsnData{}
 	out.tag.tagClass = class
 	out.tag.tagNumber = tag
-	if tag == tagSEQUENCE || child > 0 {
+	if tag == tagSEQUENCE || tag == tagSET || child > 0 {
 		out.tag.tagConstructed = true
 		if child > 0 {
 			out.sub = make([]*AsnData, child)
@@ -86,35 +106,64 @@ func makeTag(class int, tag int, child int) *AsnData {
 	return &out
 }
 
-func markTag(th *AsnData, sheme *Sheme) {
+func markTag(th *AsnData, sheme *Sheme, tagging Tagging) {
 	th.sheme = sheme
-	th.tag.tagged = sheme.Tagged()
-	th.tag.taggedN = sheme.Index()
+	th.tag.mark(sheme, tagging)
+}
 
-	th.tag.implicit = sheme.Implicit()
-	th.tag.explicit = sheme.Explicit()
+// mark sets the tag of sheme, with the tags it wraps, as the tag of tag.
+func (tag *AsnTag) mark(sheme *Sheme, tagging Tagging) {
+	tag.tagged = sheme.Tagged()
+	tag.taggedN = sheme.Index()
+	tag.taggedC = sheme.TagClass()
+	tag.mode(sheme, tagging)
+}
 
-	if sheme.TypeEn() == tagCHOICE && th.tag.tagged {
-		th.tag.explicit = true
+// assign marks tag with the tag in, leaving the class and number it holds.
+func (tag *AsnTag) assign(in *AsnTag) {
+	tag.tagged = in.tagged
+	tag.taggedN = in.taggedN
+	tag.taggedC = in.taggedC
+	tag.implicit = in.implicit
+	tag.explicit = in.explicit
+	tag.inner = in.inner
+}
+
+// mode sets whether the tag of sheme is implicit or explicit.
+func (tag *AsnTag) mode(sheme *Sheme, tagging Tagging) {
+	tag.implicit = sheme.Implicit()
+	tag.explicit = sheme.Explicit()
+
+	if tp := sheme.TypeEn(); (tp == tagCHOICE || tp == tagANY) && sheme.Tagged() {
+		// the tag of a CHOICE or an open type cannot be implicit
+		tag.implicit = false
+		tag.explicit = true
 	}
-	if implicit && !th.tag.explicit {
-		th.tag.implicit = true
+	if tagging == ImplicitTags && !tag.explicit {
+		tag.implicit = true
 	}
-	if explicit && !th.tag.implicit {
-		th.tag.explicit = true
+	if !tag.explicit && !tag.implicit {
+		tag.explicit = true
 	}
-	if !th.tag.explicit && !th.tag.implicit {
-		th.tag.explicit = true
+
+	// an implicit tag replaces the tag it would wrap
+	tag.inner = nil
+	if in := sheme.Inner(); in != nil && tag.explicit {
+		tag.inner = &AsnTag{}
+		tag.inner.mark(in, tagging)
 	}
 }
 
+// makeType makes the element of a value of sheme. The tags the sheme leaves
+// open take the Tagging of the default options here; build applies the
+// Tagging of its own options to them.
 func makeType(sheme *Sheme, tag int, child int) (*AsnData, error) {
 	if err := checkType(tag, sheme); err != nil {
 		return nil, err
 	}
 
 	out := makeTag(classUniversal, tag, child)
-	markTag(out, sheme)
+	markTag(out, sheme, defaultOptions.Tagging)
 
 	return out, nil
 }
@@ -139,11 +188,12 @@ func findOf(sheme *Sheme) (*Sheme, error) {
 	return sh, nil
 }
 
+// Null encodes a NULL, which has no contents octets.
 func (sheme *Sheme) Null() (AsnElm, error) {
 	var out *AsnData
 	var err error
 	if out, err = makeType(sheme, tagNULL, 0); err == nil {
-		out.data = []byte{0x00}
+		out.data = []byte{}
 	}
 	return out, err
 }
@@ -172,15 +222,43 @@ func (sheme *Sheme) Integer(val int) (AsnElm, error) {
 	return out, err
 }
 
+// BigInteger encodes an INTEGER of any size in the minimal two's complement
+// form.
+func (sheme *Sheme) BigInteger(val *big.Int) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagINTEGER, 0); err == nil {
+		if val == nil {
+			return nil, encodeDataErr("'%s' %s nil value", sheme.Name(), sheme.Type())
+		}
+		if !bigRestrict(val, sheme) {
+			return nil, encodeDataErr("'%s' %s out of range value: %s", sheme.Name(), sheme.Type(), val)
+		}
+		out.data = encodeBigInt(val)
+	}
+	return out, err
+}
+
 func (sheme *Sheme) Enumerated(val string) (AsnElm, error) {
 	var out *AsnData
 	var err error
 	if out, err = makeType(sheme, tagENUMERATED, 0); err == nil {
-		itm, ok := sheme.FieldAttr()[val]
+		_, id, ok := sheme.enumItem(val)
 		if !ok {
 			return nil, encodeDataErr("'%s' %s wrong value: '%s'", sheme.Name(), sheme.Type(), val)
 		}
-		out.data = encodeInt(simplejson.Wrap(itm).MustInt())
+		out.data = encodeInt(id)
+	}
+	return out, err
+}
+
+// enumerated encodes an ENUMERATED value by its number, which an extensible
+// sheme need not know.
+func (sheme *Sheme) enumerated(val int) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagENUMERATED, 0); err == nil {
+		out.data = encodeInt(val)
 	}
 	return out, err
 }
@@ -189,9 +267,9 @@ func (sheme *Sheme) BitString(val BitStr) (AsnElm, error) {
 	var out *AsnData
 	var err error
 	if out, err = makeType(sheme, tagBIT_STR, 0); err == nil {
-		out.data = make([]byte, len(val.Bytes)+1)
-		out.data[0] = byte((8 - val.BitLength%8) % 8)
-		copy(out.data[1:], val.Bytes)
+		if out.data, err = AppendBitString(nil, val); err != nil {
+			return nil, encodeDataErr("'%s' %s invalid bit length: %d", sheme.Name(), sheme.Type(), val.BitLength)
+		}
 	}
 	return out, err
 }
@@ -207,6 +285,7 @@ func (sheme *Sheme) UTCTime(val time.Time) (AsnElm, error) {
 		tm := val.Format(formatStr)
 		out.data = make([]byte, len(tm))
 		copy(out.data, tm)
+		out.tm = &val
 	}
 	return out, err
 }
@@ -219,6 +298,7 @@ func (sheme *Sheme) GeneralizedTime(val time.Time) (AsnElm, error) {
 		tm := val.Format(formatStr)
 		out.data = make([]byte, len(tm))
 		copy(out.data, tm)
+		out.tm = &val
 	}
 	return out, err
 }
@@ -227,9 +307,8 @@ func (sheme *Sheme) ObjectIdentifier(val OID) (AsnElm, error) {
 	var out *AsnData
 	var err error
 	if out, err = makeType(sheme, tagOID, 0); err == nil {
-		out.data = appendBase128Int(out.data[:0], int64(val[0]*40+val[1]))
-		for i := 2; i < len(val); i++ {
-			out.data = appendBase128Int(out.data, int64(val[i]))
+		if out.data, err = AppendObjectIdentifier(nil, val); err != nil {
+			return nil, encodeDataErr("'%s' %s invalid value: %s", sheme.Name(), sheme.Type(), val)
 		}
 	}
 	return out, err
@@ -299,6 +378,120 @@ func (sheme *Sheme) IA5String(val string) (AsnElm, error) {
 	return out, err
 }
 
+func (sheme *Sheme) VisibleString(val string) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagVisibleString, 0); err == nil {
+		for i := 0; i < len(val); i++ {
+			if !isVisible(val[i]) {
+				return nil, encodeDataErr("%s %s contains invalid character: %c", sheme.Name(), sheme.Type(), val[i])
+			}
+		}
+		if !strRestrict(val, sheme) {
+			return nil, encodeDataErr("%s %s contains invalid length: %d", sheme.Name(), sheme.Type(), len(val))
+		}
+		out.data = make([]byte, len(val))
+		copy(out.data, val)
+	}
+	return out, err
+}
+
+func (sheme *Sheme) GraphicString(val string) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagGraphicString, 0); err == nil {
+		for i := 0; i < len(val); i++ {
+			if !isGraphic(val[i]) {
+				return nil, encodeDataErr("%s %s contains invalid character: %c", sheme.Name(), sheme.Type(), val[i])
+			}
+		}
+		if !strRestrict(val, sheme) {
+			return nil, encodeDataErr("%s %s contains invalid length: %d", sheme.Name(), sheme.Type(), len(val))
+		}
+		out.data = make([]byte, len(val))
+		copy(out.data, val)
+	}
+	return out, err
+}
+
+func (sheme *Sheme) GeneralString(val string) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagGeneralString, 0); err == nil {
+		if !strRestrict(val, sheme) {
+			return nil, encodeDataErr("%s %s contains invalid length: %d", sheme.Name(), sheme.Type(), len(val))
+		}
+		out.data = make([]byte, len(val))
+		copy(out.data, val)
+	}
+	return out, err
+}
+
+func (sheme *Sheme) TeletexString(val string) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagTeletexString, 0); err == nil {
+		if !strRestrict(val, sheme) {
+			return nil, encodeDataErr("%s %s contains invalid length: %d", sheme.Name(), sheme.Type(), len(val))
+		}
+		out.data = make([]byte, len(val))
+		copy(out.data, val)
+	}
+	return out, err
+}
+
+func (sheme *Sheme) VideotexString(val string) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagVideotexString, 0); err == nil {
+		if !strRestrict(val, sheme) {
+			return nil, encodeDataErr("%s %s contains invalid length: %d", sheme.Name(), sheme.Type(), len(val))
+		}
+		out.data = make([]byte, len(val))
+		copy(out.data, val)
+	}
+	return out, err
+}
+
+func (sheme *Sheme) BMPString(val string) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagBMPString, 0); err == nil {
+		if !utf8.ValidString(val) {
+			return nil, encodeDataErr("%s %s invalid UTF-8 string", sheme.Name(), sheme.Type())
+		}
+		if !runeRestrict(val, sheme) {
+			return nil, encodeDataErr("%s %s contains invalid length: %d", sheme.Name(), sheme.Type(), utf8.RuneCountInString(val))
+		}
+		out.data = make([]byte, 0, 2*len(val))
+		for _, r := range val {
+			if r > 0xffff || utf16.IsSurrogate(r) {
+				return nil, encodeDataErr("%s %s contains invalid character: %c", sheme.Name(), sheme.Type(), r)
+			}
+			out.data = append(out.data, byte(r>>8), byte(r))
+		}
+	}
+	return out, err
+}
+
+func (sheme *Sheme) UniversalString(val string) (AsnElm, error) {
+	var out *AsnData
+	var err error
+	if out, err = makeType(sheme, tagUniversalString, 0); err == nil {
+		if !utf8.ValidString(val) {
+			return nil, encodeDataErr("%s %s invalid UTF-8 string", sheme.Name(), sheme.Type())
+		}
+		if !runeRestrict(val, sheme) {
+			return nil, encodeDataErr("%s %s contains invalid length: %d", sheme.Name(), sheme.Type(), utf8.RuneCountInString(val))
+		}
+		out.data = make([]byte, 0, 4*len(val))
+		for _, r := range val {
+			out.data = append(out.data, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		}
+	}
+	return out, err
+}
+
 func (sheme *Sheme) UTF8String(val string) (AsnElm, error) {
 	var out *AsnData
 	var err error
@@ -326,34 +519,41 @@ func (sheme *Sheme) OctetString(val []byte) (AsnElm, error) {
 }
 
 func (sheme *Sheme) Sequence() (AsnSeq, error) {
-	debugPrint("Sequence: '%s' taged: %t(%d)", sheme.Name(), sheme.Tagged(), sheme.Index())
 	var out *AsnData
 	var err error
 	fld := sheme.FieldAttr()
 	if out, err = makeType(sheme, tagSEQUENCE, len(fld)); err == nil {
-		if fld == nil && sheme.OfAttr() == nil {
+		if fld == nil && !sheme.isOf() {
 			return nil, encodeShemeErr("Sequence '%s' does not contain '$field' or '$of'", sheme.Name())
 		}
 	}
 	return out, err
 }
 
+func (sheme *Sheme) Set() (AsnSeq, error) {
+	var out *AsnData
+	var err error
+	fld := sheme.FieldAttr()
+	if out, err = makeType(sheme, tagSET, len(fld)); err == nil {
+		if fld == nil && !sheme.isOf() {
+			return nil, encodeShemeErr("Set '%s' does not contain '$field' or '$of'", sheme.Name())
+		}
+	}
+	return out, err
+}
+
 func (th *AsnData) SeqFieldByName(name string, el AsnElm, err error) error {
 	if err != nil {
 		return err
 	}
-	debugPrint("SeqFieldByName: '%s' set '%s' (%s)", th.sheme.Name(), name, this(el).sheme.Type())
 	dt := this(el)
-	if th.sheme.TypeEn() != tagSEQUENCE {
-		return encodeShemeErr("'%s' does not a SEQUENCE", th.sheme.Name())
+	if th.sheme.TypeEn() != tagSEQUENCE && th.sheme.TypeEn() != tagSET {
+		return encodeShemeErr("'%s' does not a SEQUENCE or SET", th.sheme.Name())
 	}
 	sh, err := findField(th.sheme, name)
 	if err != nil {
 		return err
 	}
-	if debug && !reflect.DeepEqual(sh.obj.Interface(), dt.sheme.obj.Interface()) {
-		return encodeShemeErr("SEQUENCE incompatible interfaces '%s' and '%s'", th.sheme.Name(), name)
-	}
 	id := sh.ID()
 	if id >= len(th.sub) || th.sub[id] != nil {
 		return encodeShemeErr("'%s' corrupt field id '%s'", th.sheme.Name(), name)
@@ -373,28 +573,22 @@ func (th *AsnData) SeqItem(el AsnElm, err error) error {
 	if err != nil {
 		return err
 	}
-	debugPrint("SeqItem: '%s' add %s", th.sheme.Name(), this(el).sheme.Type())
 	dt := this(el)
-	if th.sheme.TypeEn() != tagSEQUENCE {
-		return encodeShemeErr("'%s' does not a SEQUENCE", th.sheme.Name())
+	if th.sheme.TypeEn() != tagSEQUENCE && th.sheme.TypeEn() != tagSET {
+		return encodeShemeErr("'%s' does not a SEQUENCE or SET", th.sheme.Name())
 	}
-	sh, err := findOf(th.sheme)
-	if err != nil {
+	if _, err := findOf(th.sheme); err != nil {
 		return err
 	}
-	if debug && !reflect.DeepEqual(sh.obj.Interface(), dt.sheme.obj.Interface()) {
-		return encodeShemeErr("SEQUENCE OF incompatible interfaces '%s' and '%s'", th.sheme.Name(), dt.sheme.Name())
-	}
 	th.sub = append(th.sub, dt)
 	return nil
 }
 
 func (sheme *Sheme) Choice() (AsnChoice, error) {
-	debugPrint("Choice: '%s' taged: %t(%d)", sheme.Name(), sheme.Tagged(), sheme.Index())
 	var out *AsnData
 	var err error
 	if out, err = makeType(sheme, tagCHOICE, 1); err == nil {
-		if sheme.FieldAttr() == nil {
+		if !sheme.hasFields() {
 			return nil, encodeShemeErr("Choice '%s' does not contain '$field'", sheme.Name())
 		}
 	}
@@ -405,29 +599,33 @@ func (th *AsnData) ChoiceSetByName(name string, el AsnElm, err error) error {
 	if err != nil {
 		return err
 	}
-	debugPrint("ChoiceSetByName: '%s' set '%s' (%s)", th.sheme.Name(), name, this(el).sheme.Type())
 	dt := this(el)
 	if th.sheme.TypeEn() != tagCHOICE {
 		return encodeShemeErr("'%s' does not a CHOICE", th.sheme.Name())
 	}
-	sh, err := findField(th.sheme, name)
-	if err != nil {
+	if _, err := findField(th.sheme, name); err != nil {
 		return err
 	}
-	if debug && !reflect.DeepEqual(sh.obj.Interface(), dt.sheme.obj.Interface()) {
-		return encodeShemeErr("CHOICE incompatible interfaces '%s' and '%s'", sh.Name(), name)
+
+	if dt.sheme.Tagged() {
+		dt.tag.tagged = true
+		dt.tag.taggedN = dt.sheme.Index()
+		dt.tag.taggedC = dt.sheme.TagClass()
 	}
+	th.setChoice(dt)
+	return nil
+}
 
-	dt.tag.tagged = true
-	dt.tag.taggedN = dt.sheme.Index()
+// setChoice sets dt as the alternative of the CHOICE th, within the tag of
+// th if it has one. Nested tags are left to preprocess.
+func (th *AsnData) setChoice(dt *AsnData) {
 	th.sub[0] = dt
 
-	if th.tag.tagged {
+	if th.tag.tagged && th.tag.inner == nil {
 		th.tag.tagged = false
 		th.tag.tagNumber = th.tag.taggedN
-		th.tag.tagClass = classContextSpecific
+		th.tag.tagClass = th.tag.taggedC
 	}
-	return nil
 }
 
 func (th *AsnData) ChoiceSet(el AsnElm, err error) error {
@@ -438,11 +636,10 @@ func (th *AsnData) ChoiceSet(el AsnElm, err error) error {
 }
 
 func (sheme *Sheme) Any() (AsnAny, error) {
-	debugPrint("Any: '%s' taged: %t(%d)", sheme.Name(), sheme.Tagged(), sheme.Index())
 	var out *AsnData
 	var err error
 	if out, err = makeType(sheme, tagANY, 1); err == nil {
-		if sheme.FieldAttr() == nil {
+		if !sheme.hasFields() {
 			return nil, encodeShemeErr("ANY '%s' does not contain '$field'", sheme.Name())
 		}
 	}
@@ -453,18 +650,13 @@ func (th *AsnData) AnySetByName(name string, el AsnElm, err error) error {
 	if err != nil {
 		return err
 	}
-	debugPrint("AnySetByName: '%s' set '%s' (%s)", th.sheme.Name(), name, this(el).sheme.Type())
 	dt := this(el)
 	if th.sheme.TypeEn() != tagANY {
 		return encodeShemeErr("'%s' does not a ANY", th.sheme.Name())
 	}
-	sh, err := findField(th.sheme, name)
-	if err != nil {
+	if _, err := findField(th.sheme, name); err != nil {
 		return err
 	}
-	if debug && !reflect.DeepEqual(sh.obj.Interface(), dt.sheme.obj.Interface()) {
-		return encodeShemeErr("ANY incompatible interfaces '%s' and '%s'", sh.Name(), name)
-	}
 	th.sub[0] = dt
 	return nil
 }
@@ -485,46 +677,58 @@ func lowest_set_bit(value int) int {
 	return offset
 }
 
-// https://github.com/eerimoq/asn1tools/blob/master/asn1tools/codecs/ber.py
 func (sheme *Sheme) Real(val float64) (AsnElm, error) {
 	var out *AsnData
 	var err error
 	if out, err = makeType(sheme, tagREAL, 0); err == nil {
-		if math.IsInf(val, 1) {
-			out.data = []byte{0x40}
-		} else if math.IsInf(val, -1) {
-			out.data = []byte{0x41}
-		} else if math.IsNaN(val) {
-			out.data = []byte{0x42}
-		} else if val == 0.0 {
-			// out.data
-		} else {
-			negative_bit := byte(0)
-			if val < 0 {
-				negative_bit = 0x40
-				val *= -1
-			}
-
-			mantissa, exponent := math.Frexp(math.Abs(val))
-			mantissa_i := int(mantissa * math.Pow(2, 53))
-			lowest_set_bit := lowest_set_bit(mantissa_i)
-			mantissa_i >>= uint(lowest_set_bit)
-			mantissa_i |= (0x80 << (8 * ((uint(bits.Len(uint(mantissa_i))) / 8) + 1)))
-			mantissa_d, _ := hex.DecodeString(fmt.Sprintf("%x", uint(mantissa_i))[2:])
-			exponent = (52 - lowest_set_bit - exponent)
-			var exponent_d []byte
-
-			if -129 < exponent && exponent < 128 {
-				exponent_d = []byte{byte(0x80 | negative_bit), byte((0xff - exponent) & 0xff)}
-			} else if -32769 < exponent && exponent < 32768 {
-				exponent = ((0xffff - exponent) & 0xffff)
-				exponent_d = []byte{0x81 | negative_bit, byte((exponent >> 8) & 0xff), byte(exponent & 0xff)}
-			} else {
-				encodeDataErr("REAL exponent out of range")
-			}
-
-			out.data = append(exponent_d, mantissa_d...)
+		if out.data, err = AppendReal(nil, val); err != nil {
+			return nil, err
 		}
 	}
 	return out, err
 }
+
+// AppendReal appends the contents of a REAL in the binary form of base 2.
+//
+// https://github.com/eerimoq/asn1tools/blob/master/asn1tools/codecs/ber.py
+func AppendReal(dst []byte, val float64) ([]byte, error) {
+	if math.IsInf(val, 1) {
+		return append(dst, 0x40), nil
+	} else if math.IsInf(val, -1) {
+		return append(dst, 0x41), nil
+	} else if math.IsNaN(val) {
+		return append(dst, 0x42), nil
+	} else if val == 0.0 {
+		if math.Signbit(val) {
+			return append(dst, 0x43), nil
+		}
+		return dst, nil
+	}
+
+	negative_bit := byte(0)
+	if val < 0 {
+		negative_bit = 0x40
+		val *= -1
+	}
+
+	mantissa, exponent := math.Frexp(math.Abs(val))
+	mantissa_i := int(mantissa * math.Pow(2, 53))
+	lowest_set_bit := lowest_set_bit(mantissa_i)
+	mantissa_i >>= uint(lowest_set_bit)
+	mantissa_i |= (0x80 << (8 * ((uint(bits.Len(uint(mantissa_i))) / 8) + 1)))
+	mantissa_d, _ := hex.DecodeString(fmt.Sprintf("%x", uint(mantissa_i))[2:])
+	exponent = (52 - lowest_set_bit - exponent)
+	var exponent_d []byte
+
+	if -129 < exponent && exponent < 128 {
+		exponent_d = []byte{byte(0x80 | negative_bit), byte((0xff - exponent) & 0xff)}
+	} else if -32769 < exponent && exponent < 32768 {
+		exponent = ((0xffff - exponent) & 0xffff)
+		exponent_d = []byte{0x81 | negative_bit, byte((exponent >> 8) & 0xff), byte(exponent & 0xff)}
+	} else {
+		return dst, encodeDataErr("REAL exponent out of range")
+	}
+
+	dst = append(dst, exponent_d...)
+	return append(dst, mantissa_d...), nil
+}
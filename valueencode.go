@@ -0,0 +1,421 @@
+package asn1dynamic
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+// valueInt returns an integer value given as any of the Go numbers or as a
+// decimal string.
+func valueInt(val interface{}) (*big.Int, bool) {
+	switch v := val.(type) {
+	case int:
+		return big.NewInt(int64(v)), true
+	case int64:
+		return big.NewInt(v), true
+	case *big.Int:
+		return v, v != nil
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		out, _ := big.NewFloat(v).Int(nil)
+		return out, true
+	case json.Number:
+		return new(big.Int).SetString(string(v), 10)
+	case string:
+		return new(big.Int).SetString(v, 10)
+	}
+	return nil, false
+}
+
+// valueReal returns a real value given as any of the Go numbers or as a
+// string.
+func valueReal(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	if v, ok := valueInt(val); ok {
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, true
+	}
+	return 0, false
+}
+
+// valueBytes returns the octets given as a slice or, as json encodes them,
+// in base64.
+func valueBytes(val interface{}) ([]byte, bool) {
+	switch v := val.(type) {
+	case []byte:
+		return v, true
+	case string:
+		out, err := base64.StdEncoding.DecodeString(v)
+		return out, err == nil
+	}
+	return nil, false
+}
+
+// valueBits returns a BIT STRING given as a BitStr or as the object json
+// encodes it to.
+func valueBits(val interface{}) (BitStr, bool) {
+	switch v := val.(type) {
+	case BitStr:
+		return v, true
+	case map[string]interface{}:
+		data, ok := valueBytes(v["Bytes"])
+		if v["Bytes"] == nil {
+			data, ok = nil, true
+		}
+		num, fnd := valueInt(v["BitLength"])
+		if !ok || !fnd || !num.IsInt64() {
+			return BitStr{}, false
+		}
+		return BitStr{Bytes: data, BitLength: int(num.Int64())}, true
+	}
+	return BitStr{}, false
+}
+
+// valueOID returns an OBJECT IDENTIFIER given as an OID, a list of numbers or
+// a dotted string.
+func valueOID(val interface{}) (OID, bool) {
+	switch v := val.(type) {
+	case OID:
+		return v, len(v) >= 2
+	case []int:
+		return OID(v), len(v) >= 2
+	case string:
+		return parseOID(v)
+	case []interface{}:
+		out := make(OID, len(v))
+		for i, itm := range v {
+			n, ok := valueInt(itm)
+			if !ok || !n.IsInt64() || n.Sign() < 0 {
+				return nil, false
+			}
+			out[i] = int(n.Int64())
+		}
+		return out, len(out) >= 2
+	}
+	return nil, false
+}
+
+// valueTime returns a time given as a time.Time, as the RFC 3339 string json
+// encodes it to or as the characters of its BER contents.
+func valueTime(sheme *Sheme, val interface{}, ctx *AsnContext) (time.Time, bool) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if tm, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return tm, true
+		}
+		if tm, err := timeValue(sheme, v, ctx); err == nil {
+			return tm.(time.Time), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func valueErr(sheme *Sheme, val interface{}) error {
+	return encodeDataErr("'%s' %s wrong value type: %T", sheme.Name(), sheme.Type(), val)
+}
+
+// Encode encodes val in BER, val being a *simplejson.Json or a value shaped
+// as the result of Decode. Numbers and strings are converted to the types of
+// the sheme, so the result of Decode may be encoded again, also after a trip
+// through json.
+func Encode(sheme *Sheme, val interface{}) ([]byte, error) {
+	if js, ok := val.(*simplejson.Json); ok {
+		val = js.Interface()
+	}
+	el, err := build(sheme, val, &AsnContext{})
+	if err != nil {
+		return nil, err
+	}
+	return el.Encode()
+}
+
+// build makes the AsnData tree of val, a value shaped as the result of
+// Decode. ctx carries the ObjectDescriptor selecting an ANY alternative and
+// the options, whose Tagging applies to the tags the sheme leaves open.
+func build(sheme *Sheme, val interface{}, ctx *AsnContext) (*AsnData, error) {
+	ctx.debugPrint("build: '%s' (%s)", sheme.Name(), sheme.Type())
+	out, err := buildValue(sheme, val, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tagging := ctx.options().Tagging; tagging != defaultOptions.Tagging {
+		out.tag.mode(sheme, tagging)
+	}
+	return out, nil
+}
+
+func buildValue(sheme *Sheme, val interface{}, ctx *AsnContext) (*AsnData, error) {
+	var out AsnElm
+	var err error
+
+	switch tp := sheme.TypeEn(); tp {
+	case tagNULL:
+		out, err = sheme.Null()
+	case tagBOOLEAN:
+		v, ok := val.(bool)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		out, err = sheme.Boolean(v)
+	case tagINTEGER:
+		v, ok := valueInt(val)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		out, err = sheme.BigInteger(v)
+	case tagENUMERATED:
+		v, id, ok := sheme.enumItem(val)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		if v == "" {
+			// a value unknown to the sheme is sent by its number
+			out, err = sheme.enumerated(id)
+			break
+		}
+		out, err = sheme.Enumerated(v)
+	case tagREAL:
+		v, ok := valueReal(val)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		out, err = sheme.Real(v)
+	case tagOCTET_STR:
+		v, ok := valueBytes(val)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		out, err = sheme.OctetString(v)
+	case tagBIT_STR:
+		v, ok := valueBits(val)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		out, err = sheme.BitString(v)
+	case tagOID:
+		v, ok := valueOID(val)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		out, err = sheme.ObjectIdentifier(v)
+	case tagUTCTime, tagGeneralizedTime:
+		v, ok := valueTime(sheme, val, ctx)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		if tp == tagUTCTime {
+			out, err = sheme.UTCTime(v)
+		} else {
+			out, err = sheme.GeneralizedTime(v)
+		}
+	case tagObjDescriptor, tagUTF8String, tagNumericString, tagPrintableString, tagIA5String,
+		tagVisibleString, tagGraphicString, tagGeneralString, tagTeletexString, tagVideotexString,
+		tagBMPString, tagUniversalString:
+		v, ok := val.(string)
+		if !ok {
+			return nil, valueErr(sheme, val)
+		}
+		out, err = buildString(sheme, tp, v)
+		if tp == tagObjDescriptor {
+			ctx.od = v
+		}
+	case tagSEQUENCE, tagSET:
+		if sheme.isOf() {
+			return buildSequenceOf(sheme, val, ctx)
+		}
+		return buildSequence(sheme, val, ctx)
+	case tagCHOICE:
+		v, ok := val.(map[string]interface{})
+		if !ok || len(v) != 1 {
+			return nil, valueErr(sheme, val)
+		}
+		var seq AsnChoice
+		if seq, err = sheme.Choice(); err != nil {
+			return nil, err
+		}
+		if raw, ok := v[unknownKey]; ok {
+			el, err := buildUnknown(sheme, raw)
+			if err != nil {
+				return nil, err
+			}
+			this(seq).setChoice(el)
+			return this(seq), nil
+		}
+		for name, itm := range v {
+			alt, err := findField(sheme, name)
+			if err != nil {
+				return nil, err
+			}
+			el, err := build(alt, itm, &AsnContext{parent: ctx})
+			if err = seq.ChoiceSetByName(name, el, err); err != nil {
+				return nil, err
+			}
+		}
+		return this(seq), nil
+	case tagANY:
+		if ctx.od == "" {
+			return nil, encodeDataErr("'%s' miss ObjectDescriptor", sheme.Name())
+		}
+		alt := sheme.Field(ctx.od)
+		if alt == nil {
+			return nil, encodeDataErr("'%s' unknown ObjectDescriptor %s", sheme.Name(), ctx.od)
+		}
+		var seq AsnAny
+		if seq, err = sheme.Any(); err != nil {
+			return nil, err
+		}
+		el, err := build(alt, val, &AsnContext{parent: ctx})
+		if err = seq.AnySetByName(alt.Name(), el, err); err != nil {
+			return nil, err
+		}
+		return this(seq), nil
+	default:
+		return nil, encodeShemeErr("'%s' of unknown type '%s'", sheme.Name(), sheme.Type())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return this(out), nil
+}
+
+func buildString(sheme *Sheme, tag int, val string) (AsnElm, error) {
+	switch tag {
+	case tagObjDescriptor:
+		return sheme.ObjectDescriptor(val)
+	case tagUTF8String:
+		return sheme.UTF8String(val)
+	case tagNumericString:
+		return sheme.NumericString(val)
+	case tagPrintableString:
+		return sheme.PrintableString(val)
+	case tagIA5String:
+		return sheme.IA5String(val)
+	case tagVisibleString:
+		return sheme.VisibleString(val)
+	case tagGraphicString:
+		return sheme.GraphicString(val)
+	case tagGeneralString:
+		return sheme.GeneralString(val)
+	case tagTeletexString:
+		return sheme.TeletexString(val)
+	case tagVideotexString:
+		return sheme.VideotexString(val)
+	case tagBMPString:
+		return sheme.BMPString(val)
+	}
+	return sheme.UniversalString(val)
+}
+
+func buildConstructed(sheme *Sheme) (AsnSeq, error) {
+	if sheme.TypeEn() == tagSET {
+		return sheme.Set()
+	}
+	return sheme.Sequence()
+}
+
+func buildSequence(sheme *Sheme, val interface{}, ctx *AsnContext) (*AsnData, error) {
+	v, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, valueErr(sheme, val)
+	}
+	seq, err := buildConstructed(sheme)
+	if err != nil {
+		return nil, err
+	}
+	for name := range v {
+		if name == unknownKey {
+			continue
+		}
+		if _, err = findField(sheme, name); err != nil {
+			return nil, err
+		}
+	}
+	// the fields go in the order of the sheme, an ObjectDescriptor comes
+	// before the ANY it selects
+	ctxn := &AsnContext{parent: ctx}
+	fld := sheme.FieldList()
+	for f := fld.Begin(); f != nil; f = fld.Next() {
+		itm, ok := v[f.Name()]
+		if !ok {
+			continue
+		}
+		el, err := build(f, itm, ctxn)
+		if err = seq.SeqFieldByName(f.Name(), el, err); err != nil {
+			return nil, err
+		}
+	}
+	// the extensions unknown to the sheme go after its fields
+	if raw, f := v[unknownKey]; f {
+		lst, ok := raw.([]interface{})
+		if !ok {
+			return nil, valueErr(sheme, raw)
+		}
+		for _, itm := range lst {
+			el, err := buildUnknown(sheme, itm)
+			if err != nil {
+				return nil, err
+			}
+			this(seq).sub = append(this(seq).sub, el)
+		}
+	}
+	return this(seq), nil
+}
+
+// buildUnknown parses the raw encoding of an extension unknown to sheme,
+// as Decode keeps it under '$unknown', to send it again as it came.
+func buildUnknown(sheme *Sheme, val interface{}) (*AsnData, error) {
+	if !sheme.Extensible() {
+		return nil, encodeShemeErr("'%s' is not extensible", sheme.Name())
+	}
+	b, ok := valueBytes(val)
+	if !ok {
+		return nil, valueErr(sheme, val)
+	}
+	el := &AsnData{}
+	rest, ok, err := el.Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || len(rest) != 0 {
+		return nil, encodeDataErr("'%s' invalid unknown extension", sheme.Name())
+	}
+	return el, nil
+}
+
+func buildSequenceOf(sheme *Sheme, val interface{}, ctx *AsnContext) (*AsnData, error) {
+	v, ok := val.([]interface{})
+	if !ok {
+		return nil, valueErr(sheme, val)
+	}
+	seq, err := buildConstructed(sheme)
+	if err != nil {
+		return nil, err
+	}
+	of := sheme.Of()
+	for _, itm := range v {
+		el, err := build(of, itm, &AsnContext{parent: ctx})
+		if err = seq.SeqItem(el, err); err != nil {
+			return nil, err
+		}
+	}
+	return this(seq), nil
+}
@@ -1,6 +1,7 @@
 package asn1dynamic
 
 import (
+	"math/big"
 	"time"
 
 	"github.com/anton-zolotarev/go-simplejson"
@@ -8,9 +9,17 @@ import (
 
 type AsnElm interface {
 	Encode() ([]byte, error)
+	EncodeDER() ([]byte, error)
+	EncodeCER() ([]byte, error)
+	EncodeAPER() ([]byte, error)
+	EncodeUPER() ([]byte, error)
+	EncodeOER() ([]byte, error)
+	EncodeCOER() ([]byte, error)
 	RawData() []byte
 
 	Decode(sheme *Sheme) (*simplejson.Json, error)
+	DecodeDER(sheme *Sheme) (*simplejson.Json, error)
+	DecodeInto(sheme *Sheme, v interface{}) error
 	Parse(data []byte) ([]byte, bool, error)
 }
 
@@ -18,6 +27,7 @@ type AsnPath interface {
 	PathNull(path ...string) error
 	PathBoolean(val bool, path ...string) error
 	PathInteger(val int, path ...string) error
+	PathBigInteger(val *big.Int, path ...string) error
 	PathReal(val float64, path ...string) error
 	PathEnumerated(val string, path ...string) error
 	PathBitString(val BitStr, path ...string) error
@@ -29,9 +39,17 @@ type AsnPath interface {
 	PathPrintableString(val string, path ...string) error
 	PathIA5String(val string, path ...string) error
 	PathUTF8String(val string, path ...string) error
+	PathVisibleString(val string, path ...string) error
+	PathGraphicString(val string, path ...string) error
+	PathGeneralString(val string, path ...string) error
+	PathTeletexString(val string, path ...string) error
+	PathVideotexString(val string, path ...string) error
+	PathBMPString(val string, path ...string) error
+	PathUniversalString(val string, path ...string) error
 	PathOctetString(val []byte, path ...string) error
 
 	PathSequence(path ...string) (out AsnSeq, err error)
+	PathSet(path ...string) (out AsnSeq, err error)
 	PathChoice(path ...string) (out AsnChoice, err error)
 	PathAny(path ...string) (out AsnAny, err error)
 }
@@ -46,6 +64,7 @@ type AsnSeq interface {
 	SetNull(name string) error
 	SetBoolean(name string, val bool) error
 	SetInteger(name string, val int) error
+	SetBigInteger(name string, val *big.Int) error
 	SetReal(name string, val float64) error
 	SetEnumerated(name string, val string) error
 	SetBitString(name string, val BitStr) error
@@ -57,14 +76,23 @@ type AsnSeq interface {
 	SetPrintableString(name string, val string) error
 	SetIA5String(name string, val string) error
 	SetUTF8String(name string, val string) error
+	SetVisibleString(name string, val string) error
+	SetGraphicString(name string, val string) error
+	SetGeneralString(name string, val string) error
+	SetTeletexString(name string, val string) error
+	SetVideotexString(name string, val string) error
+	SetBMPString(name string, val string) error
+	SetUniversalString(name string, val string) error
 	SetOctetString(name string, val []byte) error
 
 	SetSequence(name string) (out AsnSeq, err error)
+	SetSet(name string) (out AsnSeq, err error)
 	SetChoice(name string) (out AsnChoice, err error)
 	SetAny(name string) (out AsnAny, err error)
 
 	AddBoolean(val bool) error
 	AddInteger(val int) error
+	AddBigInteger(val *big.Int) error
 	AddReal(val float64) error
 	AddEnumerated(val string) error
 	AddBitString(val BitStr) error
@@ -76,9 +104,17 @@ type AsnSeq interface {
 	AddPrintableString(val string) error
 	AddIA5String(val string) error
 	AddUTF8String(val string) error
+	AddVisibleString(val string) error
+	AddGraphicString(val string) error
+	AddGeneralString(val string) error
+	AddTeletexString(val string) error
+	AddVideotexString(val string) error
+	AddBMPString(val string) error
+	AddUniversalString(val string) error
 	AddOctetString(val []byte) error
 
 	AddSequence() (out AsnSeq, err error)
+	AddSet() (out AsnSeq, err error)
 	AddChoice() (out AsnChoice, err error)
 	AddAny() (out AsnAny, err error)
 }
@@ -92,6 +128,7 @@ type AsnChoice interface {
 	ChoiceNull(name string) error
 	ChoiceBoolean(name string, val bool) error
 	ChoiceInteger(name string, val int) error
+	ChoiceBigInteger(name string, val *big.Int) error
 	ChoiceReal(name string, val float64) error
 	ChoiceEnumerated(name string, val string) error
 	ChoiceBitString(name string, val BitStr) error
@@ -103,6 +140,13 @@ type AsnChoice interface {
 	ChoicePrintableString(name string, val string) error
 	ChoiceIA5String(name string, val string) error
 	ChoiceUTF8String(name string, val string) error
+	ChoiceVisibleString(name string, val string) error
+	ChoiceGraphicString(name string, val string) error
+	ChoiceGeneralString(name string, val string) error
+	ChoiceTeletexString(name string, val string) error
+	ChoiceVideotexString(name string, val string) error
+	ChoiceBMPString(name string, val string) error
+	ChoiceUniversalString(name string, val string) error
 	ChoiceOctetString(name string, val []byte) error
 
 	ChoiceSequence(name string) (out AsnSeq, err error)
@@ -119,6 +163,7 @@ type AsnAny interface {
 	AnyNull(name string) error
 	AnyBoolean(name string, val bool) error
 	AnyInteger(name string, val int) error
+	AnyBigInteger(name string, val *big.Int) error
 	AnyReal(name string, val float64) error
 	AnyEnumerated(name string, val string) error
 	AnyBitString(name string, val BitStr) error
@@ -130,6 +175,13 @@ type AsnAny interface {
 	AnyPrintableString(name string, val string) error
 	AnyIA5String(name string, val string) error
 	AnyUTF8String(name string, val string) error
+	AnyVisibleString(name string, val string) error
+	AnyGraphicString(name string, val string) error
+	AnyGeneralString(name string, val string) error
+	AnyTeletexString(name string, val string) error
+	AnyVideotexString(name string, val string) error
+	AnyBMPString(name string, val string) error
+	AnyUniversalString(name string, val string) error
 	AnyOctetString(name string, val []byte) error
 
 	AnySequence(name string) (out AsnSeq, err error)
@@ -146,7 +198,7 @@ func AsnElm2AsnDataSub(th AsnElm) []*AsnData {
 
 func setByType(th *AsnData, elm AsnElm, err error) error {
 	switch th.sheme.Type() {
-	case "SEQUENCE":
+	case "SEQUENCE", "SET":
 		return th.SeqField(elm, err)
 	case "CHOICE":
 		return th.ChoiceSet(elm, err)
@@ -166,6 +218,8 @@ func makePath(th *AsnData, path ...string) (*AsnData, error) {
 		switch sh.Type() {
 		case "SEQUENCE":
 			out, err = sh.Sequence()
+		case "SET":
+			out, err = sh.Set()
 		case "CHOICE":
 			out, err = sh.Choice()
 		case "ANY":
@@ -173,7 +227,6 @@ func makePath(th *AsnData, path ...string) (*AsnData, error) {
 		default:
 			return nil, encodeTypeErr(path[0], sh)
 		}
-		debugPrintln("Path:", path, th.sheme.Type(), sh.Type())
 	} else {
 		return nil, err
 	}
@@ -286,6 +339,43 @@ func (th *AsnData) AnyInteger(name string, val int) error {
 	return th.AnySet(th.Integer(name, val))
 }
 
+func (th *AsnData) BigInteger(name string, val *big.Int) (out AsnElm, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.BigInteger(val)
+	}
+	return
+}
+
+func (th *AsnData) PathBigInteger(val *big.Int, path ...string) error {
+	pth, err := makePath(th, path[:len(path)-1]...)
+	if err == nil {
+		el, err := pth.BigInteger(path[len(path)-1], val)
+		return setByType(pth, el, err)
+	}
+	return err
+}
+
+func (th *AsnData) AddBigInteger(val *big.Int) error {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		return th.SeqItem(sh.BigInteger(val))
+	}
+	return err
+}
+
+func (th *AsnData) SetBigInteger(name string, val *big.Int) error {
+	return th.SeqField(th.BigInteger(name, val))
+}
+
+func (th *AsnData) ChoiceBigInteger(name string, val *big.Int) error {
+	return th.ChoiceSet(th.BigInteger(name, val))
+}
+
+func (th *AsnData) AnyBigInteger(name string, val *big.Int) error {
+	return th.AnySet(th.BigInteger(name, val))
+}
+
 func (th *AsnData) Real(name string, val float64) (out AsnElm, err error) {
 	sh, err := findField(th.sheme, name)
 	if err == nil {
@@ -693,6 +783,265 @@ func (th *AsnData) AnyUTF8String(name string, val string) error {
 	return th.AnySet(th.UTF8String(name, val))
 }
 
+func (th *AsnData) VisibleString(name string, val string) (out AsnElm, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.VisibleString(val)
+	}
+	return
+}
+
+func (th *AsnData) PathVisibleString(val string, path ...string) error {
+	pth, err := makePath(th, path[:len(path)-1]...)
+	if err == nil {
+		el, err := pth.VisibleString(path[len(path)-1], val)
+		return setByType(pth, el, err)
+	}
+	return err
+}
+
+func (th *AsnData) AddVisibleString(val string) error {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		return th.SeqItem(sh.VisibleString(val))
+	}
+	return err
+}
+
+func (th *AsnData) SetVisibleString(name string, val string) error {
+	return th.SeqField(th.VisibleString(name, val))
+}
+
+func (th *AsnData) ChoiceVisibleString(name string, val string) error {
+	return th.ChoiceSet(th.VisibleString(name, val))
+}
+
+func (th *AsnData) AnyVisibleString(name string, val string) error {
+	return th.AnySet(th.VisibleString(name, val))
+}
+
+func (th *AsnData) GraphicString(name string, val string) (out AsnElm, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.GraphicString(val)
+	}
+	return
+}
+
+func (th *AsnData) PathGraphicString(val string, path ...string) error {
+	pth, err := makePath(th, path[:len(path)-1]...)
+	if err == nil {
+		el, err := pth.GraphicString(path[len(path)-1], val)
+		return setByType(pth, el, err)
+	}
+	return err
+}
+
+func (th *AsnData) AddGraphicString(val string) error {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		return th.SeqItem(sh.GraphicString(val))
+	}
+	return err
+}
+
+func (th *AsnData) SetGraphicString(name string, val string) error {
+	return th.SeqField(th.GraphicString(name, val))
+}
+
+func (th *AsnData) ChoiceGraphicString(name string, val string) error {
+	return th.ChoiceSet(th.GraphicString(name, val))
+}
+
+func (th *AsnData) AnyGraphicString(name string, val string) error {
+	return th.AnySet(th.GraphicString(name, val))
+}
+
+func (th *AsnData) GeneralString(name string, val string) (out AsnElm, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.GeneralString(val)
+	}
+	return
+}
+
+func (th *AsnData) PathGeneralString(val string, path ...string) error {
+	pth, err := makePath(th, path[:len(path)-1]...)
+	if err == nil {
+		el, err := pth.GeneralString(path[len(path)-1], val)
+		return setByType(pth, el, err)
+	}
+	return err
+}
+
+func (th *AsnData) AddGeneralString(val string) error {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		return th.SeqItem(sh.GeneralString(val))
+	}
+	return err
+}
+
+func (th *AsnData) SetGeneralString(name string, val string) error {
+	return th.SeqField(th.GeneralString(name, val))
+}
+
+func (th *AsnData) ChoiceGeneralString(name string, val string) error {
+	return th.ChoiceSet(th.GeneralString(name, val))
+}
+
+func (th *AsnData) AnyGeneralString(name string, val string) error {
+	return th.AnySet(th.GeneralString(name, val))
+}
+
+func (th *AsnData) TeletexString(name string, val string) (out AsnElm, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.TeletexString(val)
+	}
+	return
+}
+
+func (th *AsnData) PathTeletexString(val string, path ...string) error {
+	pth, err := makePath(th, path[:len(path)-1]...)
+	if err == nil {
+		el, err := pth.TeletexString(path[len(path)-1], val)
+		return setByType(pth, el, err)
+	}
+	return err
+}
+
+func (th *AsnData) AddTeletexString(val string) error {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		return th.SeqItem(sh.TeletexString(val))
+	}
+	return err
+}
+
+func (th *AsnData) SetTeletexString(name string, val string) error {
+	return th.SeqField(th.TeletexString(name, val))
+}
+
+func (th *AsnData) ChoiceTeletexString(name string, val string) error {
+	return th.ChoiceSet(th.TeletexString(name, val))
+}
+
+func (th *AsnData) AnyTeletexString(name string, val string) error {
+	return th.AnySet(th.TeletexString(name, val))
+}
+
+func (th *AsnData) VideotexString(name string, val string) (out AsnElm, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.VideotexString(val)
+	}
+	return
+}
+
+func (th *AsnData) PathVideotexString(val string, path ...string) error {
+	pth, err := makePath(th, path[:len(path)-1]...)
+	if err == nil {
+		el, err := pth.VideotexString(path[len(path)-1], val)
+		return setByType(pth, el, err)
+	}
+	return err
+}
+
+func (th *AsnData) AddVideotexString(val string) error {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		return th.SeqItem(sh.VideotexString(val))
+	}
+	return err
+}
+
+func (th *AsnData) SetVideotexString(name string, val string) error {
+	return th.SeqField(th.VideotexString(name, val))
+}
+
+func (th *AsnData) ChoiceVideotexString(name string, val string) error {
+	return th.ChoiceSet(th.VideotexString(name, val))
+}
+
+func (th *AsnData) AnyVideotexString(name string, val string) error {
+	return th.AnySet(th.VideotexString(name, val))
+}
+
+func (th *AsnData) BMPString(name string, val string) (out AsnElm, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.BMPString(val)
+	}
+	return
+}
+
+func (th *AsnData) PathBMPString(val string, path ...string) error {
+	pth, err := makePath(th, path[:len(path)-1]...)
+	if err == nil {
+		el, err := pth.BMPString(path[len(path)-1], val)
+		return setByType(pth, el, err)
+	}
+	return err
+}
+
+func (th *AsnData) AddBMPString(val string) error {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		return th.SeqItem(sh.BMPString(val))
+	}
+	return err
+}
+
+func (th *AsnData) SetBMPString(name string, val string) error {
+	return th.SeqField(th.BMPString(name, val))
+}
+
+func (th *AsnData) ChoiceBMPString(name string, val string) error {
+	return th.ChoiceSet(th.BMPString(name, val))
+}
+
+func (th *AsnData) AnyBMPString(name string, val string) error {
+	return th.AnySet(th.BMPString(name, val))
+}
+
+func (th *AsnData) UniversalString(name string, val string) (out AsnElm, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.UniversalString(val)
+	}
+	return
+}
+
+func (th *AsnData) PathUniversalString(val string, path ...string) error {
+	pth, err := makePath(th, path[:len(path)-1]...)
+	if err == nil {
+		el, err := pth.UniversalString(path[len(path)-1], val)
+		return setByType(pth, el, err)
+	}
+	return err
+}
+
+func (th *AsnData) AddUniversalString(val string) error {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		return th.SeqItem(sh.UniversalString(val))
+	}
+	return err
+}
+
+func (th *AsnData) SetUniversalString(name string, val string) error {
+	return th.SeqField(th.UniversalString(name, val))
+}
+
+func (th *AsnData) ChoiceUniversalString(name string, val string) error {
+	return th.ChoiceSet(th.UniversalString(name, val))
+}
+
+func (th *AsnData) AnyUniversalString(name string, val string) error {
+	return th.AnySet(th.UniversalString(name, val))
+}
+
 func (th *AsnData) OctetString(name string, val []byte) (out AsnElm, err error) {
 	sh, err := findField(th.sheme, name)
 	if err == nil {
@@ -774,6 +1123,36 @@ func (th *AsnData) AnySequence(name string) (out AsnSeq, err error) {
 	return
 }
 
+func (th *AsnData) Set(name string) (out AsnSeq, err error) {
+	sh, err := findField(th.sheme, name)
+	if err == nil {
+		out, err = sh.Set()
+	}
+	return
+}
+
+func (th *AsnData) PathSet(path ...string) (out AsnSeq, err error) {
+	out, err = makePath(th, path...)
+	return
+}
+
+func (th *AsnData) AddSet() (out AsnSeq, err error) {
+	sh, err := findOf(th.sheme)
+	if err == nil {
+		if out, err = sh.Set(); err == nil {
+			err = th.SeqItem(out, nil)
+		}
+	}
+	return
+}
+
+func (th *AsnData) SetSet(name string) (out AsnSeq, err error) {
+	if out, err = th.Set(name); err == nil {
+		err = th.SeqField(out, nil)
+	}
+	return
+}
+
 func (th *AsnData) Choice(name string) (out AsnChoice, err error) {
 	sh, err := findField(th.sheme, name)
 	if err == nil {
@@ -0,0 +1,98 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestXERControlCharacters(t *testing.T) {
+	sh, err := NewSheme([]byte(`{"T":{"$type":"SEQUENCE","$field":{
+		"s":{"$id":0,"$type":"UTF8String"},
+		"g":{"$id":1,"$type":"GeneralString"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	val := map[string]interface{}{"s": "\x00a\x01b\tc\r\n\x1f", "g": "\x1b(B<&>"}
+	doc, err := cls.EncodeXER(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"<nul/>a<soh/>b&#x9;c&#xD;&#xA;<is1/>", "<esc/>(B&lt;&amp;&gt;"} {
+		if !strings.Contains(string(doc), tag) {
+			t.Errorf("%s lacks %q", doc, tag)
+		}
+	}
+
+	el, err := cls.DecodeXER(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := el.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder()
+	if _, _, err := dec.Parse(data); err != nil {
+		t.Fatal(err)
+	}
+	js, err := dec.Decode(cls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range val {
+		if got := js.Get(k).MustString(); got != v {
+			t.Errorf("%s: %q, want %q", k, got, v)
+		}
+	}
+
+	if _, err := cls.DecodeXER([]byte("<T><s>a<b/></s><g/></T>")); err == nil {
+		t.Error("decoded an unknown element in a string")
+	}
+}
+
+func TestXERUnknownEnumerated(t *testing.T) {
+	sh, err := NewShemeASN1([]byte(`X DEFINITIONS ::= BEGIN
+T ::= SEQUENCE { c Color, l SEQUENCE OF Color }
+Color ::= ENUMERATED { red, green, ... }
+END`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cls := sh.Class("T")
+	val := map[string]interface{}{"c": 7, "l": []interface{}{"green", 9}}
+	xer, err := cls.EncodeXER(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"<c>7</c>", "<green/>", "<Color>9</Color>"} {
+		if !strings.Contains(string(xer), tag) {
+			t.Errorf("%s lacks %q", xer, tag)
+		}
+	}
+	jer, err := cls.EncodeJER(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"c":7,"l":["green",9]}`; string(jer) != want {
+		t.Errorf("JER %s, want %s", jer, want)
+	}
+
+	want, err := Encode(cls, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dec := range []func() (AsnElm, error){
+		func() (AsnElm, error) { return cls.DecodeXER(xer) },
+		func() (AsnElm, error) { return cls.DecodeJER(jer) },
+	} {
+		el, err := dec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := el.Encode(); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("decoded to %x, want %x", got, want)
+		}
+	}
+}
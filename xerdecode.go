@@ -0,0 +1,358 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// xerNode is an element of a XER document. at is the length of the text
+// of its parent where it starts.
+type xerNode struct {
+	name string
+	text []byte
+	sub  []*xerNode
+	at   int
+}
+
+func parseXER(data []byte) (*xerNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var root *xerNode
+	var stack []*xerNode
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, decodeDataErr("XER %s", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xerNode{name: t.Name.Local}
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				n.at = len(top.text)
+				top.sub = append(top.sub, n)
+			} else if root == nil {
+				root = n
+			} else {
+				return nil, decodeDataErr("XER more than one document element '%s'", n.name)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				top.text = append(top.text, t...)
+			}
+		}
+	}
+	if root == nil {
+		return nil, decodeDataErr("XER no document element")
+	}
+	return root, nil
+}
+
+// DecodeXER reads a document of the Basic XML Encoding Rules into the
+// AsnData tree of sheme, ready to be encoded.
+func (sheme *Sheme) DecodeXER(data []byte) (AsnElm, error) {
+	root, err := parseXER(data)
+	if err != nil {
+		return nil, err
+	}
+	if name := xerName(sheme.Name()); name != "" && root.name != name {
+		return nil, decodeDataErr("XER document element '%s' is not '%s'", root.name, name)
+	}
+	return root.element(sheme, &AsnContext{})
+}
+
+// parseOID reads an OBJECT IDENTIFIER in the dotted form.
+func parseOID(s string) (OID, bool) {
+	var v OID
+	for _, arc := range strings.Split(s, ".") {
+		i, err := strconv.Atoi(arc)
+		if err != nil || i < 0 {
+			return nil, false
+		}
+		v = append(v, i)
+	}
+	return v, len(v) >= 2
+}
+
+// timeValue reads a time written as the characters of its BER contents.
+func timeValue(sh *Sheme, s string, ctx *AsnContext) (interface{}, error) {
+	data := []byte(s)
+	tmp := &AsnData{fdata: data, data: data, len: len(data)}
+	tmp.tag.tagNumber = sh.TypeEn()
+	return tmp.decode(sh, ctx)
+}
+
+// timeElement makes the element of a time written as the characters of its
+// BER contents, which are kept as they are.
+func timeElement(sh *Sheme, s string, ctx *AsnContext) (AsnElm, error) {
+	if _, err := timeValue(sh, s, ctx); err != nil {
+		return nil, err
+	}
+	out, err := makeType(sh, sh.TypeEn(), 0)
+	if err != nil {
+		return nil, err
+	}
+	out.data = []byte(s)
+	return out, nil
+}
+
+// content returns the text of an element without the white space.
+func (n *xerNode) content() string {
+	return strings.Join(strings.Fields(string(n.text)), "")
+}
+
+// empty returns the name of the only empty element within n, the value of
+// a BOOLEAN, an ENUMERATED or a special REAL.
+func (n *xerNode) empty() (string, bool) {
+	if len(n.sub) != 1 || len(n.sub[0].sub) != 0 || n.content() != "" {
+		return "", false
+	}
+	return n.sub[0].name, true
+}
+
+// element makes the AsnData of the element by sh.
+func (n *xerNode) element(sh *Sheme, ctx *AsnContext) (AsnElm, error) {
+	ctx.debugPrint("DecodeXER: '%s' (%s) element '%s'", sh.Name(), sh.Type(), n.name)
+	tp := sh.TypeEn()
+	switch tp {
+	case tagNULL, tagINTEGER, tagOCTET_STR, tagBIT_STR, tagOID, tagUTCTime, tagGeneralizedTime:
+		if len(n.sub) != 0 {
+			return nil, decodeDataErr("'%s' unexpected element '%s'", sh.Name(), n.sub[0].name)
+		}
+	}
+
+	switch tp {
+	case tagNULL:
+		if n.content() != "" {
+			return nil, decodeDataErr("'%s' NULL not empty", sh.Name())
+		}
+		return sh.Null()
+	case tagBOOLEAN:
+		v, ok := n.empty()
+		if !ok {
+			v = n.content()
+		}
+		switch v {
+		case "true":
+			return sh.Boolean(true)
+		case "false":
+			return sh.Boolean(false)
+		}
+		return nil, decodeDataErr("'%s' wrong BOOLEAN value '%s'", sh.Name(), v)
+	case tagENUMERATED:
+		v, ok := n.empty()
+		if !ok {
+			v = n.content()
+		}
+		var val interface{} = v
+		if id, err := strconv.Atoi(v); err == nil && !ok {
+			// a value unknown to the sheme comes by its number
+			val = id
+		}
+		name, id, ok := sh.enumItem(val)
+		if !ok {
+			return nil, decodeDataErr("'%s' wrong ENUMERATED value '%s'", sh.Name(), v)
+		}
+		if name == "" {
+			return sh.enumerated(id)
+		}
+		return sh.Enumerated(name)
+	case tagINTEGER:
+		v, ok := new(big.Int).SetString(n.content(), 10)
+		if !ok {
+			return nil, decodeDataErr("'%s' wrong INTEGER value '%s'", sh.Name(), n.content())
+		}
+		return sh.BigInteger(v)
+	case tagREAL:
+		if v, ok := n.empty(); ok {
+			switch v {
+			case "PLUS-INFINITY":
+				return sh.Real(math.Inf(1))
+			case "MINUS-INFINITY":
+				return sh.Real(math.Inf(-1))
+			case "NOT-A-NUMBER":
+				return sh.Real(math.NaN())
+			}
+			return nil, decodeDataErr("'%s' wrong REAL value '%s'", sh.Name(), v)
+		}
+		v, err := strconv.ParseFloat(n.content(), 64)
+		if err != nil || len(n.sub) != 0 {
+			return nil, decodeDataErr("'%s' wrong REAL value '%s'", sh.Name(), n.content())
+		}
+		return sh.Real(v)
+	case tagOCTET_STR:
+		v, err := hex.DecodeString(n.content())
+		if err != nil {
+			return nil, decodeDataErr("'%s' wrong OCTET STRING value: %s", sh.Name(), err)
+		}
+		return sh.OctetString(v)
+	case tagBIT_STR:
+		s := n.content()
+		v := BitStr{Bytes: make([]byte, (len(s)+7)/8), BitLength: len(s)}
+		for i, c := range []byte(s) {
+			switch c {
+			case '1':
+				v.Bytes[i/8] |= 0x80 >> uint(i%8)
+			case '0':
+			default:
+				return nil, decodeDataErr("'%s' wrong BIT STRING value '%s'", sh.Name(), s)
+			}
+		}
+		return sh.BitString(v)
+	case tagOID:
+		v, ok := parseOID(n.content())
+		if !ok {
+			return nil, decodeDataErr("'%s' wrong OBJECT IDENTIFIER value '%s'", sh.Name(), n.content())
+		}
+		return sh.ObjectIdentifier(v)
+	case tagUTCTime, tagGeneralizedTime:
+		// the same characters as the BER contents
+		return timeElement(sh, n.content(), ctx)
+	case tagSEQUENCE, tagSET:
+		if n.content() != "" {
+			return nil, decodeDataErr("'%s' unexpected text", sh.Name())
+		}
+		if sh.isOf() {
+			return n.sequenceOf(sh, ctx)
+		}
+		return n.sequence(sh, ctx)
+	case tagCHOICE:
+		if len(n.sub) != 1 || n.content() != "" {
+			return nil, decodeDataErr("'%s' CHOICE takes one element", sh.Name())
+		}
+		alt := sh.Field(n.sub[0].name)
+		if alt == nil {
+			return nil, decodeDataErr("'%s' unknown alternative '%s'", sh.Name(), n.sub[0].name)
+		}
+		seq, err := sh.Choice()
+		if err != nil {
+			return nil, err
+		}
+		el, err := n.sub[0].element(alt, &AsnContext{parent: ctx})
+		if err = seq.ChoiceSetByName(alt.Name(), el, err); err != nil {
+			return nil, err
+		}
+		return seq, nil
+	case tagANY:
+		if ctx.od == "" {
+			return nil, decodeDataErr("'%s' miss ObjectDescriptor", sh.Name())
+		}
+		alt := sh.Field(ctx.od)
+		if alt == nil {
+			return nil, decodeDataErr("'%s' unknown ObjectDescriptor %s", sh.Name(), ctx.od)
+		}
+		seq, err := sh.Any()
+		if err != nil {
+			return nil, err
+		}
+		el, err := n.element(alt, &AsnContext{parent: ctx})
+		if err = seq.AnySetByName(alt.Name(), el, err); err != nil {
+			return nil, err
+		}
+		return seq, nil
+	}
+	// the character strings keep their white space
+	v, err := n.chars(sh)
+	if err != nil {
+		return nil, err
+	}
+	if tp == tagObjDescriptor {
+		ctx.od = v
+	}
+	return buildString(sh, tp, v)
+}
+
+// chars returns the text of a character string with its control characters
+// in place of their empty elements.
+func (n *xerNode) chars(sh *Sheme) (string, error) {
+	var b strings.Builder
+	from := 0
+	for _, sub := range n.sub {
+		c := -1
+		for i, name := range xerControls {
+			if name == sub.name {
+				c = i
+			}
+		}
+		if c < 0 || len(sub.sub) != 0 || len(sub.text) != 0 {
+			return "", decodeDataErr("'%s' unexpected element '%s'", sh.Name(), sub.name)
+		}
+		b.Write(n.text[from:sub.at])
+		b.WriteByte(byte(c))
+		from = sub.at
+	}
+	b.Write(n.text[from:])
+	return b.String(), nil
+}
+
+func (n *xerNode) sequence(sh *Sheme, ctx *AsnContext) (AsnElm, error) {
+	subs := make(map[string]*xerNode, len(n.sub))
+	for _, sub := range n.sub {
+		f := sh.Field(sub.name)
+		if f == nil {
+			return nil, decodeDataErr("'%s' unexpected field '%s'", sh.Name(), sub.name)
+		}
+		if _, ok := subs[f.Name()]; ok {
+			return nil, decodeDataErr("'%s' repeated field '%s'", sh.Name(), sub.name)
+		}
+		subs[f.Name()] = sub
+	}
+	seq, err := buildConstructed(sh)
+	if err != nil {
+		return nil, err
+	}
+	// the fields are read in the order of the sheme, an ObjectDescriptor
+	// comes before the ANY it selects
+	ctxn := &AsnContext{parent: ctx}
+	present := func(f *Sheme) bool {
+		_, ok := subs[f.Name()]
+		return ok
+	}
+	fld := sh.FieldList()
+	for f := fld.Begin(); f != nil; f = fld.Next() {
+		sub, ok := subs[f.Name()]
+		if !ok {
+			if !sh.omissible(f, present) {
+				return nil, decodeDataErr("'%s' miss not optional field '%s'", sh.Name(), f.Name())
+			}
+			continue
+		}
+		el, err := sub.element(f, ctxn)
+		if err = seq.SeqFieldByName(f.Name(), el, err); err != nil {
+			return nil, err
+		}
+	}
+	return seq, nil
+}
+
+func (n *xerNode) sequenceOf(sh *Sheme, ctx *AsnContext) (AsnElm, error) {
+	seq, err := buildConstructed(sh)
+	if err != nil {
+		return nil, err
+	}
+	of := sh.Of()
+	for _, sub := range n.sub {
+		// a BOOLEAN or an ENUMERATED item may stand without an element of
+		// its own
+		if xerEmpty(of) && len(sub.sub) == 0 && sub.content() == "" {
+			sub = &xerNode{sub: []*xerNode{sub}}
+		}
+		el, err := sub.element(of, &AsnContext{parent: ctx})
+		if err = seq.SeqItem(el, err); err != nil {
+			return nil, err
+		}
+	}
+	return seq, nil
+}
@@ -0,0 +1,335 @@
+package asn1dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/xml"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anton-zolotarev/go-simplejson"
+)
+
+// xerWriter builds a BASIC-XER (X.693) document, the constructed values
+// indented by two spaces a level.
+type xerWriter struct {
+	buf bytes.Buffer
+}
+
+// xerName returns the element name of a class: its name without the module.
+func xerName(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// xerTypeName returns the element name of an item of a SEQUENCE OF: the
+// class it refers to or the name of the builtin type (X.693 Table 4).
+func xerTypeName(sh *Sheme) string {
+	if ref := sh.raw(sh.OfAttr(), "").Ref(); ref != "" {
+		return xerName(ref)
+	}
+	of := sh.Of()
+	switch tp := of.TypeEn(); tp {
+	case tagOID:
+		return "OBJECT_IDENTIFIER"
+	case tagSEQUENCE, tagSET:
+		if of.isOf() {
+			return of.Type() + "_OF"
+		}
+	}
+	return of.Type()
+}
+
+// xerEmpty reports whether the values of the type are empty elements, which
+// stand alone as the items of a SEQUENCE OF.
+func xerEmpty(sh *Sheme) bool {
+	tp := sh.TypeEn()
+	return tp == tagBOOLEAN || tp == tagENUMERATED
+}
+
+// xerEmptyValue returns the empty element standing for a BOOLEAN or an
+// ENUMERATED value, or the number of an ENUMERATED value unknown to sh.
+func xerEmptyValue(sh *Sheme, val interface{}) (string, error) {
+	if sh.TypeEn() == tagBOOLEAN {
+		v, ok := val.(bool)
+		if !ok {
+			return "", valueErr(sh, val)
+		}
+		return "<" + strconv.FormatBool(v) + "/>", nil
+	}
+	v, id, ok := sh.enumItem(val)
+	if !ok {
+		return "", encodeDataErr("'%s' %s wrong value: '%v'", sh.Name(), sh.Type(), val)
+	}
+	if v == "" {
+		// a value unknown to the sheme is written by its number
+		return strconv.Itoa(id), nil
+	}
+	return "<" + v + "/>", nil
+}
+
+// xerReal formats a REAL value as an XML real number or names its special
+// value.
+func xerReal(v float64) (string, bool) {
+	switch {
+	case math.IsInf(v, 1):
+		return "PLUS-INFINITY", true
+	case math.IsInf(v, -1):
+		return "MINUS-INFINITY", true
+	case math.IsNaN(v):
+		return "NOT-A-NUMBER", true
+	case v == 0:
+		if math.Signbit(v) {
+			return "-0", false
+		}
+		return "0", false
+	}
+	s := strconv.FormatFloat(v, 'E', -1, 64)
+	i := strings.IndexByte(s, 'E')
+	exp, _ := strconv.Atoi(s[i+1:])
+	return s[:i+1] + strconv.Itoa(exp), false
+}
+
+// EncodeXER writes val, a value shaped as the result of Decode, in the
+// Basic XML Encoding Rules. The document element is named after the sheme.
+func (sheme *Sheme) EncodeXER(val interface{}) ([]byte, error) {
+	if js, ok := val.(*simplejson.Json); ok {
+		val = js.Interface()
+	}
+	w := &xerWriter{}
+	if err := w.element(xerName(sheme.Name()), sheme, val, &AsnContext{}, 0); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+func (w *xerWriter) indent(lvl int) {
+	for i := 0; i < lvl; i++ {
+		w.buf.WriteString("  ")
+	}
+}
+
+// xerControls names the control characters, which a character string
+// carries as empty elements; HT, LF and CR may stand as themselves (X.693
+// Table 3).
+var xerControls = [...]string{
+	"nul", "soh", "stx", "etx", "eot", "enq", "ack", "bel",
+	"bs", "ht", "lf", "vt", "ff", "cr", "so", "si",
+	"dle", "dc1", "dc2", "dc3", "dc4", "nak", "syn", "etb",
+	"can", "em", "sub", "esc", "is4", "is3", "is2", "is1",
+}
+
+// text writes the characters of a string, the control characters that XML
+// cannot hold as their empty elements.
+func (w *xerWriter) text(s string) {
+	from := 0
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' && c != '\t' && c != '\n' && c != '\r' {
+			xml.EscapeText(&w.buf, []byte(s[from:i]))
+			w.buf.WriteString("<" + xerControls[c] + "/>")
+			from = i + 1
+		}
+	}
+	xml.EscapeText(&w.buf, []byte(s[from:]))
+}
+
+// empty writes an empty element.
+func (w *xerWriter) empty(name string) {
+	w.buf.WriteString("<" + name + "/>\n")
+}
+
+// element writes val as the element name, on its own line at level lvl.
+func (w *xerWriter) element(name string, sh *Sheme, val interface{}, ctx *AsnContext, lvl int) error {
+	ctx.debugPrint("EncodeXER: '%s' (%s)", sh.Name(), sh.Type())
+	if sh.TypeEn() == tagANY {
+		if ctx.od == "" {
+			return encodeDataErr("'%s' miss ObjectDescriptor", sh.Name())
+		}
+		alt := sh.Field(ctx.od)
+		if alt == nil {
+			return encodeDataErr("'%s' unknown ObjectDescriptor %s", sh.Name(), ctx.od)
+		}
+		return w.element(name, alt, val, &AsnContext{parent: ctx}, lvl)
+	}
+	w.indent(lvl)
+	var txt string
+	var err error
+
+	switch tp := sh.TypeEn(); tp {
+	case tagNULL:
+		w.empty(name)
+		return nil
+	case tagBOOLEAN, tagENUMERATED:
+		if txt, err = xerEmptyValue(sh, val); err != nil {
+			return err
+		}
+	case tagINTEGER:
+		v, ok := valueInt(val)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		txt = v.String()
+	case tagREAL:
+		v, ok := valueReal(val)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		s, special := xerReal(v)
+		if txt = s; special {
+			txt = "<" + s + "/>"
+		}
+	case tagOCTET_STR:
+		v, ok := val.([]byte)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		txt = strings.ToUpper(hex.EncodeToString(v))
+	case tagBIT_STR:
+		v, ok := val.(BitStr)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		b := make([]byte, v.BitLength)
+		for i := range b {
+			b[i] = '0' + byte(v.At(i))
+		}
+		txt = string(b)
+	case tagOID:
+		v, ok := val.(OID)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		txt = v.String()
+	case tagUTCTime, tagGeneralizedTime:
+		// the same characters as the BER contents
+		v, ok := val.(time.Time)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		var el AsnElm
+		if tp == tagUTCTime {
+			el, err = sh.UTCTime(v)
+		} else {
+			el, err = sh.GeneralizedTime(v)
+		}
+		if err != nil {
+			return err
+		}
+		txt = string(this(el).data)
+	case tagSEQUENCE, tagSET:
+		if sh.isOf() {
+			return w.sequenceOf(name, sh, val, ctx, lvl)
+		}
+		return w.sequence(name, sh, val, ctx, lvl)
+	case tagCHOICE:
+		v, ok := val.(map[string]interface{})
+		if !ok || len(v) != 1 {
+			return valueErr(sh, val)
+		}
+		w.buf.WriteString("<" + name + ">\n")
+		for k, itm := range v {
+			alt, err := findField(sh, k)
+			if err != nil {
+				return err
+			}
+			if err = w.element(k, alt, itm, &AsnContext{parent: ctx}, lvl+1); err != nil {
+				return err
+			}
+		}
+		w.indent(lvl)
+		w.buf.WriteString("</" + name + ">\n")
+		return nil
+	default:
+		v, ok := val.(string)
+		if !ok {
+			return valueErr(sh, val)
+		}
+		if tp == tagObjDescriptor {
+			ctx.od = v
+		}
+		w.buf.WriteString("<" + name + ">")
+		w.text(v)
+		w.buf.WriteString("</" + name + ">\n")
+		return nil
+	}
+	w.buf.WriteString("<" + name + ">" + txt + "</" + name + ">\n")
+	return nil
+}
+
+func (w *xerWriter) sequence(name string, sh *Sheme, val interface{}, ctx *AsnContext, lvl int) error {
+	v, ok := val.(map[string]interface{})
+	if !ok {
+		return valueErr(sh, val)
+	}
+	for k := range v {
+		if _, err := findField(sh, k); err != nil {
+			return err
+		}
+	}
+	if len(v) == 0 {
+		w.empty(name)
+		return nil
+	}
+	w.buf.WriteString("<" + name + ">\n")
+	ctxn := &AsnContext{parent: ctx}
+	present := func(f *Sheme) bool {
+		_, ok := v[f.Name()]
+		return ok
+	}
+	fld := sh.FieldList()
+	for f := fld.Begin(); f != nil; f = fld.Next() {
+		itm, ok := v[f.Name()]
+		if !ok {
+			if !sh.omissible(f, present) {
+				return encodeShemeErr("'%s' miss not optional field '%s'", sh.Name(), f.Name())
+			}
+			continue
+		}
+		if err := w.element(f.Name(), f, itm, ctxn, lvl+1); err != nil {
+			return err
+		}
+	}
+	w.indent(lvl)
+	w.buf.WriteString("</" + name + ">\n")
+	return nil
+}
+
+func (w *xerWriter) sequenceOf(name string, sh *Sheme, val interface{}, ctx *AsnContext, lvl int) error {
+	v, ok := val.([]interface{})
+	if !ok {
+		return valueErr(sh, val)
+	}
+	if len(v) == 0 {
+		w.empty(name)
+		return nil
+	}
+	w.buf.WriteString("<" + name + ">\n")
+	of, item := sh.Of(), xerTypeName(sh)
+	for _, itm := range v {
+		if !xerEmpty(of) {
+			if err := w.element(item, of, itm, &AsnContext{parent: ctx}, lvl+1); err != nil {
+				return err
+			}
+			continue
+		}
+		// the items of a list of BOOLEAN or ENUMERATED values go
+		// without an element of their own
+		txt, err := xerEmptyValue(of, itm)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(txt, "<") {
+			// but for a number, which is not an element
+			txt = "<" + item + ">" + txt + "</" + item + ">"
+		}
+		w.indent(lvl + 1)
+		w.buf.WriteString(txt + "\n")
+	}
+	w.indent(lvl)
+	w.buf.WriteString("</" + name + ">\n")
+	return nil
+}